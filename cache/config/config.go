@@ -2,10 +2,19 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"github.com/redis/go-redis/v9"
+	"github.com/sparrow-community/app/cache/handler"
+	"github.com/sparrow-community/app/cache/metrics"
+	"github.com/sparrow-community/app/cache/namespace"
+	"github.com/sparrow-community/app/cache/store"
 	mconfig "github.com/sparrow-community/pkgs/config"
 	"github.com/urfave/cli/v2"
 	"go-micro.dev/v4/logger"
+	"os"
+	"time"
 )
 
 var (
@@ -14,21 +23,173 @@ var (
 			Name:    "cache",
 			Address: ":",
 		},
+		Store: Store{
+			Type:  StoreRedis,
+			Bolt:  "./cache.db",
+			Sweep: "1m",
+		},
 		Redis: Redis{
-			Addr: "localhost:6379",
+			Mode:           RedisStandalone,
+			Addr:           "localhost:6379",
+			StartupTimeout: "1m",
+			SlowLog:        "100ms",
+		},
+		TLS: TLS{
+			Address: ":8443",
+		},
+		Namespaces: Namespaces{
+			Interval: "1m",
+		},
+		Near: Near{
+			Ttl: "10s",
+		},
+		Values: Values{
+			MaxSize:           4 << 20,
+			Compression:       handler.CompressionZstd,
+			CompressThreshold: 1024,
 		},
 	}
 )
 
+const (
+	StoreRedis  = "redis"
+	StoreMemory = "memory"
+	StoreBolt   = "bolt"
+)
+
+// Store engine of the cache, memory and bolt run without a redis server
+type Store struct {
+	Type string `json:"type"`
+	// MaxKeys of the memory store, the least recently used keys are evicted beyond it, 0 is unlimited
+	MaxKeys int `json:"max_keys"`
+	// Bolt database file of the bolt store
+	Bolt string `json:"bolt"`
+	// Sweep interval of the removal of the expired keys of the memory and bolt stores
+	Sweep string `json:"sweep"`
+}
+
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
+// RedisTLS enables tls to the redis nodes, CA verifies them and Cert, Key authenticate the client
+type RedisTLS struct {
+	Enabled            bool   `json:"enabled"`
+	CA                 string `json:"ca"`
+	Cert               string `json:"cert"`
+	Key                string `json:"key"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// Redis topology of the cache, Addrs are the node of a standalone redis, the sentinels of a sentinel
+// MasterName or the seed nodes of a cluster, Addr is kept for the single node configurations
 type Redis struct {
-	Addr string `json:"addr"`
+	Mode       string   `json:"mode"`
+	Addr       string   `json:"addr"`
+	Addrs      []string `json:"addrs"`
+	MasterName string   `json:"master_name"`
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	// SentinelUsername, SentinelPassword authenticate to the sentinels when they differ from the master ones
+	SentinelUsername string `json:"sentinel_username"`
+	SentinelPassword string `json:"sentinel_password"`
+	// DB index, a cluster only has the database 0
+	DB  int      `json:"db"`
+	TLS RedisTLS `json:"tls"`
+	// PoolSize connections by node, 0 is 10 by cpu
+	PoolSize     int `json:"pool_size"`
+	MinIdleConns int `json:"min_idle_conns"`
+	// DialTimeout, ReadTimeout, WriteTimeout and PoolTimeout durations, empty keeps the client defaults
+	DialTimeout  string `json:"dial_timeout"`
+	ReadTimeout  string `json:"read_timeout"`
+	WriteTimeout string `json:"write_timeout"`
+	PoolTimeout  string `json:"pool_timeout"`
+	// StartupTimeout longest wait of a reachable redis at startup, the connection is retried with backoff
+	StartupTimeout string `json:"startup_timeout"`
+	// SlowLog logs the commands slower than this duration, empty or 0 logs none
+	SlowLog string `json:"slow_log"`
+}
+
+// TLS server certificate, client certificates signed by CA name the calling services
+type TLS struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	CA   string `json:"ca"`
+	// Address of the grpc server with tls, the listener multiplexer cannot tell tls connections apart
+	Address string `json:"address"`
+}
+
+// Namespaces isolate the keys of every calling service under its name, the name comes from the client
+// certificate of the caller or, with TrustFromService, from the metadata of its go-micro client which
+// is only safe when every client of the network is trusted
+type Namespaces struct {
+	Enabled          bool `json:"enabled"`
+	TrustFromService bool `json:"trust_from_service"`
+	// Keys, Memory quotas of the services without a tenant, 0 is unlimited
+	Keys   int64 `json:"keys"`
+	Memory int64 `json:"memory"`
+	// Interval between two measures of the namespaces usage
+	Interval string             `json:"interval"`
+	Tenants  []namespace.Tenant `json:"tenants"`
+}
+
+// Locks Redlock addresses of independent redis masters leasing the locks instead of the store,
+// they use the credentials and the tls of Redis
+type Locks struct {
+	Redlock []string `json:"redlock"`
+}
+
+// Near in process tier keeping up to MaxKeys string values read from the store for up to Ttl, the replicas
+// broadcast their writes so none keeps a changed value, the writes of other redis clients are only seen
+// once the copies expire, 0 keys disables it
+type Near struct {
+	MaxKeys int    `json:"max_keys"`
+	Ttl     string `json:"ttl"`
+}
+
+// Values MaxSize bounds in bytes every value, field, element and member stored, 0 is unlimited, the values of
+// the value service of at least CompressThreshold bytes are compressed with Compression, zstd or snappy,
+// a 0 threshold compresses none, their data never exceeds handler.MaxDecodedSize
+type Values struct {
+	MaxSize           int64  `json:"max_size"`
+	Compression       string `json:"compression"`
+	CompressThreshold int64  `json:"compress_threshold"`
+}
+
+// Loaders Services the loaders of every caller may call, a caller may always register the loaders of its own
+// service, the cache calls them with its own identity
+type Loaders struct {
+	Services []string `json:"services"`
+}
+
+// Api credentials of the services calling the http api, a call with the token of a credential runs as its
+// service, the api is closed without credentials
+type Api struct {
+	Credentials []handler.Credential `json:"credentials"`
+}
+
+// Admin api and web ui browsing the keys of every namespace, they are disabled without a Token
+type Admin struct {
+	Token string `json:"token"`
 }
 
 type Config struct {
-	Server mconfig.Server `json:"server"`
-	Redis  Redis          `json:"redis"`
+	Server     mconfig.Server `json:"server"`
+	Store      Store          `json:"store"`
+	Redis      Redis          `json:"redis"`
+	TLS        TLS            `json:"tls"`
+	Namespaces Namespaces     `json:"namespaces"`
+	Locks      Locks          `json:"locks"`
+	Near       Near           `json:"near"`
+	Values     Values         `json:"values"`
+	Loaders    Loaders        `json:"loaders"`
+	Api        Api            `json:"api"`
+	Admin      Admin          `json:"admin"`
 
-	RedisClient *redis.Client `json:"-"`
+	RedisClient redis.UniversalClient `json:"-"`
 }
 
 // Init .
@@ -36,7 +197,38 @@ func (c *Config) Init() error {
 	mc, err := mconfig.New(
 		mconfig.WithDefaultConfig(c),
 		mconfig.WithFlags(
+			&cli.StringFlag{Name: "store_type", Usage: "cache store, redis, memory or bolt", EnvVars: []string{"STORE_TYPE"}},
+			&cli.IntFlag{Name: "store_max_keys", Usage: "keys of the memory store, 0 is unlimited", EnvVars: []string{"STORE_MAX_KEYS"}},
+			&cli.StringFlag{Name: "store_bolt", Usage: "bolt store database file", EnvVars: []string{"STORE_BOLT"}},
+			&cli.StringFlag{Name: "tls_cert", Usage: "tls certificate file", EnvVars: []string{"TLS_CERT"}},
+			&cli.StringFlag{Name: "tls_key", Usage: "tls private key file", EnvVars: []string{"TLS_KEY"}},
+			&cli.StringFlag{Name: "tls_ca", Usage: "client certificates authority file", EnvVars: []string{"TLS_CA"}},
+			&cli.StringFlag{Name: "tls_address", Usage: "grpc tls server address", EnvVars: []string{"TLS_ADDRESS"}},
+			&cli.BoolFlag{Name: "namespaces_enabled", Usage: "isolate the keys of every calling service", EnvVars: []string{"NAMESPACES_ENABLED"}},
+			&cli.BoolFlag{Name: "namespaces_trust_from_service", Usage: "name the callers without a client certificate from their metadata", EnvVars: []string{"NAMESPACES_TRUST_FROM_SERVICE"}},
+			&cli.IntFlag{Name: "near_max_keys", Usage: "values kept in process, 0 disables the near tier", EnvVars: []string{"NEAR_MAX_KEYS"}},
+			&cli.StringFlag{Name: "near_ttl", Usage: "longest life of the values kept in process", EnvVars: []string{"NEAR_TTL"}},
+			&cli.Int64Flag{Name: "values_max_size", Usage: "largest value in bytes, 0 is unlimited", EnvVars: []string{"VALUES_MAX_SIZE"}},
+			&cli.StringFlag{Name: "values_compression", Usage: "compression of the large values, zstd or snappy", EnvVars: []string{"VALUES_COMPRESSION"}},
+			&cli.Int64Flag{Name: "values_compress_threshold", Usage: "smallest value in bytes compressed, 0 compresses none", EnvVars: []string{"VALUES_COMPRESS_THRESHOLD"}},
+			&cli.StringSliceFlag{Name: "loaders_services", Usage: "services the loaders of every caller may call", EnvVars: []string{"LOADERS_SERVICES"}},
+			&cli.StringFlag{Name: "admin_token", Usage: "bearer token of the admin api, empty disables it", EnvVars: []string{"ADMIN_TOKEN"}},
+			&cli.StringSliceFlag{Name: "locks_redlock", Usage: "independent redis masters of the locks", EnvVars: []string{"LOCKS_REDLOCK"}},
+			&cli.StringFlag{Name: "redis_mode", Usage: "redis topology, standalone, sentinel or cluster", EnvVars: []string{"REDIS_MODE"}},
 			&cli.StringFlag{Name: "redis_addr", Usage: "redis address", EnvVars: []string{"REDIS_ADDR"}},
+			&cli.StringSliceFlag{Name: "redis_addrs", Usage: "redis sentinel or cluster addresses", EnvVars: []string{"REDIS_ADDRS"}},
+			&cli.StringFlag{Name: "redis_master_name", Usage: "redis sentinel master name", EnvVars: []string{"REDIS_MASTER_NAME"}},
+			&cli.StringFlag{Name: "redis_username", Usage: "redis username", EnvVars: []string{"REDIS_USERNAME"}},
+			&cli.StringFlag{Name: "redis_password", Usage: "redis password", EnvVars: []string{"REDIS_PASSWORD"}},
+			&cli.IntFlag{Name: "redis_db", Usage: "redis database index", EnvVars: []string{"REDIS_DB"}},
+			&cli.BoolFlag{Name: "redis_tls_enabled", Usage: "connect to redis with tls", EnvVars: []string{"REDIS_TLS_ENABLED"}},
+			&cli.StringFlag{Name: "redis_tls_ca", Usage: "redis ca certificate file", EnvVars: []string{"REDIS_TLS_CA"}},
+			&cli.StringFlag{Name: "redis_tls_cert", Usage: "redis client certificate file", EnvVars: []string{"REDIS_TLS_CERT"}},
+			&cli.StringFlag{Name: "redis_tls_key", Usage: "redis client key file", EnvVars: []string{"REDIS_TLS_KEY"}},
+			&cli.IntFlag{Name: "redis_pool_size", Usage: "redis connections by node", EnvVars: []string{"REDIS_POOL_SIZE"}},
+			&cli.IntFlag{Name: "redis_min_idle_conns", Usage: "redis idle connections kept by node", EnvVars: []string{"REDIS_MIN_IDLE_CONNS"}},
+			&cli.StringFlag{Name: "redis_startup_timeout", Usage: "longest wait of redis at startup", EnvVars: []string{"REDIS_STARTUP_TIMEOUT"}},
+			&cli.StringFlag{Name: "redis_slow_log", Usage: "log the redis commands slower than this duration", EnvVars: []string{"REDIS_SLOW_LOG"}},
 		),
 	)
 	if err != nil {
@@ -47,22 +239,302 @@ func (c *Config) Init() error {
 		return err
 	}
 
-	logger.Infof("Read config: %+#v", c)
-
-	c.InitRedis()
+	logger.Infof("Read config: %+#v", c.redacted())
 
 	return nil
 }
 
-func (c *Config) InitRedis() {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
+// redacted returns a copy of c without its secrets, for the logs
+func (c *Config) redacted() *Config {
+	r := *c
+	r.Redis.Password = redact(r.Redis.Password)
+	r.Redis.SentinelPassword = redact(r.Redis.SentinelPassword)
+	r.Admin.Token = redact(r.Admin.Token)
+	r.Api.Credentials = make([]handler.Credential, len(c.Api.Credentials))
+	for i, cr := range c.Api.Credentials {
+		r.Api.Credentials[i] = handler.Credential{Service: cr.Service, Token: redact(cr.Token)}
+	}
+	return &r
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
+// OpenStore opens the configured store, the redis store connects to Redis first,
+// the store isolates the callers when the namespaces are enabled
+func (c *Config) OpenStore() (store.Store, error) {
+	switch c.Values.Compression {
+	case handler.CompressionZstd, handler.CompressionSnappy, handler.CompressionNone:
+	default:
+		return nil, fmt.Errorf("unknown compression %s", c.Values.Compression)
+	}
+	s, err := c.openEngine()
+	if err != nil {
+		return nil, err
+	}
+	if c.Near.MaxKeys > 0 {
+		ttl, err := time.ParseDuration(c.Near.Ttl)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		near, err := store.WithNear(s, c.Near.MaxKeys, ttl)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s = near
+	}
+	if len(c.Locks.Redlock) > 0 {
+		r, err := c.openRedlock()
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s = store.WithLocker(s, r)
+	}
+	if c.Values.MaxSize > 0 {
+		s = store.WithMaxValue(s, c.Values.MaxSize)
+	}
+	if !c.Namespaces.Enabled {
+		return s, nil
+	}
+	interval, err := time.ParseDuration(c.Namespaces.Interval)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return namespace.New(s, namespace.Options{
+		Tenants:          c.Namespaces.Tenants,
+		Keys:             c.Namespaces.Keys,
+		Memory:           c.Namespaces.Memory,
+		TrustFromService: c.Namespaces.TrustFromService,
+		Interval:         interval,
+	}), nil
+}
+
+func (c *Config) openEngine() (store.Store, error) {
+	switch c.Store.Type {
+	case StoreRedis:
+		if err := c.InitRedis(); err != nil {
+			return nil, err
+		}
+		var slow time.Duration
+		if c.Redis.SlowLog != "" {
+			d, err := time.ParseDuration(c.Redis.SlowLog)
+			if err != nil {
+				_ = c.RedisClient.Close()
+				return nil, err
+			}
+			slow = d
+		}
+		metrics.Redis(c.RedisClient, slow)
+		return store.NewRedis(c.RedisClient), nil
+	case StoreMemory, StoreBolt:
+		sweep, err := time.ParseDuration(c.Store.Sweep)
+		if err != nil {
+			return nil, err
+		}
+		if c.Store.Type == StoreMemory {
+			return store.NewMemory(c.Store.MaxKeys, sweep), nil
+		}
+		return store.NewBolt(c.Store.Bolt, sweep)
+	}
+	return nil, fmt.Errorf("unknown store %s", c.Store.Type)
+}
+
+// ValueOptions returns the encoding of the values of the value service
+func (c *Config) ValueOptions() handler.ValueOptions {
+	return handler.ValueOptions{
+		Compression: c.Values.Compression,
+		Threshold:   c.Values.CompressThreshold,
+		MaxSize:     c.Values.MaxSize,
+	}
+}
+
+func (c *Config) LoaderOptions() handler.LoaderOptions {
+	return handler.LoaderOptions{
+		Services:         c.Loaders.Services,
+		TrustFromService: c.Namespaces.TrustFromService,
+	}
+}
+
+// TLSConfig returns nil when no certificate is configured
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	t := c.TLS
+	if t.Cert == "" || t.Key == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", t.CA)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tc, nil
+}
+
+// openRedlock connects to every Locks.Redlock master
+func (c *Config) openRedlock() (*store.Redlock, error) {
+	opts, err := c.Redis.Options()
+	if err != nil {
+		return nil, err
+	}
+	var lockers []store.Locker
+	for _, addr := range c.Locks.Redlock {
+		o := opts.Simple()
+		o.Addr = addr
+		o.DB = 0
+		lockers = append(lockers, store.NewRedis(redis.NewClient(o)))
+	}
+	return store.NewRedlock(lockers...), nil
+}
+
+// InitRedis connects to the configured redis topology
+func (c *Config) InitRedis() error {
+	opts, err := c.Redis.Options()
+	if err != nil {
+		return err
+	}
+	var rdb redis.UniversalClient
+	switch c.Redis.Mode {
+	case RedisStandalone, "":
+		rdb = redis.NewClient(opts.Simple())
+	case RedisSentinel:
+		if opts.MasterName == "" {
+			return fmt.Errorf("redis sentinel mode needs a master name")
+		}
+		rdb = redis.NewFailoverClient(opts.Failover())
+	case RedisCluster:
+		rdb = redis.NewClusterClient(opts.Cluster())
+	default:
+		return fmt.Errorf("unknown redis mode %s", c.Redis.Mode)
+	}
+
+	if err := c.waitRedis(rdb, opts.Addrs); err != nil {
+		_ = rdb.Close()
+		return err
+	}
 	c.RedisClient = rdb
-	_, err := rdb.Ping(context.Background()).Result()
+	return nil
+}
+
+// waitRedis pings rdb until it answers, it retries with an exponential backoff up to Redis.StartupTimeout
+// so the cache survives a redis that starts after it or restarts at the same time
+func (c *Config) waitRedis(rdb redis.UniversalClient, addrs []string) error {
+	timeout := time.Duration(0)
+	if c.Redis.StartupTimeout != "" {
+		d, err := time.ParseDuration(c.Redis.StartupTimeout)
+		if err != nil {
+			return err
+		}
+		timeout = d
+	}
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := rdb.Ping(ctx).Err()
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("connection redis %v error: %w", addrs, err)
+		}
+		logger.Warnf("connection redis %v attempt %d error: %v, retrying in %s", addrs, attempt, err, delay)
+		time.Sleep(delay)
+		if delay < 5*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+// Options returns the client options of the redis topology
+func (r Redis) Options() (*redis.UniversalOptions, error) {
+	addrs := r.Addrs
+	if len(addrs) == 0 && r.Addr != "" {
+		addrs = []string{r.Addr}
+	}
+	opts := &redis.UniversalOptions{
+		Addrs:            addrs,
+		MasterName:       r.MasterName,
+		Username:         r.Username,
+		Password:         r.Password,
+		SentinelUsername: r.SentinelUsername,
+		SentinelPassword: r.SentinelPassword,
+		DB:               r.DB,
+		PoolSize:         r.PoolSize,
+		MinIdleConns:     r.MinIdleConns,
+	}
+	for _, d := range []struct {
+		value string
+		to    *time.Duration
+	}{
+		{r.DialTimeout, &opts.DialTimeout},
+		{r.ReadTimeout, &opts.ReadTimeout},
+		{r.WriteTimeout, &opts.WriteTimeout},
+		{r.PoolTimeout, &opts.PoolTimeout},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, err
+		}
+		*d.to = v
+	}
+	tc, err := r.TLS.Config()
 	if err != nil {
-		logger.Fatalf("connection redis error: %s", err)
+		return nil, err
+	}
+	opts.TLSConfig = tc
+	return opts, nil
+}
+
+// Config returns nil when tls is disabled
+func (t RedisTLS) Config() (*tls.Config, error) {
+	if !t.Enabled {
+		return nil, nil
+	}
+	tc := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if t.Cert != "" || t.Key != "" {
+		cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", t.CA)
+		}
+		tc.RootCAs = pool
 	}
+	return tc, nil
 }
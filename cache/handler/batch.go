@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// MaxBatchSize operations of a batch or a pipeline
+const MaxBatchSize = 1000
+
+// Result of a key of a batch, Error is set instead when the operation of the key failed
+type Result struct {
+	Key   string `json:"key"`
+	Field string `json:"field,omitempty"`
+	// Found is false for a missing key or field
+	Found bool   `json:"found"`
+	Value string `json:"value,omitempty"`
+	// Ttl remaining seconds of a key, -1 when it does not expire
+	Ttl   int64  `json:"ttl,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type MGetRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type MGetResponse struct {
+	Results []*Result `json:"results"`
+}
+
+type SetItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Ttl seconds, 0 never expires
+	Ttl int64 `json:"ttl"`
+}
+
+type MSetRequest struct {
+	Items []*SetItem `json:"items"`
+}
+
+type MSetResponse struct {
+	Results []*Result `json:"results"`
+}
+
+type MDeleteRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type MDeleteResponse struct {
+	// Results Found tells whether the key existed
+	Results []*Result `json:"results"`
+	Deleted int64     `json:"deleted"`
+}
+
+type HGetItem struct {
+	Key   string `json:"key"`
+	Field string `json:"field"`
+}
+
+type MHGetRequest struct {
+	Items []*HGetItem `json:"items"`
+}
+
+type MHGetResponse struct {
+	Results []*Result `json:"results"`
+}
+
+// Operation of a pipeline, Op is get, set, delete, incrby, hget, hset or hgetall
+type Operation struct {
+	Op     string            `json:"op"`
+	Key    string            `json:"key"`
+	Field  string            `json:"field,omitempty"`
+	Value  string            `json:"value,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// Ttl seconds of a set, 0 never expires
+	Ttl int64 `json:"ttl,omitempty"`
+	// By increment of an incrby
+	By int64 `json:"by,omitempty"`
+}
+
+type PipelineRequest struct {
+	Operations []*Operation `json:"operations"`
+	// Atomic runs the operations in a transaction, no other command runs in between but an operation
+	// that fails does not undo the others
+	Atomic bool `json:"atomic"`
+}
+
+// OperationResult Int is the value of an incrby, the deleted keys of a delete or the added fields of a hset
+type OperationResult struct {
+	Found  bool              `json:"found"`
+	Value  string            `json:"value,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Int    int64             `json:"int,omitempty"`
+	Ttl    int64             `json:"ttl,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+type PipelineResponse struct {
+	Results []*OperationResult `json:"results"`
+}
+
+// BatchService runs several operations in one round trip to the store, every operation has its own result,
+// its messages are plain structs served over the application/grpc+json codec only
+type BatchService struct {
+	store store.Store
+}
+
+func NewBatchService(s store.Store) *BatchService {
+	return &BatchService{store: s}
+}
+
+func (b *BatchService) MGet(ctx context.Context, request *MGetRequest, response *MGetResponse) error {
+	ops := make([]store.Op, len(request.Keys))
+	for i, k := range request.Keys {
+		ops[i] = store.Op{Kind: store.OpGet, Key: k}
+	}
+	results, err := b.exec(ctx, ops, false)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		response.Results = append(response.Results, result(request.Keys[i], "", decoded(request.Keys[i], r)))
+	}
+	return nil
+}
+
+func (b *BatchService) MSet(ctx context.Context, request *MSetRequest, response *MSetResponse) error {
+	ops := make([]store.Op, len(request.Items))
+	for i, item := range request.Items {
+		if err := raw(item.Key, item.Value); err != nil {
+			return err
+		}
+		ops[i] = store.Op{Kind: store.OpSet, Key: item.Key, Value: item.Value, TTL: time.Duration(item.Ttl) * time.Second}
+	}
+	results, err := b.exec(ctx, ops, false)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		response.Results = append(response.Results, result(request.Items[i].Key, "", r))
+	}
+	return nil
+}
+
+func (b *BatchService) MDelete(ctx context.Context, request *MDeleteRequest, response *MDeleteResponse) error {
+	ops := make([]store.Op, len(request.Keys))
+	for i, k := range request.Keys {
+		ops[i] = store.Op{Kind: store.OpDelete, Key: k}
+	}
+	results, err := b.exec(ctx, ops, false)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		res := result(request.Keys[i], "", r)
+		res.Found = r.Err == nil && r.Int > 0
+		response.Deleted += r.Int
+		response.Results = append(response.Results, res)
+	}
+	return nil
+}
+
+func (b *BatchService) MHGet(ctx context.Context, request *MHGetRequest, response *MHGetResponse) error {
+	ops := make([]store.Op, len(request.Items))
+	for i, item := range request.Items {
+		ops[i] = store.Op{Kind: store.OpHGet, Key: item.Key, Field: item.Field}
+	}
+	results, err := b.exec(ctx, ops, false)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		response.Results = append(response.Results, result(request.Items[i].Key, request.Items[i].Field, r))
+	}
+	return nil
+}
+
+func (b *BatchService) Pipeline(ctx context.Context, request *PipelineRequest, response *PipelineResponse) error {
+	ops := make([]store.Op, len(request.Operations))
+	for i, o := range request.Operations {
+		ops[i] = store.Op{
+			Kind:   store.OpKind(o.Op),
+			Key:    o.Key,
+			Field:  o.Field,
+			Value:  o.Value,
+			Fields: o.Fields,
+			TTL:    time.Duration(o.Ttl) * time.Second,
+			By:     o.By,
+		}
+		switch ops[i].Kind {
+		case store.OpSet:
+			if err := raw(o.Key, o.Value); err != nil {
+				return err
+			}
+		case store.OpGet, store.OpDelete, store.OpIncrBy, store.OpHGet, store.OpHSet, store.OpHGetAll:
+		default:
+			return status.Errorf(codes.InvalidArgument, "unknown operation %s", o.Op)
+		}
+	}
+	results, err := b.exec(ctx, ops, request.Atomic)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		if ops[i].Kind == store.OpGet {
+			r = decoded(ops[i].Key, r)
+		}
+		res := &OperationResult{Found: r.Err == nil, Value: r.Value, Fields: r.Fields, Int: r.Int, Ttl: ttl(r.TTL)}
+		if r.Err != nil && r.Err != store.ErrNotFound {
+			res.Error = r.Err.Error()
+		}
+		response.Results = append(response.Results, res)
+	}
+	return nil
+}
+
+func (b *BatchService) exec(ctx context.Context, ops []store.Op, atomic bool) ([]store.Result, error) {
+	if len(ops) > MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "%d operations exceed the maximum of %d", len(ops), MaxBatchSize)
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	results, err := b.store.Exec(ctx, ops, atomic)
+	if err != nil {
+		return nil, storeError("batch", err)
+	}
+	return results, nil
+}
+
+// result of the operation of a key, a missing key is not an error
+func result(key string, field string, r store.Result) *Result {
+	res := &Result{Key: key, Field: field, Found: r.Err == nil, Value: r.Value, Ttl: ttl(r.TTL)}
+	if r.Err != nil && r.Err != store.ErrNotFound {
+		res.Error = r.Err.Error()
+	}
+	return res
+}
+
+// ttl returns the remaining seconds of a time to live, -1 when it does not expire
+func ttl(d time.Duration) int64 {
+	switch {
+	case d == store.NoExpiry:
+		return -1
+	case d <= 0:
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
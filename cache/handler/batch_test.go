@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"testing"
+)
+
+func TestBatchService(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	b := NewBatchService(s)
+	ctx := context.Background()
+
+	var set MSetResponse
+	err := b.MSet(ctx, &MSetRequest{Items: []*SetItem{{Key: "a", Value: "1"}, {Key: "b", Value: "2", Ttl: 60}}}, &set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Results) != 2 || set.Results[0].Error != "" || set.Results[1].Error != "" {
+		t.Fatalf("MSet() = %+v", set.Results)
+	}
+	if _, err := s.HSet(ctx, "h", map[string]string{"f": "v"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var get MGetResponse
+	if err := b.MGet(ctx, &MGetRequest{Keys: []string{"a", "b", "missing", "h"}}, &get); err != nil {
+		t.Fatal(err)
+	}
+	r := get.Results
+	if !r[0].Found || r[0].Value != "1" || r[0].Ttl != -1 {
+		t.Fatalf("MGet() a = %+v", r[0])
+	}
+	if !r[1].Found || r[1].Value != "2" || r[1].Ttl != 60 {
+		t.Fatalf("MGet() b = %+v", r[1])
+	}
+	if r[2].Found || r[2].Error != "" {
+		t.Fatalf("MGet() missing = %+v", r[2])
+	}
+	if r[3].Found || r[3].Error == "" {
+		t.Fatalf("MGet() hash = %+v, want an error", r[3])
+	}
+
+	var hget MHGetResponse
+	if err := b.MHGet(ctx, &MHGetRequest{Items: []*HGetItem{{Key: "h", Field: "f"}, {Key: "h", Field: "g"}}}, &hget); err != nil {
+		t.Fatal(err)
+	}
+	if !hget.Results[0].Found || hget.Results[0].Value != "v" || hget.Results[1].Found {
+		t.Fatalf("MHGet() = %+v", hget.Results)
+	}
+
+	var del MDeleteResponse
+	if err := b.MDelete(ctx, &MDeleteRequest{Keys: []string{"a", "missing"}}, &del); err != nil {
+		t.Fatal(err)
+	}
+	if del.Deleted != 1 || !del.Results[0].Found || del.Results[1].Found {
+		t.Fatalf("MDelete() = %d, %+v", del.Deleted, del.Results)
+	}
+
+	var pipe PipelineResponse
+	err = b.Pipeline(ctx, &PipelineRequest{Atomic: true, Operations: []*Operation{
+		{Op: "incrby", Key: "n", By: 5},
+		{Op: "get", Key: "n"},
+	}}, &pipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pipe.Results[0].Int != 5 || pipe.Results[1].Value != "5" {
+		t.Fatalf("Pipeline() = %+v, %+v", pipe.Results[0], pipe.Results[1])
+	}
+	if err := b.Pipeline(ctx, &PipelineRequest{Operations: []*Operation{{Op: "flushall"}}}, &PipelineResponse{}); err == nil {
+		t.Fatal("Pipeline() with an unknown operation succeeded")
+	}
+}
@@ -3,43 +3,76 @@ package handler
 import (
 	"context"
 	"fmt"
-	"github.com/redis/go-redis/v9"
+	"github.com/sparrow-community/app/cache/store"
 	"github.com/sparrow-community/protos/cache"
+	"go-micro.dev/v4/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"time"
 )
 
+// Cache reads the keys through the loaders of Loader when it is set
 type Cache struct {
-	Client *redis.Client
+	Store  store.Store
+	Loader *LoaderService
 }
 
+// Get fails with a go-micro NotFound error, code 404, on a missing key, where it used to return an empty
+// value, so a missing key is told apart from an empty value, Ttl is the unix time the key expires at, 0
+// when it does not expire, KeyService.Ttl returns the remaining time, the values of the value service are decoded
 func (c Cache) Get(ctx context.Context, in *cache.GetRequest, out *cache.GetResponse) error {
-	v, err := c.Client.Get(ctx, in.Key).Result()
-	if err != nil && err == redis.Nil {
+	if c.Loader != nil {
+		var response LoaderGetResponse
+		if err := c.Loader.Get(ctx, &LoaderGetRequest{Key: in.Key}, &response); err != nil {
+			return err
+		}
+		if !response.Found {
+			return errors.NotFound("cache.Get", "key %s not found", in.Key)
+		}
+		out.Key = in.Key
+		out.Value = response.Value
+		out.Ttl = expiresAt(store.NoExpiry)
+		if response.TtlMs >= 0 {
+			out.Ttl = expiresAt(time.Duration(response.TtlMs) * time.Millisecond)
+		}
 		return nil
+	}
+	v, d, err := c.Store.Get(ctx, in.Key)
+	if err == store.ErrNotFound {
+		return errors.NotFound("cache.Get", "key %s not found", in.Key)
 	} else if err != nil {
 		return err
 	}
-	dur, err := c.Client.TTL(ctx, in.Key).Result()
-	if err != nil {
+	if v, err = plain(in.Key, v); err != nil {
 		return err
 	}
 	out.Key = in.Key
 	out.Value = v
-	out.Ttl = time.Now().Add(dur).Unix()
+	out.Ttl = expiresAt(d)
 	return nil
 }
 
+// expiresAt returns the unix time a key expires at in d, 0 when it does not expire
+func expiresAt(d time.Duration) int64 {
+	if d == store.NoExpiry {
+		return 0
+	}
+	return time.Now().Add(d).Unix()
+}
+
 func (c Cache) Set(ctx context.Context, in *cache.SetRequest, out *cache.SetResponse) error {
-	ret, err := c.Client.Set(ctx, in.Key, in.Value, time.Duration(in.Ttl)*time.Second).Result()
-	if err != nil {
+	if err := raw(in.Key, in.Value); err != nil {
 		return err
 	}
-	out.Status = ret
+	if err := c.Store.Set(ctx, in.Key, in.Value, time.Duration(in.Ttl)*time.Second); err != nil {
+		return storeError("set", err)
+	}
+	out.Status = "OK"
 	return nil
 }
 
 func (c Cache) Delete(ctx context.Context, in *cache.DeleteRequest, out *cache.DeleteResponse) error {
-	ret, err := c.Client.Del(ctx, in.Key).Result()
+	ret, err := c.Store.Delete(ctx, in.Key)
 	if err != nil {
 		return err
 	}
@@ -48,7 +81,7 @@ func (c Cache) Delete(ctx context.Context, in *cache.DeleteRequest, out *cache.D
 }
 
 func (c Cache) Increment(ctx context.Context, in *cache.IncrementRequest, out *cache.IncrementResponse) error {
-	ret, err := c.Client.IncrBy(ctx, in.Key, in.Value).Result()
+	ret, err := c.Store.IncrBy(ctx, in.Key, in.Value)
 	if err != nil {
 		return err
 	}
@@ -58,7 +91,7 @@ func (c Cache) Increment(ctx context.Context, in *cache.IncrementRequest, out *c
 }
 
 func (c Cache) Decrement(ctx context.Context, in *cache.DecrementRequest, out *cache.DecrementResponse) error {
-	ret, err := c.Client.DecrBy(ctx, in.Key, in.Value).Result()
+	ret, err := c.Store.IncrBy(ctx, in.Key, -in.Value)
 	if err != nil {
 		return err
 	}
@@ -67,19 +100,32 @@ func (c Cache) Decrement(ctx context.Context, in *cache.DecrementRequest, out *c
 	return nil
 }
 
+// ListKeys returns every key, scanned by pages so the store is not blocked, a store of more than MaxListKeys keys
+// is paged through with KeyService.List instead
 func (c Cache) ListKeys(ctx context.Context, _ *cache.ListKeysRequest, out *cache.ListKeysResponse) error {
-	ret, err := c.Client.Keys(ctx, "*").Result()
-	if err != nil {
-		return err
+	cursor := ""
+	for {
+		keys, next, err := c.Store.Scan(ctx, cursor, "*", MaxPageSize)
+		if err != nil {
+			return err
+		}
+		if len(out.Keys)+len(keys) > MaxListKeys {
+			out.Keys = nil
+			return status.Errorf(codes.ResourceExhausted, "more than %d keys, page through them with KeyService.List", MaxListKeys)
+		}
+		out.Keys = append(out.Keys, keys...)
+		if next == "" {
+			return nil
+		}
+		cursor = next
 	}
-	out.Keys = ret
-	return nil
 }
 
+// HGet fails with a go-micro NotFound error, like Get, when the key or the field is missing
 func (c Cache) HGet(ctx context.Context, in *cache.HGetRequest, out *cache.HGetResponse) error {
-	ret, err := c.Client.HGet(ctx, in.Key, in.Field).Result()
-	if err != nil && err == redis.Nil {
-		return nil
+	ret, err := c.Store.HGet(ctx, in.Key, in.Field)
+	if err == store.ErrNotFound {
+		return errors.NotFound("cache.HGet", "field %s of key %s not found", in.Field, in.Key)
 	} else if err != nil {
 		return err
 	}
@@ -90,7 +136,7 @@ func (c Cache) HGet(ctx context.Context, in *cache.HGetRequest, out *cache.HGetR
 }
 
 func (c Cache) HSet(ctx context.Context, in *cache.HSetRequest, out *cache.HSetResponse) error {
-	ret, err := c.Client.HSet(ctx, in.Key, in.Field, in.Value).Result()
+	ret, err := c.Store.HSet(ctx, in.Key, map[string]string{in.Field: in.Value})
 	if err != nil {
 		return err
 	}
@@ -99,7 +145,7 @@ func (c Cache) HSet(ctx context.Context, in *cache.HSetRequest, out *cache.HSetR
 }
 
 func (c Cache) HSetMap(ctx context.Context, in *cache.HSetMapRequest, out *cache.HSetMapResponse) error {
-	ret, err := c.Client.HSet(ctx, in.Key, in.Value).Result()
+	ret, err := c.Store.HSet(ctx, in.Key, in.Value)
 	if err != nil {
 		return err
 	}
@@ -108,10 +154,8 @@ func (c Cache) HSetMap(ctx context.Context, in *cache.HSetMapRequest, out *cache
 }
 
 func (c Cache) HGetAll(ctx context.Context, in *cache.HGetAllRequest, out *cache.HGetAllResponse) error {
-	ret, err := c.Client.HGetAll(ctx, in.Key).Result()
-	if err != nil && err == redis.Nil {
-		return nil
-	} else if err != nil {
+	ret, err := c.Store.HGetAll(ctx, in.Key)
+	if err != nil {
 		return err
 	}
 	out.Value = ret
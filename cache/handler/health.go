@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// HealthTimeout longest wait of the store by a health check
+const HealthTimeout = 2 * time.Second
+
+// Health serves the grpc health checking protocol, grpc.health.v1.Health/Check, the cache is serving while
+// its store is reachable
+type Health struct {
+	store   store.Store
+	service string
+}
+
+// NewHealth reports the health of the empty service and of service
+func NewHealth(s store.Store, service string) *Health {
+	return &Health{store: s, service: service}
+}
+
+func (h *Health) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, out *grpc_health_v1.HealthCheckResponse) error {
+	if in.Service != "" && in.Service != h.service {
+		return status.Errorf(codes.NotFound, "unknown service %s", in.Service)
+	}
+	ctx, cancel := context.WithTimeout(ctx, HealthTimeout)
+	defer cancel()
+	if err := h.store.Ping(ctx); err != nil {
+		logger.Warnf("%s store unreachable: %v", h.store, err)
+		out.Status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		return nil
+	}
+	out.Status = grpc_health_v1.HealthCheckResponse_SERVING
+	return nil
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// unreachable is a store whose server does not answer
+type unreachable struct {
+	store.Store
+}
+
+func (unreachable) Ping(context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthCheck(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, service := range []string{"", "cache"} {
+		var out grpc_health_v1.HealthCheckResponse
+		err := NewHealth(s, "cache").Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service}, &out)
+		if err != nil || out.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Fatalf("Check(%q) = %v, %v, want serving", service, out.Status, err)
+		}
+	}
+
+	err := NewHealth(s, "cache").Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "other"}, &grpc_health_v1.HealthCheckResponse{})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Check() unknown service error = %v, want not found", err)
+	}
+
+	var out grpc_health_v1.HealthCheckResponse
+	err = NewHealth(unreachable{Store: s}, "cache").Check(ctx, &grpc_health_v1.HealthCheckRequest{}, &out)
+	if err != nil || out.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("Check() unreachable store = %v, %v, want not serving", out.Status, err)
+	}
+}
@@ -0,0 +1,464 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/sparrow-community/app/cache/namespace"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/protos/cache"
+	"go-micro.dev/v4/errors"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxBodySize of a request body over http
+	MaxBodySize = 8 << 20
+	// AdminScanLimit keys scanned at most to list the namespaces
+	AdminScanLimit = 100000
+	// AdminItems elements, members, fields or entries of a key returned at most by the admin api
+	AdminItems = 100
+)
+
+// Admin of the keys of every namespace, Store is the store before the namespaces, the admin api is
+// disabled without a Token
+type Admin struct {
+	Store      store.Store
+	Token      string
+	Namespaces bool
+}
+
+// Credential bearer Token of the http api calls of Service, they run as Service, in its namespace
+type Credential struct {
+	Service string `json:"service"`
+	Token   string `json:"token"`
+}
+
+// HttpHandler serves the Cache rpcs over http under Prefix:
+//
+//	GET    {prefix}/api/keys                          list the keys, ListKeys
+//	GET    {prefix}/api/keys/{key}                    read a value, Get
+//	PUT    {prefix}/api/keys/{key}                    set a value, body {"value":"","ttl":0}, Set
+//	DELETE {prefix}/api/keys/{key}                    delete a key, Delete
+//	POST   {prefix}/api/increment/{key}               add to a value, body {"value":1}, Increment
+//	POST   {prefix}/api/decrement/{key}               subtract from a value, body {"value":1}, Decrement
+//	GET    {prefix}/api/hashes/{key}?field=           read a field, HGet, or every field without one, HGetAll
+//	PUT    {prefix}/api/hashes/{key}?field=           set a field, body {"value":""}, HSet, or fields without
+//	                                                  one, body {"value":{}}, HSetMap
+//	GET    {prefix}/api/admin/namespaces              list the namespaces
+//	GET    {prefix}/api/admin/keys?namespace=&match=&cursor=  browse the keys of every namespace
+//	GET    {prefix}/api/admin/keys/{key}              inspect a key
+//	DELETE {prefix}/api/admin/keys/{key}              delete a key
+//	GET    {prefix}/ui/                               admin web ui
+//
+// the Authorization header carries a bearer token, the token of a credential runs the cache routes as its
+// service, they read the namespace of the namespace query parameter when the service may read it, like the
+// Cache-Namespace metadata of a grpc call, the admin token authorizes the admin routes, the caller of
+// a request never comes from its headers
+type HttpHandler struct {
+	Prefix      string
+	cache       *Cache
+	credentials []Credential
+	admin       Admin
+	ui          http.Handler
+}
+
+// NewHttpHandler serves c, the cache of the grpc server, to the callers of credentials
+func NewHttpHandler(prefix string, c *Cache, credentials []Credential, admin Admin, ui fs.FS) *HttpHandler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return &HttpHandler{
+		Prefix:      prefix,
+		cache:       c,
+		credentials: credentials,
+		admin:       admin,
+		ui:          http.StripPrefix(prefix+"/ui/", http.FileServer(http.FS(ui))),
+	}
+}
+
+func (h *HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, h.Prefix)
+	if p == "" || p == "/" || p == "/ui" {
+		http.Redirect(w, r, h.Prefix+"/ui/", http.StatusFound)
+		return
+	}
+	if strings.HasPrefix(p, "/ui/") {
+		h.ui.ServeHTTP(w, r)
+		return
+	}
+
+	route, key, _ := strings.Cut(strings.TrimPrefix(p, "/api/"), "/")
+	if route == "admin" {
+		if err := h.serveAdmin(r.Context(), w, r, key); err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+	ctx, err := h.caller(r)
+	switch {
+	case err != nil:
+	case route == "keys" && key == "" && r.Method == http.MethodGet:
+		var response cache.ListKeysResponse
+		if err = h.cache.ListKeys(ctx, &cache.ListKeysRequest{}, &response); err == nil {
+			err = writeJSON(w, &response)
+		}
+	case route == "keys" && key != "" && r.Method == http.MethodGet:
+		var response cache.GetResponse
+		if err = h.cache.Get(ctx, &cache.GetRequest{Key: key}, &response); err == nil {
+			err = writeJSON(w, &response)
+		}
+	case route == "keys" && key != "" && r.Method == http.MethodPut:
+		var request cache.SetRequest
+		if err = readJSON(w, r, &request); err == nil {
+			request.Key = key
+			var response cache.SetResponse
+			if err = h.cache.Set(ctx, &request, &response); err == nil {
+				err = writeJSON(w, &response)
+			}
+		}
+	case route == "keys" && key != "" && r.Method == http.MethodDelete:
+		var response cache.DeleteResponse
+		if err = h.cache.Delete(ctx, &cache.DeleteRequest{Key: key}, &response); err == nil {
+			err = writeJSON(w, &response)
+		}
+	case route == "increment" && key != "" && r.Method == http.MethodPost:
+		var request cache.IncrementRequest
+		if err = readJSON(w, r, &request); err == nil {
+			request.Key = key
+			var response cache.IncrementResponse
+			if err = h.cache.Increment(ctx, &request, &response); err == nil {
+				err = writeJSON(w, &response)
+			}
+		}
+	case route == "decrement" && key != "" && r.Method == http.MethodPost:
+		var request cache.DecrementRequest
+		if err = readJSON(w, r, &request); err == nil {
+			request.Key = key
+			var response cache.DecrementResponse
+			if err = h.cache.Decrement(ctx, &request, &response); err == nil {
+				err = writeJSON(w, &response)
+			}
+		}
+	case route == "hashes" && key != "" && r.Method == http.MethodGet:
+		err = h.hget(ctx, w, r, key)
+	case route == "hashes" && key != "" && r.Method == http.MethodPut:
+		err = h.hset(ctx, w, r, key)
+	default:
+		err = status.Errorf(codes.NotFound, "%s %s not found", r.Method, r.URL.Path)
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *HttpHandler) hget(ctx context.Context, w http.ResponseWriter, r *http.Request, key string) error {
+	field, ok := r.URL.Query()["field"]
+	if !ok {
+		var response cache.HGetAllResponse
+		if err := h.cache.HGetAll(ctx, &cache.HGetAllRequest{Key: key}, &response); err != nil {
+			return err
+		}
+		return writeJSON(w, &response)
+	}
+	var response cache.HGetResponse
+	if err := h.cache.HGet(ctx, &cache.HGetRequest{Key: key, Field: field[0]}, &response); err != nil {
+		return err
+	}
+	return writeJSON(w, &response)
+}
+
+func (h *HttpHandler) hset(ctx context.Context, w http.ResponseWriter, r *http.Request, key string) error {
+	field, ok := r.URL.Query()["field"]
+	if !ok {
+		var request cache.HSetMapRequest
+		if err := readJSON(w, r, &request); err != nil {
+			return err
+		}
+		request.Key = key
+		var response cache.HSetMapResponse
+		if err := h.cache.HSetMap(ctx, &request, &response); err != nil {
+			return err
+		}
+		return writeJSON(w, &response)
+	}
+	var request cache.HSetRequest
+	if err := readJSON(w, r, &request); err != nil {
+		return err
+	}
+	request.Key = key
+	request.Field = field[0]
+	var response cache.HSetResponse
+	if err := h.cache.HSet(ctx, &request, &response); err != nil {
+		return err
+	}
+	return writeJSON(w, &response)
+}
+
+type AdminNamespacesResponse struct {
+	Namespaces []string `json:"namespaces"`
+	// Truncated is set when the keys were not all scanned
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type AdminKeysResponse struct {
+	Keys []string `json:"keys"`
+	// Cursor of the next page, empty after the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// AdminKey kind, time to live and first items of a key, the data of a string value is Value when it is
+// valid utf-8, else Data
+type AdminKey struct {
+	Key         string              `json:"key"`
+	Kind        string              `json:"kind"`
+	TtlMs       int64               `json:"ttl_ms"`
+	Size        int64               `json:"size"`
+	Value       *string             `json:"value,omitempty"`
+	Data        []byte              `json:"data,omitempty"`
+	ContentType string              `json:"content_type,omitempty"`
+	Compression string              `json:"compression,omitempty"`
+	CreatedMs   int64               `json:"created_ms,omitempty"`
+	Fields      map[string]string   `json:"fields,omitempty"`
+	Elements    []string            `json:"elements,omitempty"`
+	Members     []store.ZMember     `json:"members,omitempty"`
+	Entries     []store.StreamEntry `json:"entries,omitempty"`
+}
+
+// caller returns the context of a cache request run as the service of its credential
+func (h *HttpHandler) caller(r *http.Request) (context.Context, error) {
+	token := bearer(r)
+	service := ""
+	for _, c := range h.credentials {
+		if c.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1 {
+			service = c.Service
+		}
+	}
+	if service == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+	}
+	ctx := namespace.WithCaller(r.Context(), service)
+	if ns := r.URL.Query().Get("namespace"); ns != "" {
+		ctx = metadata.NewContext(ctx, metadata.Metadata{namespace.NamespaceKey: ns})
+	}
+	return ctx, nil
+}
+
+func bearer(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func (h *HttpHandler) serveAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request, p string) error {
+	if h.admin.Token == "" {
+		return status.Errorf(codes.PermissionDenied, "the admin api is disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(bearer(r)), []byte(h.admin.Token)) != 1 {
+		return status.Errorf(codes.Unauthenticated, "invalid admin token")
+	}
+	route, key, _ := strings.Cut(p, "/")
+	switch {
+	case route == "namespaces" && key == "" && r.Method == http.MethodGet:
+		return h.namespaces(ctx, w)
+	case route == "keys" && key == "" && r.Method == http.MethodGet:
+		return h.browse(ctx, w, r)
+	case route == "keys" && key != "" && r.Method == http.MethodGet:
+		return h.inspect(ctx, w, key)
+	case route == "keys" && key != "" && r.Method == http.MethodDelete:
+		if _, err := h.admin.Store.Delete(ctx, key); err != nil {
+			return storeError("delete", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return status.Errorf(codes.NotFound, "%s %s not found", r.Method, r.URL.Path)
+}
+
+// namespaces lists the namespaces of the first AdminScanLimit keys
+func (h *HttpHandler) namespaces(ctx context.Context, w http.ResponseWriter) error {
+	response := AdminNamespacesResponse{Namespaces: []string{}}
+	if !h.admin.Namespaces {
+		return writeJSON(w, &response)
+	}
+	seen := map[string]bool{}
+	cursor := ""
+	for scanned := 0; ; {
+		keys, next, err := h.admin.Store.Scan(ctx, cursor, "*", MaxPageSize)
+		if err != nil {
+			return storeError("scan", err)
+		}
+		for _, k := range keys {
+			if ns, _, ok := strings.Cut(k, namespace.Separator); ok {
+				seen[ns] = true
+			}
+		}
+		scanned += len(keys)
+		if next == "" {
+			break
+		}
+		if scanned >= AdminScanLimit {
+			response.Truncated = true
+			break
+		}
+		cursor = next
+	}
+	for ns := range seen {
+		response.Namespaces = append(response.Namespaces, ns)
+	}
+	sort.Strings(response.Namespaces)
+	return writeJSON(w, &response)
+}
+
+// browse returns a page of the keys matching match in namespace
+func (h *HttpHandler) browse(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	pattern := q.Get("match")
+	if pattern == "" {
+		pattern = "*"
+	}
+	if ns := q.Get("namespace"); ns != "" {
+		pattern = store.Escape(ns+namespace.Separator) + pattern
+	}
+	count, _ := strconv.ParseInt(q.Get("count"), 10, 64)
+	keys, next, err := h.admin.Store.Scan(ctx, q.Get("cursor"), pattern, pageSize(count))
+	if err == store.ErrInvalidCursor {
+		return status.Errorf(codes.InvalidArgument, "invalid cursor")
+	}
+	if err != nil {
+		return storeError("scan", err)
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return writeJSON(w, &AdminKeysResponse{Keys: keys, Cursor: next})
+}
+
+// inspect reads key as every kind in turn until its kind holds
+func (h *HttpHandler) inspect(ctx context.Context, w http.ResponseWriter, key string) error {
+	s := h.admin.Store
+	d, err := s.TTL(ctx, key)
+	if err != nil {
+		return storeError("ttl", err)
+	}
+	if d == store.Missing {
+		return status.Errorf(codes.NotFound, "key %s not found", key)
+	}
+	k := AdminKey{Key: key, TtlMs: ttlMs(d)}
+	if k.Size, err = s.Size(ctx, key); err != nil {
+		return storeError("size", err)
+	}
+	for _, read := range []func() error{
+		func() error {
+			v, _, err := s.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			data, m, err := decodeValue(v, MaxDecodedSize)
+			if err != nil {
+				return status.Errorf(codes.DataLoss, "value of %s is corrupted: %s", key, err)
+			}
+			k.Kind = "string"
+			if utf8.Valid(data) {
+				value := string(data)
+				k.Value = &value
+			} else {
+				k.Data = data
+			}
+			if m != nil {
+				k.ContentType, k.Compression, k.CreatedMs = m.ContentType, m.Compression, m.Created
+			}
+			return nil
+		},
+		func() error {
+			fields, err := s.HGetAll(ctx, key)
+			k.Kind, k.Fields = "hash", fields
+			return err
+		},
+		func() error {
+			elements, err := s.LRange(ctx, key, 0, AdminItems-1)
+			k.Kind, k.Elements = "list", elements
+			return err
+		},
+		func() error {
+			members, err := s.SMembers(ctx, key)
+			if len(members) > AdminItems {
+				members = members[:AdminItems]
+			}
+			k.Kind, k.Elements = "set", members
+			return err
+		},
+		func() error {
+			members, err := s.ZRangeByScore(ctx, key, math.Inf(-1), math.Inf(1), 0, AdminItems)
+			k.Kind, k.Members = "zset", members
+			return err
+		},
+		func() error {
+			entries, err := s.XRead(ctx, key, "0", AdminItems, 0)
+			k.Kind, k.Entries = "stream", entries
+			return err
+		},
+	} {
+		err := read()
+		if err == store.ErrWrongType {
+			continue
+		}
+		if err == store.ErrNotFound {
+			return status.Errorf(codes.NotFound, "key %s not found", key)
+		}
+		if err != nil {
+			return storeError("read", err)
+		}
+		return writeJSON(w, &k)
+	}
+	return status.Errorf(codes.FailedPrecondition, "key %s has an unknown kind", key)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize)).Decode(v); err != nil && err != io.EOF {
+		return status.Errorf(codes.InvalidArgument, "read body error %s", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the status of err with its http status code, a go-micro error keeps its code
+func writeError(w http.ResponseWriter, err error) {
+	if e, ok := err.(*errors.Error); ok && e.Code > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(e.Code))
+		_ = json.NewEncoder(w).Encode(map[string]string{"code": e.Status, "message": e.Detail})
+		return
+	}
+	s, _ := status.FromError(err)
+	code := http.StatusInternalServerError
+	switch s.Code() {
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.AlreadyExists:
+		code = http.StatusConflict
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.Unimplemented:
+		code = http.StatusNotImplemented
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"code": s.Code().String(), "message": s.Message()})
+}
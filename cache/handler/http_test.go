@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/sparrow-community/app/cache/namespace"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/protos/cache"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHttpHandler(t *testing.T) {
+	m := store.NewMemory(0, 0)
+	defer m.Close()
+	ns := namespace.New(m, namespace.Options{
+		Tenants:  []namespace.Tenant{{Service: "config", Read: []string{"orders"}}},
+		Interval: time.Minute,
+	})
+	defer ns.Close()
+	admin := Admin{Store: ns.Unscoped(), Token: "secret", Namespaces: true}
+	credentials := []Credential{{Service: "orders", Token: "orders-token"}, {Service: "config", Token: "config-token"}}
+	h := NewHttpHandler("/cache", &Cache{Store: ns}, credentials, admin, fstest.MapFS{"index.html": {Data: []byte("ui")}})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	do := func(method string, p string, body string, header ...string) (int, string) {
+		req, err := http.NewRequest(method, srv.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var b strings.Builder
+		_, _ = io.Copy(&b, resp.Body)
+		return resp.StatusCode, b.String()
+	}
+	bearer := []string{"Authorization", "Bearer secret"}
+	caller := []string{"Authorization", "Bearer orders-token"}
+
+	if code, _ := do(http.MethodGet, "/cache/api/keys/a", ""); code != http.StatusUnauthorized {
+		t.Errorf("get without token status %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/cache/api/keys/a", "", namespace.FromServiceKey, "orders"); code != http.StatusUnauthorized {
+		t.Errorf("get with a caller header status %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/cache/api/keys/a", "", bearer...); code != http.StatusUnauthorized {
+		t.Errorf("get with the admin token status %d", code)
+	}
+	if code, body := do(http.MethodPut, "/cache/api/keys/order/1", `{"value":"paid","ttl":60}`, caller...); code != http.StatusOK {
+		t.Fatalf("set status %d %s", code, body)
+	}
+	code, body := do(http.MethodGet, "/cache/api/keys/order/1", "", caller...)
+	var got cache.GetResponse
+	if err := json.Unmarshal([]byte(body), &got); code != http.StatusOK || err != nil || got.Value != "paid" {
+		t.Fatalf("get status %d %s", code, body)
+	}
+	// ttl is the unix time the key expires at
+	if now := time.Now().Unix(); got.Ttl < now+59 || got.Ttl > now+61 {
+		t.Errorf("get ttl %d, want about %d", got.Ttl, now+60)
+	}
+	if code, _ := do(http.MethodGet, "/cache/api/keys/missing", "", caller...); code != http.StatusNotFound {
+		t.Errorf("get missing status %d", code)
+	}
+	code, body = do(http.MethodPost, "/cache/api/increment/count", `{"value":3}`, caller...)
+	var incr cache.IncrementResponse
+	if err := json.Unmarshal([]byte(body), &incr); code != http.StatusOK || err != nil || incr.Value != 3 {
+		t.Fatalf("increment status %d %s", code, body)
+	}
+	if code, body := do(http.MethodPut, "/cache/api/hashes/user?field=name", `{"value":"ada"}`, caller...); code != http.StatusOK {
+		t.Fatalf("hset status %d %s", code, body)
+	}
+	code, body = do(http.MethodGet, "/cache/api/hashes/user", "", caller...)
+	var all cache.HGetAllResponse
+	if err := json.Unmarshal([]byte(body), &all); code != http.StatusOK || err != nil || all.Value["name"] != "ada" {
+		t.Fatalf("hgetall status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/cache/api/hashes/user?field=age", "", caller...); code != http.StatusNotFound {
+		t.Errorf("hget missing field status %d %s", code, body)
+	}
+	if code, body := do(http.MethodPut, "/cache/api/keys/a", `{"value":`, caller...); code != http.StatusBadRequest {
+		t.Errorf("invalid body status %d %s", code, body)
+	}
+	other := []string{"Authorization", "Bearer config-token"}
+	if code, body := do(http.MethodGet, "/cache/api/keys/order/1", "", other...); code != http.StatusNotFound {
+		t.Errorf("get of another namespace key status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/cache/api/keys/order/1?namespace=orders", "", other...); code != http.StatusOK || !strings.Contains(body, "paid") {
+		t.Errorf("get of a readable namespace status %d %s", code, body)
+	}
+	if code, body := do(http.MethodPut, "/cache/api/keys/order/1?namespace=orders", `{"value":"x"}`, other...); code != http.StatusForbidden {
+		t.Errorf("set of another namespace status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/cache/api/keys/a?namespace=config", "", caller...); code != http.StatusForbidden {
+		t.Errorf("get of an unreadable namespace status %d %s", code, body)
+	}
+
+	if code, _ := do(http.MethodGet, "/cache/api/admin/namespaces", ""); code != http.StatusUnauthorized {
+		t.Errorf("admin without token status %d", code)
+	}
+	if code, body := do(http.MethodGet, "/cache/api/admin/namespaces", "", bearer...); code != http.StatusOK || !strings.Contains(body, `"orders"`) {
+		t.Errorf("namespaces status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/cache/api/admin/keys?namespace=orders&match=order*", "", bearer...); code != http.StatusOK || !strings.Contains(body, `"orders:order/1"`) || strings.Contains(body, "count") {
+		t.Errorf("browse status %d %s", code, body)
+	}
+	code, body = do(http.MethodGet, "/cache/api/admin/keys/orders:user", "", bearer...)
+	var k AdminKey
+	if err := json.Unmarshal([]byte(body), &k); code != http.StatusOK || err != nil || k.Kind != "hash" || k.Fields["name"] != "ada" || k.TtlMs != -1 {
+		t.Errorf("inspect status %d %s", code, body)
+	}
+	if code, body := do(http.MethodDelete, "/cache/api/admin/keys/orders:order/1", "", bearer...); code != http.StatusNoContent {
+		t.Errorf("admin delete status %d %s", code, body)
+	}
+	if code, _ := do(http.MethodGet, "/cache/api/keys/order/1", "", caller...); code != http.StatusNotFound {
+		t.Errorf("get deleted status %d", code)
+	}
+
+	if code, body := do(http.MethodGet, "/cache/ui/", ""); code != http.StatusOK || body != "ui" {
+		t.Errorf("ui status %d %s", code, body)
+	}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const (
+	// DefaultPageSize keys of a page without a size
+	DefaultPageSize = 100
+	// MaxPageSize keys of the largest page
+	MaxPageSize = 1000
+	// MaxListKeys keys returned by Cache.ListKeys, List pages through more keys
+	MaxListKeys = 10000
+)
+
+type ListRequest struct {
+	// Pattern redis glob of the keys, empty matches every key
+	Pattern string `json:"pattern"`
+	// Cursor of the page, empty for the first one
+	Cursor string `json:"cursor"`
+	// Size of the page, a hint the page may exceed
+	Size int64 `json:"size"`
+}
+
+type ListResponse struct {
+	Keys []string `json:"keys"`
+	// Cursor of the next page, empty after the last one
+	Cursor string `json:"cursor"`
+}
+
+type ExportRequest struct {
+	Pattern string `json:"pattern"`
+	// Size of the batches of keys
+	Size int64 `json:"size"`
+}
+
+type ExportResponse struct {
+	Keys []string `json:"keys"`
+}
+
+type ExpireRequest struct {
+	Key string `json:"key"`
+	// TtlMs time to live in milliseconds, Delete removes a key
+	TtlMs int64 `json:"ttl_ms"`
+}
+
+type ExpireResponse struct {
+	// Found is false when the key does not exist
+	Found bool `json:"found"`
+}
+
+type PersistRequest struct {
+	Key string `json:"key"`
+}
+
+type PersistResponse struct {
+	// Persisted is false when the key does not exist or does not expire
+	Persisted bool `json:"persisted"`
+}
+
+type TTLRequest struct {
+	Key string `json:"key"`
+}
+
+type TTLResponse struct {
+	Found bool `json:"found"`
+	// TtlMs remaining milliseconds, -1 when the key does not expire, -2 when it does not exist
+	TtlMs int64 `json:"ttl_ms"`
+}
+
+// KeyService pages through the keys without blocking the store and manages their time to live, it has no proto,
+// its callers use the application/grpc+json codec
+type KeyService struct {
+	store store.Store
+}
+
+func NewKeyService(s store.Store) *KeyService {
+	return &KeyService{store: s}
+}
+
+// List returns a page of the keys matching the pattern, keys added or removed during the paging may be missed
+func (k *KeyService) List(ctx context.Context, request *ListRequest, response *ListResponse) error {
+	keys, next, err := k.store.Scan(ctx, request.Cursor, request.Pattern, pageSize(request.Size))
+	if err == store.ErrInvalidCursor {
+		return status.Errorf(codes.InvalidArgument, "invalid cursor %s", request.Cursor)
+	}
+	if err != nil {
+		return storeError("scan keys", err)
+	}
+	response.Keys = keys
+	response.Cursor = next
+	return nil
+}
+
+// Export streams every key matching the pattern by batches, the stream receives one ExportRequest
+func (k *KeyService) Export(ctx context.Context, stream server.Stream) error {
+	defer stream.Close()
+	var request ExportRequest
+	if err := stream.Recv(&request); err != nil {
+		return err
+	}
+	cursor := ""
+	for {
+		keys, next, err := k.store.Scan(ctx, cursor, request.Pattern, pageSize(request.Size))
+		if err != nil {
+			return storeError("scan keys", err)
+		}
+		if len(keys) > 0 {
+			if err := stream.Send(&ExportResponse{Keys: keys}); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Expire sets the time to live of a key of any kind
+func (k *KeyService) Expire(ctx context.Context, request *ExpireRequest, response *ExpireResponse) error {
+	if request.TtlMs <= 0 {
+		return status.Errorf(codes.InvalidArgument, "ttl_ms must be positive")
+	}
+	ok, err := k.store.Expire(ctx, request.Key, time.Duration(request.TtlMs)*time.Millisecond)
+	if err != nil {
+		return storeError("expire", err)
+	}
+	response.Found = ok
+	return nil
+}
+
+// Persist removes the time to live of a key, it never expires after
+func (k *KeyService) Persist(ctx context.Context, request *PersistRequest, response *PersistResponse) error {
+	ok, err := k.store.Persist(ctx, request.Key)
+	if err != nil {
+		return storeError("persist", err)
+	}
+	response.Persisted = ok
+	return nil
+}
+
+func (k *KeyService) TTL(ctx context.Context, request *TTLRequest, response *TTLResponse) error {
+	d, err := k.store.TTL(ctx, request.Key)
+	if err != nil {
+		return storeError("ttl", err)
+	}
+	response.Found = d != store.Missing
+	response.TtlMs = ttlMs(d)
+	return nil
+}
+
+// ttlMs returns the remaining milliseconds of a time to live, -1 when it does not expire, -2 for a missing key
+func ttlMs(d time.Duration) int64 {
+	switch {
+	case d == store.NoExpiry:
+		return -1
+	case d == store.Missing:
+		return -2
+	case d <= 0:
+		return 0
+	}
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// storeError keeps the status of the errors of a namespaced store and maps the store errors to a status
+func storeError(op string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch err {
+	case store.ErrWrongType, store.ErrNotInteger:
+		return status.Errorf(codes.FailedPrecondition, "%s error %s", op, err)
+	case store.ErrStreamID, store.ErrTooLarge:
+		return status.Errorf(codes.InvalidArgument, "%s error %s", op, err)
+	case store.ErrGroupExists:
+		return status.Errorf(codes.AlreadyExists, "%s error %s", op, err)
+	case store.ErrNoGroup:
+		return status.Errorf(codes.NotFound, "%s error %s", op, err)
+	}
+	return status.Errorf(codes.Internal, "%s error %s", op, err)
+}
+
+func pageSize(size int64) int64 {
+	switch {
+	case size <= 0:
+		return DefaultPageSize
+	case size > MaxPageSize:
+		return MaxPageSize
+	}
+	return size
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/protos/cache"
+	"go-micro.dev/v4/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+)
+
+// exportStream receives one request and records the sent responses
+type exportStream struct {
+	request *ExportRequest
+	sent    []*ExportResponse
+}
+
+func (s *exportStream) Context() context.Context { return context.Background() }
+func (s *exportStream) Request() server.Request  { return nil }
+func (s *exportStream) Error() error             { return nil }
+func (s *exportStream) Close() error             { return nil }
+
+func (s *exportStream) Send(m interface{}) error {
+	s.sent = append(s.sent, m.(*ExportResponse))
+	return nil
+}
+
+func (s *exportStream) Recv(m interface{}) error {
+	if s.request == nil {
+		return io.EOF
+	}
+	data, _ := json.Marshal(s.request)
+	s.request = nil
+	return json.Unmarshal(data, m)
+}
+
+func newKeys(t *testing.T, n int) store.Store {
+	s := store.NewMemory(0, 0)
+	for i := 0; i < n; i++ {
+		if err := s.Set(context.Background(), fmt.Sprintf("key:%03d", i), "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Set(context.Background(), "other", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestKeyServiceList(t *testing.T) {
+	s := newKeys(t, 25)
+	defer s.Close()
+	k := NewKeyService(s)
+
+	var keys []string
+	request := &ListRequest{Pattern: "key:*", Size: 10}
+	for pages := 1; ; pages++ {
+		var response ListResponse
+		if err := k.List(context.Background(), request, &response); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, response.Keys...)
+		if response.Cursor == "" {
+			if pages != 3 {
+				t.Fatalf("List() pages = %d, want 3", pages)
+			}
+			break
+		}
+		request.Cursor = response.Cursor
+	}
+	if len(keys) != 25 || keys[0] != "key:000" || keys[24] != "key:024" {
+		t.Fatalf("List() keys = %v", keys)
+	}
+
+	err := k.List(context.Background(), &ListRequest{Cursor: "bad"}, &ListResponse{})
+	if err == nil {
+		t.Fatal("List() with an invalid cursor succeeded")
+	}
+}
+
+func TestKeyServiceExport(t *testing.T) {
+	s := newKeys(t, 5)
+	defer s.Close()
+	stream := &exportStream{request: &ExportRequest{Pattern: "key:*", Size: 2}}
+	if err := NewKeyService(s).Export(context.Background(), stream); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, r := range stream.sent {
+		n += len(r.Keys)
+	}
+	if len(stream.sent) != 3 || n != 5 {
+		t.Fatalf("Export() sent %d batches of %d keys, want 3 of 5", len(stream.sent), n)
+	}
+}
+
+func TestCacheListKeys(t *testing.T) {
+	s := newKeys(t, 1500)
+	defer s.Close()
+	var response cache.ListKeysResponse
+	if err := (Cache{Store: s}).ListKeys(context.Background(), &cache.ListKeysRequest{}, &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Keys) != 1501 {
+		t.Fatalf("ListKeys() = %d keys, want 1501", len(response.Keys))
+	}
+
+	s = newKeys(t, MaxListKeys)
+	defer s.Close()
+	err := (Cache{Store: s}).ListKeys(context.Background(), &cache.ListKeysRequest{}, &cache.ListKeysResponse{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("ListKeys() of %d keys error = %v, want resource exhausted", MaxListKeys+1, err)
+	}
+}
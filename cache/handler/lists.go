@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// MaxPopTimeout longest wait of a blocking pop
+const MaxPopTimeout = time.Minute
+
+type ListPushRequest struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+	// Left pushes at the head, one value after the other, instead of the tail
+	Left bool `json:"left"`
+}
+
+type ListPushResponse struct {
+	Length int64 `json:"length"`
+}
+
+type ListPopRequest struct {
+	Key string `json:"key"`
+	// Keys other lists a blocking pop tries after Key
+	Keys []string `json:"keys"`
+	// Left pops the head instead of the tail
+	Left bool `json:"left"`
+	// TimeoutMs time to wait for a value in milliseconds, 0 does not wait
+	TimeoutMs int64 `json:"timeout_ms"`
+}
+
+type ListPopResponse struct {
+	// Found is false when every list is empty
+	Found bool   `json:"found"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+type ListRangeRequest struct {
+	Key string `json:"key"`
+	// Start, Stop indexes of the first and the last values, negative indexes count from the tail
+	Start int64 `json:"start"`
+	Stop  int64 `json:"stop"`
+}
+
+type ListRangeResponse struct {
+	Values []string `json:"values"`
+}
+
+// ListService pushes and pops the values of lists, like queues, over the application/grpc+json codec
+type ListService struct {
+	lists store.Lists
+}
+
+func NewListService(l store.Lists) *ListService {
+	return &ListService{lists: l}
+}
+
+func (l *ListService) Push(ctx context.Context, request *ListPushRequest, response *ListPushResponse) error {
+	push := l.lists.RPush
+	if request.Left {
+		push = l.lists.LPush
+	}
+	n, err := push(ctx, request.Key, request.Values...)
+	if err != nil {
+		return storeError("push", err)
+	}
+	response.Length = n
+	return nil
+}
+
+// Pop waits up to the timeout for a value of one of the lists
+func (l *ListService) Pop(ctx context.Context, request *ListPopRequest, response *ListPopResponse) error {
+	keys := append([]string{request.Key}, request.Keys...)
+	for _, k := range keys {
+		if k == "" {
+			return status.Errorf(codes.InvalidArgument, "list key is empty")
+		}
+	}
+	timeout := time.Duration(request.TimeoutMs) * time.Millisecond
+	if timeout > MaxPopTimeout {
+		timeout = MaxPopTimeout
+	}
+	pop := l.lists.BRPop
+	if request.Left {
+		pop = l.lists.BLPop
+	}
+	key, v, err := pop(ctx, timeout, keys...)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return storeError("pop", err)
+	}
+	response.Found = true
+	response.Key = key
+	response.Value = v
+	return nil
+}
+
+func (l *ListService) Range(ctx context.Context, request *ListRangeRequest, response *ListRangeResponse) error {
+	values, err := l.lists.LRange(ctx, request.Key, request.Start, request.Stop)
+	if err != nil {
+		return storeError("range", err)
+	}
+	response.Values = values
+	return nil
+}
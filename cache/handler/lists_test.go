@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestListServicePop(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	l := NewListService(s)
+	ctx := context.Background()
+
+	var empty ListPopResponse
+	if err := l.Pop(ctx, &ListPopRequest{Key: "jobs"}, &empty); err != nil || empty.Found {
+		t.Fatalf("Pop() empty = %+v, %v", empty, err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = l.Push(ctx, &ListPushRequest{Key: "urgent", Values: []string{"a", "b"}}, &ListPushResponse{})
+	}()
+	var popped ListPopResponse
+	if err := l.Pop(ctx, &ListPopRequest{Key: "jobs", Keys: []string{"urgent"}, Left: true, TimeoutMs: 1000}, &popped); err != nil {
+		t.Fatal(err)
+	}
+	if !popped.Found || popped.Key != "urgent" || popped.Value != "a" {
+		t.Fatalf("Pop() waiting = %+v, want a of urgent", popped)
+	}
+
+	if _, err := s.SAdd(ctx, "seen", "a"); err != nil {
+		t.Fatal(err)
+	}
+	err := l.Push(ctx, &ListPushRequest{Key: "seen", Values: []string{"a"}}, &ListPushResponse{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Push() to a set error = %v, want failed precondition", err)
+	}
+}
+
+func TestSortedSetServiceRank(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	z := NewSortedSetService(s)
+	ctx := context.Background()
+
+	request := &SortedSetAddRequest{Key: "scores", Members: []*ScoredMember{{"ann", 30}, {"bob", 10}, {"eve", 20}}}
+	if err := z.Add(ctx, request, &SortedSetAddResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	var top SortedSetRankResponse
+	if err := z.Rank(ctx, &SortedSetRankRequest{Key: "scores", Member: "ann", Reverse: true}, &top); err != nil || !top.Found || top.Rank != 0 {
+		t.Fatalf("Rank() = %+v, %v, want 0", top, err)
+	}
+	var page SortedSetRangeResponse
+	if err := z.Range(ctx, &SortedSetRangeRequest{Key: "scores", Min: "15", Max: "+inf"}, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Members) != 2 || page.Members[0].Member != "eve" || page.Members[1].Member != "ann" {
+		t.Fatalf("Range() = %+v, want eve ann", page.Members)
+	}
+	if err := z.Range(ctx, &SortedSetRangeRequest{Key: "scores", Min: "high"}, &page); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Range() invalid score error = %v, want invalid argument", err)
+	}
+}
@@ -0,0 +1,471 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sparrow-community/app/cache/namespace"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/logger"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LoadersKey hash of the registered loaders by key prefix, an unregistered prefix keeps an empty field
+	LoadersKey = "cache:loaders"
+	// LoadersVersionKey counter of the changes of LoadersKey, the replicas read the loaders again once it changed
+	LoadersVersionKey = "cache:loaders:version"
+	// LoadTimeout of a call to a loader or a writer
+	LoadTimeout = 10 * time.Second
+	// LoadedPrefix of the side keys keeping the freshness of the values stored by a loader, the value itself
+	// stays as is under its key
+	LoadedPrefix = "cache:loaded:"
+)
+
+// Loader loads the missing keys of Prefix by calling Endpoint of Service with a LoadRequest, it answers
+// a LoadResponse, the values set through the LoaderService are written behind to WriteEndpoint
+// with a WriteRequest
+type Loader struct {
+	Prefix        string `json:"prefix"`
+	Service       string `json:"service"`
+	Endpoint      string `json:"endpoint"`
+	WriteEndpoint string `json:"write_endpoint,omitempty"`
+	// TtlMs milliseconds a loaded value is fresh unless the loader answers another one
+	TtlMs int64 `json:"ttl_ms"`
+	// StaleMs milliseconds a value is still served after it is fresh while it is loaded again
+	StaleMs int64 `json:"stale_ms,omitempty"`
+	// Beta rate of the probabilistic early refresh, 1 is the usual rate, 0 disables it
+	Beta float64 `json:"beta,omitempty"`
+}
+
+type LoadRequest struct {
+	Key string `json:"key"`
+}
+
+type LoadResponse struct {
+	// Found is false when the key has no value, it is not stored
+	Found bool   `json:"found"`
+	Value string `json:"value"`
+	// TtlMs overrides the ttl of the loader when it is set
+	TtlMs int64 `json:"ttl_ms"`
+}
+
+type WriteRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type WriteResponse struct{}
+
+type LoaderRegisterRequest struct {
+	Loader *Loader `json:"loader"`
+}
+
+type LoaderRegisterResponse struct{}
+
+type LoaderUnregisterRequest struct {
+	Prefix string `json:"prefix"`
+}
+
+type LoaderUnregisterResponse struct {
+	Found bool `json:"found"`
+}
+
+type LoaderGetRequest struct {
+	Key string `json:"key"`
+}
+
+type LoaderGetResponse struct {
+	// Found is false when the key is missing and no loader found it
+	Found bool   `json:"found"`
+	Value string `json:"value,omitempty"`
+	// TtlMs milliseconds the value is fresh, -1 when it does not expire
+	TtlMs int64 `json:"ttl_ms,omitempty"`
+	// Stale is set when the value is no longer fresh and is loaded again
+	Stale bool `json:"stale,omitempty"`
+	// Loaded is set when the loader was called for the value
+	Loaded bool `json:"loaded,omitempty"`
+}
+
+type LoaderSetRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// TtlMs milliseconds the value is fresh, 0 is the ttl of the loader of the key
+	TtlMs int64 `json:"ttl_ms"`
+}
+
+type LoaderSetResponse struct{}
+
+// LoaderOptions Services the loaders of every caller may call, a caller may always register the loaders
+// of its own service, named like the namespaces
+type LoaderOptions struct {
+	Services         []string
+	TrustFromService bool
+}
+
+// loaded freshness of a value stored by a loader, kept under the side key of the value, Fresh is the unix
+// milliseconds until it is fresh and Delta the milliseconds its load took
+type loaded struct {
+	Fresh int64 `json:"f"`
+	Delta int64 `json:"d"`
+}
+
+func (e *loaded) encode() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// decodeLoaded returns false for a side key value that is not a freshness
+func decodeLoaded(v string) (*loaded, bool) {
+	var e loaded
+	if err := json.Unmarshal([]byte(v), &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+// early reports whether a fresh value is refreshed now, the probability grows as its expiration nears
+// and with the time its load took, like the XFetch algorithm
+func (e *loaded) early(beta float64, now time.Time) bool {
+	if beta <= 0 || e.Fresh == 0 {
+		return false
+	}
+	return float64(now.UnixMilli())-float64(e.Delta)*beta*math.Log(rand.Float64()) >= float64(e.Fresh)
+}
+
+// LoaderService reads the keys through the loaders registered for their prefix, the concurrent loads
+// of a key in a namespace are collapsed into one call, the values it stores are read as is by the other services,
+// the service, like the loaders it calls, talks the application/grpc+json codec, its messages have no proto
+type LoaderService struct {
+	store   store.Store
+	client  client.Client
+	options LoaderOptions
+	flight  singleflight.Group
+
+	sync.Mutex
+	// tables of the loaders by namespace
+	tables map[string]*loaderTable
+}
+
+// loaderTable loaders of a namespace read at a version of LoadersVersionKey
+type loaderTable struct {
+	version string
+	fields  map[string]string
+}
+
+func NewLoaderService(s store.Store, c client.Client, opts LoaderOptions) *LoaderService {
+	return &LoaderService{store: s, client: c, options: opts, tables: map[string]*loaderTable{}}
+}
+
+// allowed reports whether the caller of ctx may register a loader calling service, the cache calls the
+// loaders with its own identity so a caller only reaches its own service or the allowed ones
+func (l *LoaderService) allowed(ctx context.Context, service string) bool {
+	for _, s := range l.options.Services {
+		if s == service {
+			return true
+		}
+	}
+	caller, ok := namespace.Caller(ctx, l.options.TrustFromService)
+	return ok && caller == service
+}
+
+// Register replaces the loader of the prefix, its service is the service of the caller or an allowed one
+func (l *LoaderService) Register(ctx context.Context, request *LoaderRegisterRequest, _ *LoaderRegisterResponse) error {
+	ld := request.Loader
+	switch {
+	case ld == nil || ld.Service == "" || ld.Endpoint == "":
+		return status.Errorf(codes.InvalidArgument, "loader service or endpoint is empty")
+	case !l.allowed(ctx, ld.Service):
+		return status.Errorf(codes.PermissionDenied, "loaders of %s cannot be registered by this caller", ld.Service)
+	case ld.TtlMs <= 0:
+		return status.Errorf(codes.InvalidArgument, "loader ttl_ms must be positive")
+	case ld.StaleMs < 0 || ld.Beta < 0:
+		return status.Errorf(codes.InvalidArgument, "loader stale_ms or beta is negative")
+	}
+	data, err := json.Marshal(ld)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "loader error %s", err)
+	}
+	if err := l.change(ctx, ld.Prefix, string(data)); err != nil {
+		return storeError("register loader", err)
+	}
+	return nil
+}
+
+func (l *LoaderService) Unregister(ctx context.Context, request *LoaderUnregisterRequest, response *LoaderUnregisterResponse) error {
+	v, err := l.store.HGet(ctx, LoadersKey, request.Prefix)
+	if err == store.ErrNotFound || (err == nil && v == "") {
+		return nil
+	}
+	if err != nil {
+		return storeError("unregister loader", err)
+	}
+	if err := l.change(ctx, request.Prefix, ""); err != nil {
+		return storeError("unregister loader", err)
+	}
+	response.Found = true
+	return nil
+}
+
+// change sets the loader of prefix then counts the change so every replica reads the loaders again
+func (l *LoaderService) change(ctx context.Context, prefix string, loader string) error {
+	if _, err := l.store.HSet(ctx, LoadersKey, map[string]string{prefix: loader}); err != nil {
+		return err
+	}
+	_, err := l.store.IncrBy(ctx, LoadersVersionKey, 1)
+	return err
+}
+
+// loaders returns the loaders of the namespace of ctx, they are read again once version, the value of
+// LoadersVersionKey, changed
+func (l *LoaderService) loaders(ctx context.Context, version string) (map[string]string, error) {
+	ns := namespace.Of(ctx, l.options.TrustFromService)
+	l.Lock()
+	t, ok := l.tables[ns]
+	l.Unlock()
+	if ok && t.version == version {
+		return t.fields, nil
+	}
+	// version is read before the loaders, a change in between only reads them again
+	fields, err := l.store.HGetAll(ctx, LoadersKey)
+	if err != nil {
+		return nil, err
+	}
+	l.Lock()
+	l.tables[ns] = &loaderTable{version: version, fields: fields}
+	l.Unlock()
+	return fields, nil
+}
+
+// version returns the value v of LoadersVersionKey read with err, "" before the first change
+func version(v string, err error) (string, error) {
+	if err == store.ErrNotFound {
+		return "", nil
+	}
+	return v, err
+}
+
+// Get returns the value of the key, a missing key is loaded by the loader of its prefix, a stale value is
+// served while it is loaded again in the background
+func (l *LoaderService) Get(ctx context.Context, request *LoaderGetRequest, response *LoaderGetResponse) error {
+	results, err := l.store.Exec(ctx, []store.Op{
+		{Kind: store.OpGet, Key: request.Key},
+		{Kind: store.OpGet, Key: LoadedPrefix + request.Key},
+		{Kind: store.OpGet, Key: LoadersVersionKey},
+	}, false)
+	if err != nil {
+		return storeError("get", err)
+	}
+	v, err := version(results[2].Value, results[2].Err)
+	if err != nil {
+		return storeError("get loaders", err)
+	}
+	fields, err := l.loaders(ctx, v)
+	if err != nil {
+		return storeError("get loaders", err)
+	}
+	ld := match(fields, request.Key)
+
+	hit := results[0]
+	switch {
+	case hit.Err == store.ErrNotFound:
+	case hit.Err != nil:
+		return storeError("get", hit.Err)
+	default:
+		value, err := plain(request.Key, hit.Value)
+		if err != nil {
+			return err
+		}
+		response.Found = true
+		response.Value = value
+		var e *loaded
+		ok := false
+		if results[1].Err == nil {
+			e, ok = decodeLoaded(results[1].Value)
+		}
+		if !ok {
+			// a value set without the loaders
+			response.TtlMs = ttlMs(hit.TTL)
+			return nil
+		}
+		now := time.Now()
+		switch {
+		case e.Fresh == 0:
+			response.TtlMs = -1
+		case now.UnixMilli() < e.Fresh:
+			response.TtlMs = e.Fresh - now.UnixMilli()
+			if ld != nil && e.early(ld.Beta, now) {
+				l.refresh(ctx, ld, request.Key)
+			}
+		default:
+			response.Stale = true
+			if ld != nil {
+				l.refresh(ctx, ld, request.Key)
+			}
+		}
+		return nil
+	}
+
+	if ld == nil {
+		return nil
+	}
+	loadedResponse, err := l.load(ctx, ld, request.Key)
+	if err != nil {
+		return err
+	}
+	if !loadedResponse.Found {
+		return nil
+	}
+	response.Found = true
+	response.Loaded = true
+	response.Value = loadedResponse.Value
+	response.TtlMs = ld.ttl(loadedResponse.TtlMs).Milliseconds()
+	return nil
+}
+
+// Set stores the value like a loaded one and writes it behind to the writer of the loader of the key,
+// the writes of a key may reach the writer out of order
+func (l *LoaderService) Set(ctx context.Context, request *LoaderSetRequest, _ *LoaderSetResponse) error {
+	if request.TtlMs < 0 {
+		return status.Errorf(codes.InvalidArgument, "ttl_ms is negative")
+	}
+	if err := raw(request.Key, request.Value); err != nil {
+		return err
+	}
+	current, _, err := l.store.Get(ctx, LoadersVersionKey)
+	v, err := version(current, err)
+	if err != nil {
+		return storeError("get loaders", err)
+	}
+	fields, err := l.loaders(ctx, v)
+	if err != nil {
+		return storeError("get loaders", err)
+	}
+	ld := match(fields, request.Key)
+	if err := l.put(ctx, ld, request.Key, request.Value, request.TtlMs, 0); err != nil {
+		return storeError("set", err)
+	}
+	if ld == nil || ld.WriteEndpoint == "" {
+		return nil
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(detached{ctx}, LoadTimeout)
+		defer cancel()
+		req := l.client.NewRequest(ld.Service, ld.WriteEndpoint, &WriteRequest{Key: request.Key, Value: request.Value},
+			client.WithContentType("application/json"))
+		if err := l.client.Call(ctx, req, &WriteResponse{}); err != nil {
+			logger.Warnf("write behind %s to %s.%s error: %v", request.Key, ld.Service, ld.WriteEndpoint, err)
+		}
+	}()
+	return nil
+}
+
+// load calls the loader once for the concurrent loads of key in a namespace and stores the found value
+func (l *LoaderService) load(ctx context.Context, ld *Loader, key string) (*LoadResponse, error) {
+	ns := namespace.Of(ctx, l.options.TrustFromService)
+	v, err, _ := l.flight.Do(ns+"/"+ld.Service+"/"+ld.Endpoint+"/"+key, func() (interface{}, error) {
+		// the load outlives a caller that gives up so the others waiting for it still get it
+		ctx, cancel := context.WithTimeout(detached{ctx}, LoadTimeout)
+		defer cancel()
+		start := time.Now()
+		var response LoadResponse
+		req := l.client.NewRequest(ld.Service, ld.Endpoint, &LoadRequest{Key: key}, client.WithContentType("application/json"))
+		if err := l.client.Call(ctx, req, &response); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "load %s from %s.%s error %s", key, ld.Service, ld.Endpoint, err)
+		}
+		if !response.Found {
+			return &response, nil
+		}
+		if err := raw(key, response.Value); err != nil {
+			return nil, err
+		}
+		if err := l.put(ctx, ld, key, response.Value, response.TtlMs, time.Since(start)); err != nil {
+			return nil, storeError("store loaded", err)
+		}
+		return &response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*LoadResponse), nil
+}
+
+// refresh loads key again in the background
+func (l *LoaderService) refresh(ctx context.Context, ld *Loader, key string) {
+	go func() {
+		if _, err := l.load(ctx, ld, key); err != nil {
+			logger.Warnf("refresh %s error: %v", key, err)
+		}
+	}()
+}
+
+// put stores a value fresh for ttlMs, or the ttl of ld, and kept for the stale window of ld after, its
+// freshness goes to its side key, the keys may be in different cluster slots so they are not set atomically
+func (l *LoaderService) put(ctx context.Context, ld *Loader, key string, value string, ttlMs int64, delta time.Duration) error {
+	e := &loaded{Delta: delta.Milliseconds()}
+	var ttl, keep time.Duration
+	if ld != nil {
+		ttl = ld.ttl(ttlMs)
+		keep = ttl + time.Duration(ld.StaleMs)*time.Millisecond
+	} else if ttlMs > 0 {
+		ttl = time.Duration(ttlMs) * time.Millisecond
+		keep = ttl
+	}
+	if ttl > 0 {
+		e.Fresh = time.Now().Add(ttl).UnixMilli()
+	}
+	results, err := l.store.Exec(ctx, []store.Op{
+		{Kind: store.OpSet, Key: key, Value: value, TTL: keep},
+		{Kind: store.OpSet, Key: LoadedPrefix + key, Value: e.encode(), TTL: keep},
+	}, false)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// ttl returns the ttl answered by a loader, the ttl of ld when it answered none
+func (ld *Loader) ttl(ms int64) time.Duration {
+	if ms <= 0 {
+		ms = ld.TtlMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// match returns the loader of the longest prefix of key, nil when none is registered
+func match(loaders map[string]string, key string) *Loader {
+	var best *Loader
+	for prefix, v := range loaders {
+		if v == "" || !strings.HasPrefix(key, prefix) || (best != nil && len(best.Prefix) >= len(prefix)) {
+			continue
+		}
+		var ld Loader
+		if err := json.Unmarshal([]byte(v), &ld); err != nil {
+			continue
+		}
+		ld.Prefix = prefix
+		best = &ld
+	}
+	return best
+}
+
+// detached keeps the values of a context, like its metadata, without its deadline and cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
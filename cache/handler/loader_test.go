@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/namespace"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/protos/cache"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/errors"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loaderClient answers the loads with the key as value after a delay, it counts the loads
+type loaderClient struct {
+	client.Client
+	loads  int32
+	delay  time.Duration
+	writes chan *WriteRequest
+}
+
+func (c *loaderClient) Call(_ context.Context, req client.Request, rsp interface{}, _ ...client.CallOption) error {
+	switch r := req.Body().(type) {
+	case *LoadRequest:
+		atomic.AddInt32(&c.loads, 1)
+		time.Sleep(c.delay)
+		*rsp.(*LoadResponse) = LoadResponse{Found: r.Key != "users:missing", Value: "loaded " + r.Key}
+	case *WriteRequest:
+		c.writes <- r
+	}
+	return nil
+}
+
+// eventually fails t unless cond holds within a second
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLoaderService(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	c := &loaderClient{Client: client.NewClient(), delay: 50 * time.Millisecond, writes: make(chan *WriteRequest, 1)}
+	l := NewLoaderService(s, c, LoaderOptions{Services: []string{"users"}})
+	ctx := context.Background()
+
+	loader := &Loader{Prefix: "users:", Service: "users", Endpoint: "Users.Load", WriteEndpoint: "Users.Save", TtlMs: 100, StaleMs: 1000}
+	if err := l.Register(ctx, &LoaderRegisterRequest{Loader: loader}, &LoaderRegisterResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]LoaderGetResponse, 10)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := l.Get(ctx, &LoaderGetRequest{Key: "users:1"}, &responses[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, r := range responses {
+		if !r.Found || r.Value != "loaded users:1" {
+			t.Fatalf("Get() = %+v, want the loaded value", r)
+		}
+	}
+	if n := atomic.LoadInt32(&c.loads); n != 1 {
+		t.Fatalf("loads = %d, want the concurrent gets collapsed into 1", n)
+	}
+	if v, _, err := s.Get(ctx, "users:1"); err != nil || v != "loaded users:1" {
+		t.Fatalf("stored value = %q, %v, want the loaded value as is", v, err)
+	}
+
+	// the value turns stale once its ttl elapses, the get serving it refreshes it
+	var stale LoaderGetResponse
+	eventually(t, "the stale value served", func() bool {
+		stale = LoaderGetResponse{}
+		err := l.Get(ctx, &LoaderGetRequest{Key: "users:1"}, &stale)
+		return err == nil && stale.Stale
+	})
+	if !stale.Found || stale.Loaded {
+		t.Fatalf("Get() stale = %+v, want the stale value served", stale)
+	}
+	eventually(t, "the stale value refreshed", func() bool {
+		return atomic.LoadInt32(&c.loads) == 2
+	})
+
+	var through cache.GetResponse
+	if err := (Cache{Store: s, Loader: l}).Get(ctx, &cache.GetRequest{Key: "users:4"}, &through); err != nil || through.Value != "loaded users:4" {
+		t.Fatalf("Cache.Get() = %+v, %v, want the value read through the loader", &through, err)
+	}
+	if err := (Cache{Store: s, Loader: l}).Get(ctx, &cache.GetRequest{Key: "users:missing"}, &cache.GetResponse{}); errors.FromError(err).Code != http.StatusNotFound {
+		t.Fatalf("Cache.Get() missing error = %v, want not found", err)
+	}
+
+	var missing LoaderGetResponse
+	if err := l.Get(ctx, &LoaderGetRequest{Key: "users:missing"}, &missing); err != nil || missing.Found {
+		t.Fatalf("Get() missing = %+v, %v", missing, err)
+	}
+	var other LoaderGetResponse
+	if err := l.Get(ctx, &LoaderGetRequest{Key: "orders:1"}, &other); err != nil || other.Found {
+		t.Fatalf("Get() without loader = %+v, %v", other, err)
+	}
+
+	if err := l.Set(ctx, &LoaderSetRequest{Key: "users:2", Value: "ann"}, &LoaderSetResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-c.writes:
+		if w.Key != "users:2" || w.Value != "ann" {
+			t.Fatalf("write behind = %+v, want ann", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the set was not written behind")
+	}
+
+	// a replica keeps the loaders it read until they change
+	replica := NewLoaderService(s, c, LoaderOptions{Services: []string{"users"}})
+	var read LoaderGetResponse
+	if err := replica.Get(ctx, &LoaderGetRequest{Key: "users:5"}, &read); err != nil || !read.Loaded {
+		t.Fatalf("replica Get() = %+v, %v, want the loaded value", read, err)
+	}
+
+	var unregistered LoaderUnregisterResponse
+	if err := l.Unregister(ctx, &LoaderUnregisterRequest{Prefix: "users:"}, &unregistered); err != nil || !unregistered.Found {
+		t.Fatalf("Unregister() = %+v, %v", unregistered, err)
+	}
+	var after LoaderGetResponse
+	if err := l.Get(ctx, &LoaderGetRequest{Key: "users:3"}, &after); err != nil || after.Found {
+		t.Fatalf("Get() unregistered = %+v, %v, want no load", after, err)
+	}
+	if err := replica.Get(ctx, &LoaderGetRequest{Key: "users:6"}, &after); err != nil || after.Found {
+		t.Fatalf("replica Get() unregistered = %+v, %v, want no load", after, err)
+	}
+}
+
+func TestLoaderServiceNamespaces(t *testing.T) {
+	inner := store.NewMemory(0, 0)
+	s := namespace.New(inner, namespace.Options{TrustFromService: true})
+	defer s.Close()
+	c := &loaderClient{Client: client.NewClient(), delay: 50 * time.Millisecond}
+	l := NewLoaderService(s, c, LoaderOptions{Services: []string{"users"}, TrustFromService: true})
+
+	services := []string{"orders", "payments"}
+	for _, service := range services {
+		ctx := metadata.NewContext(context.Background(), metadata.Metadata{namespace.FromServiceKey: service})
+		loader := &Loader{Prefix: "users:", Service: "users", Endpoint: "Users.Load", TtlMs: 1000}
+		if err := l.Register(ctx, &LoaderRegisterRequest{Loader: loader}, &LoaderRegisterResponse{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the concurrent loads of the same key in two namespaces are not collapsed, each stores its value
+	var wg sync.WaitGroup
+	for _, service := range services {
+		wg.Add(1)
+		go func(service string) {
+			defer wg.Done()
+			ctx := metadata.NewContext(context.Background(), metadata.Metadata{namespace.FromServiceKey: service})
+			var response LoaderGetResponse
+			if err := l.Get(ctx, &LoaderGetRequest{Key: "users:1"}, &response); err != nil || !response.Found {
+				t.Errorf("Get() in %s = %+v, %v", service, response, err)
+			}
+		}(service)
+	}
+	wg.Wait()
+	for _, service := range services {
+		if v, _, err := inner.Get(context.Background(), service+namespace.Separator+"users:1"); err != nil || v != "loaded users:1" {
+			t.Errorf("stored value in %s = %q, %v, want the loaded value", service, v, err)
+		}
+	}
+}
+
+func TestLoaderServiceRegister(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	l := NewLoaderService(s, client.NewClient(), LoaderOptions{Services: []string{"users"}, TrustFromService: true})
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{namespace.FromServiceKey: "orders"})
+
+	for _, c := range []struct {
+		service string
+		code    codes.Code
+	}{
+		{"orders", codes.OK},
+		{"users", codes.OK},
+		{"payments", codes.PermissionDenied},
+	} {
+		loader := &Loader{Prefix: c.service + ":", Service: c.service, Endpoint: "Load", TtlMs: 100}
+		err := l.Register(ctx, &LoaderRegisterRequest{Loader: loader}, &LoaderRegisterResponse{})
+		if status.Code(err) != c.code {
+			t.Errorf("Register(%s) error = %v, want %s", c.service, err, c.code)
+		}
+	}
+	loader := &Loader{Prefix: "orders:", Service: "orders", Endpoint: "Load", TtlMs: 100}
+	err := l.Register(context.Background(), &LoaderRegisterRequest{Loader: loader}, &LoaderRegisterResponse{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Register() anonymous error = %v, want permission denied", err)
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultLockTtl lease of a lock acquired without a ttl
+	DefaultLockTtl = 30 * time.Second
+	// MaxLockWait longest wait of a blocking acquisition
+	MaxLockWait = time.Minute
+)
+
+type AcquireRequest struct {
+	Name string `json:"name"`
+	// Owner of the lease, a random owner is returned when it is empty
+	Owner string `json:"owner"`
+	// TtlMs lease in milliseconds
+	TtlMs int64 `json:"ttl_ms"`
+	// WaitMs time to wait for the lock in milliseconds, 0 does not wait
+	WaitMs int64 `json:"wait_ms"`
+}
+
+type AcquireResponse struct {
+	Acquired bool   `json:"acquired"`
+	Owner    string `json:"owner"`
+	// Token fencing token of the lease, greater than the tokens of the previous leases of the lock
+	Token int64 `json:"token"`
+}
+
+type RenewRequest struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	TtlMs int64  `json:"ttl_ms"`
+}
+
+type RenewResponse struct{}
+
+type ReleaseRequest struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+}
+
+type ReleaseResponse struct{}
+
+// LockService leases named locks to the replicas of the services, a lease expires unless it is renewed,
+// a resource guarded by a lock should reject the fencing tokens lower than the last one it saw, the service has
+// no proto and answers the application/grpc+json codec only
+type LockService struct {
+	locker store.Locker
+}
+
+func NewLockService(l store.Locker) *LockService {
+	return &LockService{locker: l}
+}
+
+// Acquire retries until the lock is free or the wait elapses, Acquired is false when it did not get the lock
+func (l *LockService) Acquire(ctx context.Context, request *AcquireRequest, response *AcquireResponse) error {
+	if request.Name == "" {
+		return status.Errorf(codes.InvalidArgument, "lock name is empty")
+	}
+	owner := request.Owner
+	if owner == "" {
+		owner = uuid.New().String()
+	}
+	ttl := lockTtl(request.TtlMs)
+	wait := time.Duration(request.WaitMs) * time.Millisecond
+	if wait > MaxLockWait {
+		wait = MaxLockWait
+	}
+	deadline := time.Now().Add(wait)
+	delay := 10 * time.Millisecond
+	for {
+		token, err := l.locker.Acquire(ctx, request.Name, owner, ttl)
+		if err == nil {
+			response.Acquired = true
+			response.Owner = owner
+			response.Token = token
+			return nil
+		}
+		if err != store.ErrLocked {
+			return storeError("acquire lock", err)
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			response.Owner = owner
+			return nil
+		}
+		// retry with a jittered exponential backoff
+		sleep := delay/2 + time.Duration(rand.Int63n(int64(delay)))
+		if sleep > left {
+			sleep = left
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(sleep):
+		}
+		if delay < time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func (l *LockService) Renew(ctx context.Context, request *RenewRequest, _ *RenewResponse) error {
+	err := l.locker.Renew(ctx, request.Name, request.Owner, lockTtl(request.TtlMs))
+	if err == store.ErrNotOwner {
+		return status.Errorf(codes.FailedPrecondition, "lock %s is not held by %s", request.Name, request.Owner)
+	}
+	if err != nil {
+		return storeError("renew lock", err)
+	}
+	return nil
+}
+
+func (l *LockService) Release(ctx context.Context, request *ReleaseRequest, _ *ReleaseResponse) error {
+	err := l.locker.Release(ctx, request.Name, request.Owner)
+	if err == store.ErrNotOwner {
+		return status.Errorf(codes.FailedPrecondition, "lock %s is not held by %s", request.Name, request.Owner)
+	}
+	if err != nil {
+		return storeError("release lock", err)
+	}
+	return nil
+}
+
+func lockTtl(ms int64) time.Duration {
+	if ms <= 0 {
+		return DefaultLockTtl
+	}
+	return time.Duration(ms) * time.Millisecond
+}
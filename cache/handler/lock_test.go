@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"testing"
+	"time"
+)
+
+func TestLockService(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	l := NewLockService(s)
+	ctx := context.Background()
+
+	var first AcquireResponse
+	if err := l.Acquire(ctx, &AcquireRequest{Name: "init", TtlMs: 100}, &first); err != nil {
+		t.Fatal(err)
+	}
+	if !first.Acquired || first.Owner == "" || first.Token == 0 {
+		t.Fatalf("Acquire() = %+v", first)
+	}
+
+	var busy AcquireResponse
+	if err := l.Acquire(ctx, &AcquireRequest{Name: "init", Owner: "other"}, &busy); err != nil {
+		t.Fatal(err)
+	}
+	if busy.Acquired {
+		t.Fatalf("Acquire() of a held lock = %+v", busy)
+	}
+
+	// the blocking acquisition gets the lock once the first lease expires
+	start := time.Now()
+	var second AcquireResponse
+	if err := l.Acquire(ctx, &AcquireRequest{Name: "init", Owner: "other", WaitMs: 2000}, &second); err != nil {
+		t.Fatal(err)
+	}
+	if !second.Acquired || second.Token <= first.Token || time.Since(start) > time.Second {
+		t.Fatalf("Acquire() waiting = %+v after %v", second, time.Since(start))
+	}
+
+	if err := l.Renew(ctx, &RenewRequest{Name: "init", Owner: first.Owner}, &RenewResponse{}); err == nil {
+		t.Fatal("Renew() of an expired lease succeeded")
+	}
+	if err := l.Release(ctx, &ReleaseRequest{Name: "init", Owner: "other"}, &ReleaseResponse{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PublishRequest struct {
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+}
+
+type PublishResponse struct {
+	// Receivers subscriptions that received the message
+	Receivers int64 `json:"receivers"`
+}
+
+type SubscribeRequest struct {
+	Channels []string `json:"channels"`
+	// Patterns redis globs of the channels
+	Patterns []string `json:"patterns"`
+}
+
+type SubscribeResponse struct {
+	Channel string `json:"channel"`
+	// Pattern that matched the channel, empty for a subscription to the channel
+	Pattern string `json:"pattern"`
+	Message string `json:"message"`
+}
+
+type WatchRequest struct {
+	// Prefix of the watched keys
+	Prefix string `json:"prefix"`
+	// Pattern redis glob of the keys after the prefix, empty matches every key
+	Pattern string `json:"pattern"`
+}
+
+type WatchResponse struct {
+	Key string `json:"key"`
+	// Event set, del, incrby, hset, expired, evicted or an other redis keyspace event
+	Event string `json:"event"`
+}
+
+// PubSubService publishes messages on channels and streams the messages of channels and the changes of keys,
+// a slow subscriber misses the messages beyond store.SubscriptionBuffer, its streams and messages use the
+// application/grpc+json codec, there is no proto for them
+type PubSubService struct {
+	pubsub store.PubSub
+}
+
+func NewPubSubService(p store.PubSub) *PubSubService {
+	return &PubSubService{pubsub: p}
+}
+
+func (p *PubSubService) Publish(ctx context.Context, request *PublishRequest, response *PublishResponse) error {
+	if request.Channel == "" {
+		return status.Errorf(codes.InvalidArgument, "channel is empty")
+	}
+	n, err := p.pubsub.Publish(ctx, request.Channel, request.Message)
+	if err != nil {
+		return storeError("publish", err)
+	}
+	response.Receivers = n
+	return nil
+}
+
+// Subscribe streams the messages of the channels, the stream receives one SubscribeRequest and the
+// subscription ends when the client closes the stream
+func (p *PubSubService) Subscribe(ctx context.Context, stream server.Stream) error {
+	defer stream.Close()
+	var request SubscribeRequest
+	if err := stream.Recv(&request); err != nil {
+		return err
+	}
+	if len(request.Channels) == 0 && len(request.Patterns) == 0 {
+		return status.Errorf(codes.InvalidArgument, "no channel or pattern")
+	}
+	ctx, cancel := closed(ctx, stream)
+	defer cancel()
+	messages, err := p.pubsub.Subscribe(ctx, request.Channels, request.Patterns)
+	if err != nil {
+		return storeError("subscribe", err)
+	}
+	for m := range messages {
+		if err := stream.Send(&SubscribeResponse{Channel: m.Channel, Pattern: m.Pattern, Message: m.Payload}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch streams the events of the keys, the stream receives one WatchRequest and the watch ends when
+// the client closes the stream, it fails with FailedPrecondition on a redis without notify-keyspace-events KA
+func (p *PubSubService) Watch(ctx context.Context, stream server.Stream) error {
+	defer stream.Close()
+	var request WatchRequest
+	if err := stream.Recv(&request); err != nil {
+		return err
+	}
+	pattern := request.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	ctx, cancel := closed(ctx, stream)
+	defer cancel()
+	events, err := p.pubsub.Watch(ctx, store.Escape(request.Prefix)+pattern)
+	if errors.Is(err, store.ErrNoNotifications) {
+		return status.Errorf(codes.FailedPrecondition, "%s", err)
+	}
+	if err != nil {
+		return storeError("watch", err)
+	}
+	for e := range events {
+		if err := stream.Send(&WatchResponse{Key: e.Key, Event: e.Op}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closed returns a context canceled when the client closes the stream, a stream only receives its request
+func closed(ctx context.Context, stream server.Stream) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		var v interface{}
+		_ = stream.Recv(&v)
+		cancel()
+	}()
+	return ctx, cancel
+}
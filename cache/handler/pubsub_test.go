@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/server"
+	"io"
+	"testing"
+	"time"
+)
+
+// watchStream receives one request, then blocks until the client closes it
+type watchStream struct {
+	request interface{}
+	sent    chan interface{}
+	closed  chan bool
+}
+
+func (s *watchStream) Context() context.Context { return context.Background() }
+func (s *watchStream) Request() server.Request  { return nil }
+func (s *watchStream) Error() error             { return nil }
+func (s *watchStream) Close() error             { return nil }
+
+func (s *watchStream) Send(m interface{}) error {
+	s.sent <- m
+	return nil
+}
+
+func (s *watchStream) Recv(m interface{}) error {
+	if s.request == nil {
+		<-s.closed
+		return io.EOF
+	}
+	data, _ := json.Marshal(s.request)
+	s.request = nil
+	return json.Unmarshal(data, m)
+}
+
+func TestPubSubServiceWatch(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	p := NewPubSubService(s)
+	stream := &watchStream{
+		request: &WatchRequest{Prefix: "cache:identity:"},
+		sent:    make(chan interface{}, 10),
+		closed:  make(chan bool),
+	}
+	done := make(chan error)
+	go func() {
+		done <- p.Watch(context.Background(), stream)
+	}()
+
+	// the watch is registered asynchronously, so the key is written until an event arrives
+	deadline := time.After(time.Second)
+	for {
+		if err := s.Set(context.Background(), "other", "v", 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Set(context.Background(), "cache:identity:rsa:token", "key", 0); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case m := <-stream.sent:
+			if r := m.(*WatchResponse); r.Key != "cache:identity:rsa:token" || r.Event != store.EventSet {
+				t.Fatalf("Watch() sent %+v", r)
+			}
+		case <-time.After(10 * time.Millisecond):
+			continue
+		case <-deadline:
+			t.Fatal("no event")
+		}
+		break
+	}
+
+	close(stream.closed)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Watch() did not end with the stream")
+	}
+}
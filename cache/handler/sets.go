@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+)
+
+type SetAddRequest struct {
+	Key     string   `json:"key"`
+	Members []string `json:"members"`
+}
+
+type SetAddResponse struct {
+	// Added members that were not members yet
+	Added int64 `json:"added"`
+}
+
+type SetRemoveRequest struct {
+	Key     string   `json:"key"`
+	Members []string `json:"members"`
+}
+
+type SetRemoveResponse struct {
+	Removed int64 `json:"removed"`
+}
+
+type SetMembersRequest struct {
+	Key string `json:"key"`
+}
+
+type SetMembersResponse struct {
+	Members []string `json:"members"`
+}
+
+type SetIsMemberRequest struct {
+	Key    string `json:"key"`
+	Member string `json:"member"`
+}
+
+type SetIsMemberResponse struct {
+	IsMember bool `json:"is_member"`
+}
+
+// SetService keeps sets of strings, like the keys already seen by a deduplication, over the application/grpc+json
+// codec
+type SetService struct {
+	sets store.Sets
+}
+
+func NewSetService(s store.Sets) *SetService {
+	return &SetService{sets: s}
+}
+
+func (s *SetService) Add(ctx context.Context, request *SetAddRequest, response *SetAddResponse) error {
+	n, err := s.sets.SAdd(ctx, request.Key, request.Members...)
+	if err != nil {
+		return storeError("add", err)
+	}
+	response.Added = n
+	return nil
+}
+
+func (s *SetService) Remove(ctx context.Context, request *SetRemoveRequest, response *SetRemoveResponse) error {
+	n, err := s.sets.SRem(ctx, request.Key, request.Members...)
+	if err != nil {
+		return storeError("remove", err)
+	}
+	response.Removed = n
+	return nil
+}
+
+// Members returns the sorted members of a set
+func (s *SetService) Members(ctx context.Context, request *SetMembersRequest, response *SetMembersResponse) error {
+	members, err := s.sets.SMembers(ctx, request.Key)
+	if err != nil {
+		return storeError("members", err)
+	}
+	response.Members = members
+	return nil
+}
+
+func (s *SetService) IsMember(ctx context.Context, request *SetIsMemberRequest, response *SetIsMemberResponse) error {
+	ok, err := s.sets.SIsMember(ctx, request.Key, request.Member)
+	if err != nil {
+		return storeError("is member", err)
+	}
+	response.IsMember = ok
+	return nil
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"strconv"
+)
+
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+type SortedSetAddRequest struct {
+	Key     string          `json:"key"`
+	Members []*ScoredMember `json:"members"`
+}
+
+type SortedSetAddResponse struct {
+	// Added members that were not members yet, the score of the others is updated
+	Added int64 `json:"added"`
+}
+
+type SortedSetRangeRequest struct {
+	Key string `json:"key"`
+	// Min, Max scores of the members included, like 10, -inf or +inf, empty is unbounded
+	Min string `json:"min"`
+	Max string `json:"max"`
+	// Offset, Count page of the members, a count of 0 returns every member from the offset
+	Offset int64 `json:"offset"`
+	Count  int64 `json:"count"`
+}
+
+type SortedSetRangeResponse struct {
+	// Members ordered by score then by member
+	Members []*ScoredMember `json:"members"`
+}
+
+type SortedSetRankRequest struct {
+	Key    string `json:"key"`
+	Member string `json:"member"`
+	// Reverse ranks from the highest score, like a leaderboard
+	Reverse bool `json:"reverse"`
+}
+
+type SortedSetRankResponse struct {
+	// Found is false when the member is not in the set
+	Found bool  `json:"found"`
+	Rank  int64 `json:"rank"`
+}
+
+// SortedSetService keeps members ordered by score, like leaderboards, over the application/grpc+json codec
+type SortedSetService struct {
+	sets store.SortedSets
+}
+
+func NewSortedSetService(s store.SortedSets) *SortedSetService {
+	return &SortedSetService{sets: s}
+}
+
+func (s *SortedSetService) Add(ctx context.Context, request *SortedSetAddRequest, response *SortedSetAddResponse) error {
+	members := make([]store.ZMember, 0, len(request.Members))
+	for _, m := range request.Members {
+		if m != nil {
+			members = append(members, store.ZMember{Member: m.Member, Score: m.Score})
+		}
+	}
+	n, err := s.sets.ZAdd(ctx, request.Key, members...)
+	if err != nil {
+		return storeError("add", err)
+	}
+	response.Added = n
+	return nil
+}
+
+func (s *SortedSetService) Range(ctx context.Context, request *SortedSetRangeRequest, response *SortedSetRangeResponse) error {
+	min, err := bound(request.Min, math.Inf(-1))
+	if err != nil {
+		return err
+	}
+	max, err := bound(request.Max, math.Inf(1))
+	if err != nil {
+		return err
+	}
+	members, err := s.sets.ZRangeByScore(ctx, request.Key, min, max, request.Offset, request.Count)
+	if err != nil {
+		return storeError("range", err)
+	}
+	response.Members = make([]*ScoredMember, len(members))
+	for i, m := range members {
+		response.Members[i] = &ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	return nil
+}
+
+func (s *SortedSetService) Rank(ctx context.Context, request *SortedSetRankRequest, response *SortedSetRankResponse) error {
+	rank := s.sets.ZRank
+	if request.Reverse {
+		rank = s.sets.ZRevRank
+	}
+	n, err := rank(ctx, request.Key, request.Member)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return storeError("rank", err)
+	}
+	response.Found = true
+	response.Rank = n
+	return nil
+}
+
+// bound parses a score bound, empty is def
+func bound(v string, def float64) (float64, error) {
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid score %s", v)
+	}
+	return f, nil
+}
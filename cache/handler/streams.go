@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// MaxReadBlock longest wait of a stream read
+const MaxReadBlock = time.Minute
+
+type StreamEntry struct {
+	Id     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+type StreamAppendRequest struct {
+	Key string `json:"key"`
+	// Id of the entry, empty generates it
+	Id     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+	// MaxLen entries kept by the stream, the oldest ones are removed, 0 keeps every entry
+	MaxLen int64 `json:"max_len"`
+}
+
+type StreamAppendResponse struct {
+	Id string `json:"id"`
+}
+
+type StreamReadRequest struct {
+	Key string `json:"key"`
+	// After id of the last entry read, empty reads from the start and $ only the next entries
+	After string `json:"after"`
+	Count int64  `json:"count"`
+	// BlockMs time to wait for an entry in milliseconds, 0 does not wait
+	BlockMs int64 `json:"block_ms"`
+}
+
+type StreamReadResponse struct {
+	Entries []*StreamEntry `json:"entries"`
+}
+
+type StreamCreateGroupRequest struct {
+	Key   string `json:"key"`
+	Group string `json:"group"`
+	// Start id after which the group reads, empty reads from the start and $ only the next entries
+	Start string `json:"start"`
+}
+
+type StreamCreateGroupResponse struct{}
+
+type StreamReadGroupRequest struct {
+	Key      string `json:"key"`
+	Group    string `json:"group"`
+	Consumer string `json:"consumer"`
+	Count    int64  `json:"count"`
+	BlockMs  int64  `json:"block_ms"`
+}
+
+type StreamReadGroupResponse struct {
+	// Entries pending until they are acknowledged
+	Entries []*StreamEntry `json:"entries"`
+}
+
+type StreamAckRequest struct {
+	Key   string   `json:"key"`
+	Group string   `json:"group"`
+	Ids   []string `json:"ids"`
+}
+
+type StreamAckResponse struct {
+	Acked int64 `json:"acked"`
+}
+
+// StreamService appends entries to streams, read by consumer groups that share them between their consumers,
+// the four collection services have no proto, their callers use the application/grpc+json codec
+type StreamService struct {
+	streams store.Streams
+}
+
+func NewStreamService(s store.Streams) *StreamService {
+	return &StreamService{streams: s}
+}
+
+func (s *StreamService) Append(ctx context.Context, request *StreamAppendRequest, response *StreamAppendResponse) error {
+	if len(request.Fields) == 0 {
+		return status.Errorf(codes.InvalidArgument, "stream entry has no field")
+	}
+	id, err := s.streams.XAdd(ctx, request.Key, request.Id, request.Fields, request.MaxLen)
+	if err != nil {
+		return storeError("append", err)
+	}
+	response.Id = id
+	return nil
+}
+
+func (s *StreamService) Read(ctx context.Context, request *StreamReadRequest, response *StreamReadResponse) error {
+	entries, err := s.streams.XRead(ctx, request.Key, request.After, request.Count, block(request.BlockMs))
+	if err != nil {
+		return storeError("read", err)
+	}
+	response.Entries = streamEntries(entries)
+	return nil
+}
+
+func (s *StreamService) CreateGroup(ctx context.Context, request *StreamCreateGroupRequest, _ *StreamCreateGroupResponse) error {
+	if request.Group == "" {
+		return status.Errorf(codes.InvalidArgument, "group is empty")
+	}
+	start := request.Start
+	if start == "" {
+		start = "0"
+	}
+	if err := s.streams.XGroupCreate(ctx, request.Key, request.Group, start); err != nil {
+		return storeError("create group", err)
+	}
+	return nil
+}
+
+// ReadGroup delivers the entries never delivered to the group
+func (s *StreamService) ReadGroup(ctx context.Context, request *StreamReadGroupRequest, response *StreamReadGroupResponse) error {
+	if request.Consumer == "" {
+		return status.Errorf(codes.InvalidArgument, "consumer is empty")
+	}
+	entries, err := s.streams.XReadGroup(ctx, request.Key, request.Group, request.Consumer, request.Count, block(request.BlockMs))
+	if err != nil {
+		return storeError("read group", err)
+	}
+	response.Entries = streamEntries(entries)
+	return nil
+}
+
+func (s *StreamService) Ack(ctx context.Context, request *StreamAckRequest, response *StreamAckResponse) error {
+	n, err := s.streams.XAck(ctx, request.Key, request.Group, request.Ids...)
+	if err != nil {
+		return storeError("ack", err)
+	}
+	response.Acked = n
+	return nil
+}
+
+func block(ms int64) time.Duration {
+	d := time.Duration(ms) * time.Millisecond
+	if d > MaxReadBlock {
+		return MaxReadBlock
+	}
+	return d
+}
+
+func streamEntries(entries []store.StreamEntry) []*StreamEntry {
+	out := make([]*StreamEntry, len(entries))
+	for i, e := range entries {
+		out[i] = &StreamEntry{Id: e.ID, Fields: e.Fields}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+type StringGetRequest struct {
+	Key string `json:"key"`
+}
+
+type StringGetResponse struct {
+	// Found is false for a missing key, an empty value is found
+	Found bool   `json:"found"`
+	Value string `json:"value,omitempty"`
+	// TtlMs remaining milliseconds, -1 when the key does not expire
+	TtlMs int64 `json:"ttl_ms,omitempty"`
+}
+
+type StringSetRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Mode nx only sets a missing key, xx only an existing one, empty sets it anyway
+	Mode string `json:"mode"`
+	// TtlMs time to live in milliseconds, 0 never expires
+	TtlMs int64 `json:"ttl_ms"`
+	// KeepTtl keeps the time to live of an existing key, TtlMs must be 0
+	KeepTtl bool `json:"keep_ttl"`
+	// Get returns the previous value, like GETSET
+	Get bool `json:"get"`
+}
+
+type StringSetResponse struct {
+	// Set is false when the mode did not hold
+	Set bool `json:"set"`
+	// Found tells whether the key existed, Old is its previous value, both only when Get is set
+	Found bool   `json:"found,omitempty"`
+	Old   string `json:"old,omitempty"`
+}
+
+// StringService reads and conditionally sets string values with a time to live in milliseconds, it reads the
+// data of the values set by the value service, it is served over the application/grpc+json codec, without proto
+type StringService struct {
+	store store.Store
+}
+
+func NewStringService(s store.Store) *StringService {
+	return &StringService{store: s}
+}
+
+func (s *StringService) Get(ctx context.Context, request *StringGetRequest, response *StringGetResponse) error {
+	v, d, err := s.store.Get(ctx, request.Key)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return storeError("get", err)
+	}
+	if v, err = plain(request.Key, v); err != nil {
+		return err
+	}
+	response.Found = true
+	response.Value = v
+	response.TtlMs = ttlMs(d)
+	return nil
+}
+
+// Set replaces the value of the key when its mode holds
+func (s *StringService) Set(ctx context.Context, request *StringSetRequest, response *StringSetResponse) error {
+	mode := store.SetMode(request.Mode)
+	switch {
+	case mode != store.SetAlways && mode != store.SetNX && mode != store.SetXX:
+		return status.Errorf(codes.InvalidArgument, "unknown mode %s", request.Mode)
+	case request.TtlMs < 0:
+		return status.Errorf(codes.InvalidArgument, "ttl_ms is negative")
+	case request.KeepTtl && request.TtlMs > 0:
+		return status.Errorf(codes.InvalidArgument, "keep_ttl and ttl_ms are exclusive")
+	}
+	if err := raw(request.Key, request.Value); err != nil {
+		return err
+	}
+	res, err := s.store.SetArgs(ctx, request.Key, request.Value, store.SetArgs{
+		Mode:    mode,
+		TTL:     time.Duration(request.TtlMs) * time.Millisecond,
+		KeepTTL: request.KeepTtl,
+		Get:     request.Get,
+	})
+	if err != nil {
+		return storeError("set", err)
+	}
+	if res.Old, err = plain(request.Key, res.Old); err != nil {
+		return err
+	}
+	response.Set = res.Set
+	response.Found = res.Existed
+	response.Old = res.Old
+	return nil
+}
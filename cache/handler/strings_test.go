@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/protos/cache"
+	"go-micro.dev/v4/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStringServiceSet(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	str := NewStringService(s)
+	k := NewKeyService(s)
+	ctx := context.Background()
+
+	var missing StringGetResponse
+	if err := str.Get(ctx, &StringGetRequest{Key: "token"}, &missing); err != nil || missing.Found {
+		t.Fatalf("Get() missing = %+v, %v", missing, err)
+	}
+	err := (Cache{Store: s}).Get(ctx, &cache.GetRequest{Key: "token"}, &cache.GetResponse{})
+	if errors.FromError(err).Code != http.StatusNotFound {
+		t.Fatalf("Cache.Get() missing error = %v, want not found", err)
+	}
+
+	var set StringSetResponse
+	if err := str.Set(ctx, &StringSetRequest{Key: "token", Mode: "nx", TtlMs: 1500}, &set); err != nil || !set.Set {
+		t.Fatalf("Set() nx = %+v, %v, want set", set, err)
+	}
+	var got StringGetResponse
+	if err := str.Get(ctx, &StringGetRequest{Key: "token"}, &got); err != nil || !got.Found || got.Value != "" {
+		t.Fatalf("Get() empty = %+v, %v, want an empty value found", got, err)
+	}
+	if got.TtlMs <= 1000 || got.TtlMs > 1500 {
+		t.Fatalf("Get() ttl = %d, want about 1500ms", got.TtlMs)
+	}
+	var out cache.GetResponse
+	if err := (Cache{Store: s}).Get(ctx, &cache.GetRequest{Key: "token"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if now := time.Now().Unix(); out.Ttl < now || out.Ttl > now+2 {
+		t.Fatalf("Cache.Get() ttl = %d, want the unix time in 1500ms", out.Ttl)
+	}
+
+	var swapped StringSetResponse
+	if err := str.Set(ctx, &StringSetRequest{Key: "token", Value: "b", KeepTtl: true, Get: true}, &swapped); err != nil {
+		t.Fatal(err)
+	}
+	if !swapped.Set || !swapped.Found || swapped.Old != "" {
+		t.Fatalf("Set() get = %+v, want the empty previous value", swapped)
+	}
+	err = str.Set(ctx, &StringSetRequest{Key: "token", Mode: "always"}, &StringSetResponse{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Set() unknown mode error = %v, want invalid argument", err)
+	}
+
+	var persisted PersistResponse
+	if err := k.Persist(ctx, &PersistRequest{Key: "token"}, &persisted); err != nil || !persisted.Persisted {
+		t.Fatalf("Persist() = %+v, %v", persisted, err)
+	}
+	var ttl TTLResponse
+	if err := k.TTL(ctx, &TTLRequest{Key: "token"}, &ttl); err != nil || !ttl.Found || ttl.TtlMs != -1 {
+		t.Fatalf("TTL() = %+v, %v, want -1", ttl, err)
+	}
+	if err := k.TTL(ctx, &TTLRequest{Key: "other"}, &ttl); err != nil || ttl.Found || ttl.TtlMs != -2 {
+		t.Fatalf("TTL() missing = %+v, %v, want -2", ttl, err)
+	}
+	var expired ExpireResponse
+	if err := k.Expire(ctx, &ExpireRequest{Key: "token", TtlMs: 200}, &expired); err != nil || !expired.Found {
+		t.Fatalf("Expire() = %+v, %v", expired, err)
+	}
+	if err := k.Expire(ctx, &ExpireRequest{Key: "token"}, &expired); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expire() without ttl error = %v, want invalid argument", err)
+	}
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sparrow-community/app/cache/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+const (
+	CompressionNone   = "none"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+	// MaxDecodedSize bytes of the largest data a stored value decodes to, the larger values are rejected
+	MaxDecodedSize = 64 << 20
+	// encodedPrefix marks the values stored by the value service, the json metadata follows up to a 0 byte,
+	// then the payload, the values set as is cannot start with it
+	encodedPrefix = "encoded\x00"
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecodedSize))
+)
+
+type ValueSetRequest struct {
+	Key string `json:"key"`
+	// Data of the value, base64 in json
+	Data []byte `json:"data"`
+	// ContentType of Data, returned to the readers, e.g. application/json
+	ContentType string `json:"content_type"`
+	// Compression zstd, snappy or none, empty compresses with the configured compression above its threshold
+	Compression string `json:"compression"`
+	// Mode nx only sets a missing key, xx only an existing one, empty sets it anyway
+	Mode string `json:"mode"`
+	// TtlMs time to live in milliseconds, 0 never expires
+	TtlMs int64 `json:"ttl_ms"`
+}
+
+type ValueSetResponse struct {
+	// Set is false when the mode did not hold
+	Set bool `json:"set"`
+	// Compression of the stored value, none when it is stored as is
+	Compression string `json:"compression"`
+	// StoredSize bytes of the stored value, its metadata included
+	StoredSize int64 `json:"stored_size"`
+}
+
+type ValueGetRequest struct {
+	Key string `json:"key"`
+}
+
+type ValueGetResponse struct {
+	// Found is false for a missing key
+	Found bool   `json:"found"`
+	Data  []byte `json:"data,omitempty"`
+	// ContentType, Compression and CreatedMs, the unix milliseconds of the set, are empty for the values
+	// not set through the value service
+	ContentType string `json:"content_type,omitempty"`
+	Compression string `json:"compression,omitempty"`
+	CreatedMs   int64  `json:"created_ms,omitempty"`
+	// TtlMs remaining milliseconds, -1 when the key does not expire
+	TtlMs int64 `json:"ttl_ms,omitempty"`
+}
+
+// ValueOptions encoding of the values, values of at least Threshold bytes are compressed with Compression,
+// a 0 threshold compresses none, values longer than MaxSize bytes are rejected, 0 is up to MaxDecodedSize
+type ValueOptions struct {
+	Compression string
+	Threshold   int64
+	MaxSize     int64
+}
+
+// meta of an encoded value, stored before its payload
+type meta struct {
+	ContentType string `json:"t,omitempty"`
+	Compression string `json:"c,omitempty"`
+	Created     int64  `json:"at"`
+	// Size of the data before compression
+	Size int64 `json:"n"`
+}
+
+// ValueService sets and reads binary values with a content type, compressed above a threshold, the other
+// services read the data of these values, not their encoding, it only answers the application/grpc+json codec
+// since its messages have no proto
+type ValueService struct {
+	store   store.Store
+	options ValueOptions
+}
+
+func NewValueService(s store.Store, opts ValueOptions) *ValueService {
+	return &ValueService{store: s, options: opts}
+}
+
+func (v *ValueService) Set(ctx context.Context, request *ValueSetRequest, response *ValueSetResponse) error {
+	mode := store.SetMode(request.Mode)
+	switch {
+	case mode != store.SetAlways && mode != store.SetNX && mode != store.SetXX:
+		return status.Errorf(codes.InvalidArgument, "unknown mode %s", request.Mode)
+	case request.TtlMs < 0:
+		return status.Errorf(codes.InvalidArgument, "ttl_ms is negative")
+	case int64(len(request.Data)) > v.maxSize():
+		return status.Errorf(codes.InvalidArgument, "value of %d bytes exceeds the maximum of %d", len(request.Data), v.maxSize())
+	}
+	compression := request.Compression
+	if compression == "" {
+		compression = CompressionNone
+		if v.options.Threshold > 0 && int64(len(request.Data)) >= v.options.Threshold {
+			compression = v.options.Compression
+		}
+	}
+	value, compression, err := encodeValue(request.Data, request.ContentType, compression, time.Now())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	res, err := v.store.SetArgs(ctx, request.Key, value, store.SetArgs{
+		Mode: mode,
+		TTL:  time.Duration(request.TtlMs) * time.Millisecond,
+	})
+	if err != nil {
+		return storeError("set", err)
+	}
+	response.Set = res.Set
+	response.Compression = compression
+	response.StoredSize = int64(len(value))
+	return nil
+}
+
+func (v *ValueService) Get(ctx context.Context, request *ValueGetRequest, response *ValueGetResponse) error {
+	value, d, err := v.store.Get(ctx, request.Key)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return storeError("get", err)
+	}
+	data, m, err := decodeValue(value, v.maxSize())
+	if err != nil {
+		return status.Errorf(codes.DataLoss, "value of %s is corrupted: %s", request.Key, err)
+	}
+	response.Found = true
+	response.Data = data
+	response.TtlMs = ttlMs(d)
+	if m != nil {
+		response.ContentType = m.ContentType
+		response.Compression = m.Compression
+		response.CreatedMs = m.Created
+	}
+	return nil
+}
+
+// maxSize returns the largest data of a value
+func (v *ValueService) maxSize() int64 {
+	if v.options.MaxSize > 0 && v.options.MaxSize < MaxDecodedSize {
+		return v.options.MaxSize
+	}
+	return MaxDecodedSize
+}
+
+// encodeValue returns the stored value of data compressed with compression and the compression it kept,
+// none when the compressed data is not smaller
+func encodeValue(data []byte, contentType string, compression string, now time.Time) (string, string, error) {
+	payload := data
+	switch compression {
+	case CompressionNone:
+	case CompressionZstd:
+		payload = zstdEncoder.EncodeAll(data, nil)
+	case CompressionSnappy:
+		payload = s2.EncodeSnappy(nil, data)
+	default:
+		return "", "", fmt.Errorf("unknown compression %s", compression)
+	}
+	if len(payload) >= len(data) {
+		payload, compression = data, CompressionNone
+	}
+	m := meta{ContentType: contentType, Created: now.UnixMilli(), Size: int64(len(data))}
+	if compression != CompressionNone {
+		m.Compression = compression
+	}
+	header, _ := json.Marshal(m)
+	var b strings.Builder
+	b.Grow(len(encodedPrefix) + len(header) + 1 + len(payload))
+	b.WriteString(encodedPrefix)
+	b.Write(header)
+	b.WriteByte(0)
+	b.Write(payload)
+	return b.String(), compression, nil
+}
+
+// decodeValue returns the data and the metadata of a stored value, no metadata for a value that was not
+// encoded, it fails before decoding data larger than max
+func decodeValue(v string, max int64) ([]byte, *meta, error) {
+	if !strings.HasPrefix(v, encodedPrefix) {
+		return []byte(v), nil, nil
+	}
+	rest := []byte(strings.TrimPrefix(v, encodedPrefix))
+	// json escapes the 0 bytes, the first one ends the metadata
+	end := bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return nil, nil, fmt.Errorf("no metadata end")
+	}
+	var m meta
+	if err := json.Unmarshal(rest[:end], &m); err != nil {
+		return nil, nil, err
+	}
+	if m.Size < 0 || m.Size > max {
+		return nil, nil, fmt.Errorf("size %d out of 0 to %d", m.Size, max)
+	}
+	payload := rest[end+1:]
+	var data []byte
+	var err error
+	switch m.Compression {
+	case "":
+		data = payload
+	case CompressionZstd:
+		data, err = zstdDecoder.DecodeAll(payload, make([]byte, 0, m.Size))
+	case CompressionSnappy:
+		if n, derr := s2.DecodedLen(payload); derr != nil || int64(n) != m.Size {
+			return nil, nil, fmt.Errorf("snappy length mismatch")
+		}
+		data, err = s2.Decode(nil, payload)
+	default:
+		return nil, nil, fmt.Errorf("unknown compression %s", m.Compression)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(data)) != m.Size {
+		return nil, nil, fmt.Errorf("size %d, want %d", len(data), m.Size)
+	}
+	return data, &m, nil
+}
+
+// plain returns the data of a stored value as a string, the values set by the value service are decoded
+func plain(key string, v string) (string, error) {
+	if !strings.HasPrefix(v, encodedPrefix) {
+		return v, nil
+	}
+	data, _, err := decodeValue(v, MaxDecodedSize)
+	if err != nil {
+		return "", status.Errorf(codes.DataLoss, "value of %s is corrupted: %s", key, err)
+	}
+	return string(data), nil
+}
+
+// decoded replaces the value of a get result by its plain data, a corrupted value fails the result alone
+func decoded(key string, r store.Result) store.Result {
+	if r.Err != nil || !strings.HasPrefix(r.Value, encodedPrefix) {
+		return r
+	}
+	data, _, err := decodeValue(r.Value, MaxDecodedSize)
+	if err != nil {
+		r.Value, r.Err = "", fmt.Errorf("value of %s is corrupted: %w", key, err)
+		return r
+	}
+	r.Value = string(data)
+	return r
+}
+
+// raw rejects a value set as is that would read as a value of the value service
+func raw(key string, v string) error {
+	if strings.HasPrefix(v, encodedPrefix) {
+		return status.Errorf(codes.InvalidArgument, "value of %s starts with the reserved prefix %q", key, encodedPrefix)
+	}
+	return nil
+}
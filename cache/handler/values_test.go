@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/protos/cache"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueService(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	v := NewValueService(s, ValueOptions{Compression: CompressionZstd, Threshold: 100, MaxSize: 1 << 20})
+	ctx := context.Background()
+	large := bytes.Repeat([]byte(`{"name":"value"}`), 100)
+	var err error
+
+	for _, c := range []struct {
+		name        string
+		data        []byte
+		compression string
+		want        string
+	}{
+		{"small", []byte{0, 1, 2, 0xff}, "", CompressionNone},
+		{"large", large, "", CompressionZstd},
+		{"snappy", large, CompressionSnappy, CompressionSnappy},
+		{"none", large, CompressionNone, CompressionNone},
+	} {
+		var set ValueSetResponse
+		err = v.Set(ctx, &ValueSetRequest{Key: c.name, Data: c.data, ContentType: "application/json", Compression: c.compression}, &set)
+		if err != nil || !set.Set || set.Compression != c.want {
+			t.Fatalf("Set(%s) = %+v, %v, want %s", c.name, set, err, c.want)
+		}
+		if c.want != CompressionNone && set.StoredSize >= int64(len(c.data)) {
+			t.Fatalf("Set(%s) stored %d bytes of %d", c.name, set.StoredSize, len(c.data))
+		}
+		var got ValueGetResponse
+		if err := v.Get(ctx, &ValueGetRequest{Key: c.name}, &got); err != nil || !got.Found || !bytes.Equal(got.Data, c.data) {
+			t.Fatalf("Get(%s) = %+v, %v", c.name, got, err)
+		}
+		if got.ContentType != "application/json" || got.CreatedMs == 0 || (got.Compression != c.want && c.want != CompressionNone) {
+			t.Fatalf("Get(%s) metadata = %+v", c.name, got)
+		}
+		var out cache.GetResponse
+		if err := (Cache{Store: s}).Get(ctx, &cache.GetRequest{Key: c.name}, &out); err != nil || out.Value != string(c.data) {
+			t.Fatalf("Cache.Get(%s) = %q, %v, want the decoded data", c.name, out.Value, err)
+		}
+		var mget MGetResponse
+		if err := NewBatchService(s).MGet(ctx, &MGetRequest{Keys: []string{c.name}}, &mget); err != nil || mget.Results[0].Value != string(c.data) {
+			t.Fatalf("MGet(%s) = %+v, %v, want the decoded data", c.name, mget.Results, err)
+		}
+		var pipe PipelineResponse
+		err = NewBatchService(s).Pipeline(ctx, &PipelineRequest{Operations: []*Operation{{Op: "get", Key: c.name}}}, &pipe)
+		if err != nil || pipe.Results[0].Value != string(c.data) {
+			t.Fatalf("Pipeline(%s) = %+v, %v, want the decoded data", c.name, pipe.Results, err)
+		}
+	}
+
+	encoded, _, _ := encodeValue([]byte("forged"), "", CompressionNone, time.Now())
+	err = (Cache{Store: s}).Set(ctx, &cache.SetRequest{Key: "forged", Value: encoded}, &cache.SetResponse{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Cache.Set() encoded error = %v, want invalid argument", err)
+	}
+	err = NewStringService(s).Set(ctx, &StringSetRequest{Key: "forged", Value: encoded}, &StringSetResponse{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("StringService.Set() encoded error = %v, want invalid argument", err)
+	}
+	err = NewBatchService(s).MSet(ctx, &MSetRequest{Items: []*SetItem{{Key: "forged", Value: encoded}}}, &MSetResponse{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("MSet() encoded error = %v, want invalid argument", err)
+	}
+
+	if err := s.Set(ctx, "plain", "text", 0); err != nil {
+		t.Fatal(err)
+	}
+	var got ValueGetResponse
+	if err := v.Get(ctx, &ValueGetRequest{Key: "plain"}, &got); err != nil || string(got.Data) != "text" || got.CreatedMs != 0 {
+		t.Fatalf("Get() plain = %+v, %v", got, err)
+	}
+
+	err = v.Set(ctx, &ValueSetRequest{Key: "huge", Data: make([]byte, 1<<20+1)}, &ValueSetResponse{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Set() huge error = %v, want invalid argument", err)
+	}
+	err = v.Set(ctx, &ValueSetRequest{Key: "a", Data: large, Compression: "gzip"}, &ValueSetResponse{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Set() unknown compression error = %v, want invalid argument", err)
+	}
+}
+
+func TestValueCorrupted(t *testing.T) {
+	s := store.NewMemory(0, 0)
+	defer s.Close()
+	ctx := context.Background()
+	value, _, _ := encodeValue(bytes.Repeat([]byte("a"), 1000), "", CompressionZstd, time.Now())
+	if err := s.Set(ctx, "k", value[:len(value)-2], 0); err != nil {
+		t.Fatal(err)
+	}
+	err := NewValueService(s, ValueOptions{}).Get(ctx, &ValueGetRequest{Key: "k"}, &ValueGetResponse{})
+	if status.Code(err) != codes.DataLoss {
+		t.Fatalf("Get() corrupted error = %v, want data loss", err)
+	}
+
+	// a size beyond the maximum is rejected before the data is allocated
+	value, _, _ = encodeValue([]byte("a"), "", CompressionNone, time.Now())
+	value = strings.Replace(value, `"n":1`, `"n":1099511627776`, 1)
+	if err := s.Set(ctx, "huge", value, 0); err != nil {
+		t.Fatal(err)
+	}
+	err = NewValueService(s, ValueOptions{MaxSize: 1 << 20}).Get(ctx, &ValueGetRequest{Key: "huge"}, &ValueGetResponse{})
+	if status.Code(err) != codes.DataLoss {
+		t.Fatalf("Get() huge size error = %v, want data loss", err)
+	}
+	var mget MGetResponse
+	if err := NewBatchService(s).MGet(ctx, &MGetRequest{Keys: []string{"huge"}}, &mget); err != nil || mget.Results[0].Error == "" {
+		t.Fatalf("MGet() huge size = %+v, %v, want a failed result", mget.Results, err)
+	}
+}
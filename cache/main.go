@@ -3,14 +3,21 @@ package main
 import (
 	mcgrpc "github.com/go-micro/plugins/v4/client/grpc"
 	msgrpc "github.com/go-micro/plugins/v4/server/grpc"
+	mhttp "github.com/go-micro/plugins/v4/server/http"
 	"github.com/sparrow-community/app/cache/config"
 	"github.com/sparrow-community/app/cache/handler"
+	"github.com/sparrow-community/app/cache/metrics"
+	"github.com/sparrow-community/app/cache/namespace"
+	"github.com/sparrow-community/app/cache/store"
+	"github.com/sparrow-community/app/cache/web"
 	"github.com/sparrow-community/pkgs/listener"
 	"github.com/sparrow-community/protos/cache"
 	"go-micro.dev/v4"
 	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
 	"go-micro.dev/v4/server"
 	"net"
+	"net/http"
 )
 
 var (
@@ -29,6 +36,18 @@ func main() {
 
 	logger.Infof("%s %s %s %s", Version, Commit, Date, BuiltBy)
 
+	st, err := config.Conf.OpenStore()
+	if err != nil {
+		logger.Errorf("open %s store error: %v", config.Conf.Store.Type, err)
+		return
+	}
+	defer st.Close()
+	admin := handler.Admin{Store: st, Token: config.Conf.Admin.Token}
+	if n, ok := st.(*namespace.Store); ok {
+		admin.Store, admin.Namespaces = n.Unscoped(), true
+	}
+	st = metrics.WithLookups(st)
+
 	lst, err := listener.New(
 		listener.WithAddress(config.Conf.Server.Address),
 	)
@@ -36,30 +55,90 @@ func main() {
 		logger.Errorf("error creating listener: %v", err)
 	}
 
+	cl := mcgrpc.NewClient()
 	opts := []micro.Option{
 		micro.Name(config.Conf.Server.Name),
-		micro.Client(mcgrpc.NewClient()),
+		micro.Client(cl),
 		micro.Version(Version),
 	}
 
-	go grpcServer(lst.Grpc(), opts...)
+	// the http api serves the cache of the grpc server, with its namespaces and loaders
+	loaders := handler.NewLoaderService(st, cl, config.Conf.LoaderOptions())
+	c := &handler.Cache{Store: st, Loader: loaders}
+
+	go httpServer(lst.Http(), c, admin, opts...)
+
+	tc, err := config.Conf.TLSConfig()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if tc == nil {
+		go grpcServer(lst.Grpc(), st, c, nil, opts...)
+	} else {
+		// tls connections cannot be multiplexed, grpc listens on its own address
+		tlsLst, err := net.Listen("tcp", config.Conf.TLS.Address)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		go grpcServer(tlsLst, st, c, []server.Option{msgrpc.AuthTLS(tc)}, opts...)
+	}
 
 	_ = lst.Serve()
 }
 
-func grpcServer(lst net.Listener, opts ...micro.Option) {
-	grpcOpts := append(opts, micro.Server(msgrpc.NewServer(
+// httpServer serves the metrics, the api and the admin ui, it is kept out of the registry so the gateway
+// never routes to it
+func httpServer(lst net.Listener, c *handler.Cache, admin handler.Admin, opts ...micro.Option) {
+	prefix := "/" + config.Conf.Server.Name
+	m := http.NewServeMux()
+	m.Handle("/metrics", metrics.Handler())
+	m.Handle(prefix+"/", handler.NewHttpHandler(prefix, c, config.Conf.Api.Credentials, admin, web.UI()))
+
+	httpServer := mhttp.NewServer(
+		server.Name(config.Conf.Server.Name),
+		server.Registry(registry.NewMemoryRegistry()),
+		mhttp.Listener(lst),
+	)
+	if err := httpServer.Handle(httpServer.NewHandler(m)); err != nil {
+		logger.Errorf("error creating http server: %v", err)
+	}
+	httpOpts := append(opts, micro.Server(httpServer))
+	srv := micro.NewService(httpOpts...)
+	if err := srv.Run(); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func grpcServer(lst net.Listener, st store.Store, c *handler.Cache, serverOpts []server.Option, opts ...micro.Option) {
+	serverOpts = append(serverOpts,
 		server.Name(config.Conf.Server.Name),
 		msgrpc.Listener(lst),
-	)))
+		server.WrapHandler(metrics.Wrapper),
+	)
+	grpcOpts := append(opts, micro.Server(msgrpc.NewServer(serverOpts...)))
 	srv := micro.NewService(grpcOpts...)
 
-	cacheHandler := &handler.Cache{
-		Client: config.Conf.RedisClient,
-	}
-	if err := cache.RegisterCacheHandler(srv.Server(), cacheHandler); err != nil {
+	if err := cache.RegisterCacheHandler(srv.Server(), c); err != nil {
 		logger.Fatal(err)
 	}
+	for _, h := range []interface{}{
+		handler.NewKeyService(st),
+		handler.NewStringService(st),
+		handler.NewBatchService(st),
+		handler.NewLockService(st),
+		handler.NewPubSubService(st),
+		handler.NewListService(st),
+		handler.NewSetService(st),
+		handler.NewSortedSetService(st),
+		handler.NewStreamService(st),
+		c.Loader,
+		handler.NewValueService(st, config.Conf.ValueOptions()),
+		handler.NewHealth(st, config.Conf.Server.Name),
+	} {
+		if err := srv.Server().Handle(srv.Server().NewHandler(h)); err != nil {
+			logger.Fatal(err)
+		}
+	}
 
 	if err := srv.Run(); err != nil {
 		logger.Fatal(err)
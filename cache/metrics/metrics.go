@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/server"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// Registry of the cache metrics, with the go runtime and the process metrics
+	Registry = prometheus.NewRegistry()
+
+	latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cache",
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of the rpcs by endpoint.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"endpoint"})
+	failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cache",
+		Name:      "rpc_errors_total",
+		Help:      "Failed rpcs by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+	lookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cache",
+		Name:      "lookups_total",
+		Help:      "Reads of string values and hash fields by result, hit or miss.",
+	}, []string{"result"})
+	commands = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cache",
+		Name:      "redis_command_duration_seconds",
+		Help:      "Latency of the redis commands and pipelines by command.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"command"})
+	slow = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cache",
+		Name:      "redis_slow_commands_total",
+		Help:      "Redis commands slower than the slow log threshold.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		latency, failures, lookups, commands, slow,
+	)
+}
+
+// Handler serves the metrics of Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Wrapper measures the latency and the errors of every rpc, the code of an error is its grpc status code,
+// Unknown for the errors without a status
+func Wrapper(fn server.HandlerFunc) server.HandlerFunc {
+	return func(ctx context.Context, req server.Request, rsp interface{}) error {
+		start := time.Now()
+		err := fn(ctx, req, rsp)
+		latency.WithLabelValues(req.Endpoint()).Observe(time.Since(start).Seconds())
+		if err != nil {
+			failures.WithLabelValues(req.Endpoint(), status.Code(err).String()).Inc()
+		}
+		return err
+	}
+}
+
+// WithLookups returns s counting the hits and the misses of its reads of string values and hash fields
+func WithLookups(s store.Store) store.Store {
+	return &lookupStore{Store: s}
+}
+
+type lookupStore struct {
+	store.Store
+}
+
+// lookup counts the read of a key or a field, a missing one is a miss
+func lookup(err error) {
+	switch {
+	case err == nil:
+		lookups.WithLabelValues("hit").Inc()
+	case errors.Is(err, store.ErrNotFound):
+		lookups.WithLabelValues("miss").Inc()
+	}
+}
+
+func (s *lookupStore) Get(ctx context.Context, key string) (string, time.Duration, error) {
+	v, ttl, err := s.Store.Get(ctx, key)
+	lookup(err)
+	return v, ttl, err
+}
+
+func (s *lookupStore) HGet(ctx context.Context, key string, field string) (string, error) {
+	v, err := s.Store.HGet(ctx, key, field)
+	lookup(err)
+	return v, err
+}
+
+func (s *lookupStore) Exec(ctx context.Context, ops []store.Op, atomic bool) ([]store.Result, error) {
+	results, err := s.Store.Exec(ctx, ops, atomic)
+	if err != nil {
+		return results, err
+	}
+	for i, op := range ops {
+		if op.Kind == store.OpGet || op.Kind == store.OpHGet {
+			lookup(results[i].Err)
+		}
+	}
+	return results, nil
+}
+
+// blocking commands wait for data on purpose, they are not logged as slow
+var blocking = map[string]bool{
+	"blpop":      true,
+	"brpop":      true,
+	"xread":      true,
+	"xreadgroup": true,
+}
+
+// Redis measures the commands of client and reports its connection pool, the commands slower than
+// threshold are logged with their name and first key, never with their values, 0 logs none
+func Redis(client redis.UniversalClient, threshold time.Duration) {
+	client.AddHook(redisHook{threshold: threshold})
+	Registry.MustRegister(newPoolCollector(client))
+}
+
+type redisHook struct {
+	threshold time.Duration
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), time.Since(start), cmd)
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe("pipeline", time.Since(start), cmds...)
+		return err
+	}
+}
+
+func (h redisHook) observe(name string, d time.Duration, cmds ...redis.Cmder) {
+	commands.WithLabelValues(name).Observe(d.Seconds())
+	if h.threshold <= 0 || d < h.threshold {
+		return
+	}
+	var keys []string
+	for _, cmd := range cmds {
+		if blocking[cmd.Name()] {
+			return
+		}
+		keys = append(keys, cmd.Name()+" "+firstKey(cmd))
+	}
+	slow.Inc()
+	logger.Warnf("slow redis %s took %s: %s", name, d, strings.Join(keys, ", "))
+}
+
+// firstKey returns the first argument after the name of cmd, the key of most commands
+func firstKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	if s, ok := args[1].(string); ok {
+		return s
+	}
+	return ""
+}
+
+// poolCollector reports the connection pool statistics of a redis client
+type poolCollector struct {
+	client   redis.UniversalClient
+	hits     *prometheus.Desc
+	misses   *prometheus.Desc
+	timeouts *prometheus.Desc
+	stale    *prometheus.Desc
+	total    *prometheus.Desc
+	idle     *prometheus.Desc
+}
+
+func newPoolCollector(client redis.UniversalClient) *poolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc("cache_redis_pool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		client:   client,
+		hits:     desc("hits_total", "Free connections found in the pool."),
+		misses:   desc("misses_total", "Free connections not found in the pool."),
+		timeouts: desc("timeouts_total", "Waits for a connection that timed out."),
+		stale:    desc("stale_connections_total", "Stale connections removed from the pool."),
+		total:    desc("connections", "Connections of the pool."),
+		idle:     desc("idle_connections", "Idle connections of the pool."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.hits, c.misses, c.timeouts, c.stale, c.total, c.idle} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.stale, prometheus.CounterValue, float64(s.StaleConns))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns))
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"testing"
+)
+
+// counted returns the lookups of result counted so far
+func counted(t *testing.T, result string) float64 {
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "cache_lookups_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == result {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestWithLookups(t *testing.T) {
+	m := store.NewMemory(0, 0)
+	defer m.Close()
+	s := WithLookups(m)
+	ctx := context.Background()
+	hits, misses := counted(t, "hit"), counted(t, "miss")
+
+	if err := s.Set(ctx, "a", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	_, _, _ = s.Get(ctx, "a")
+	_, _, _ = s.Get(ctx, "b")
+	_, _ = s.Exec(ctx, []store.Op{{Kind: store.OpGet, Key: "a"}, {Kind: store.OpHGet, Key: "h", Field: "f"}, {Kind: store.OpSet, Key: "c"}}, false)
+
+	if got := counted(t, "hit") - hits; got != 2 {
+		t.Fatalf("hits = %v, want 2", got)
+	}
+	if got := counted(t, "miss") - misses; got != 2 {
+		t.Fatalf("misses = %v, want 2", got)
+	}
+}
@@ -0,0 +1,59 @@
+package namespace
+
+import (
+	"context"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// FromServiceKey metadata the go-micro clients of a service set to its name
+const FromServiceKey = "Micro-From-Service"
+
+type callerKey struct{}
+
+// WithCaller returns ctx of a call of the service name, authenticated by its transport, like the http api
+// credentials
+func WithCaller(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, callerKey{}, name)
+}
+
+// Caller returns the service name of the caller of ctx, the name given to WithCaller, the common name of its
+// verified client certificate or, when trust is set, the service name its go-micro client sent
+func Caller(ctx context.Context, trust bool) (string, bool) {
+	if name, ok := ctx.Value(callerKey{}).(string); ok && name != "" {
+		return name, true
+	}
+	if name, ok := certificateName(ctx); ok {
+		return name, true
+	}
+	if !trust {
+		return "", false
+	}
+	name, ok := metadata.Get(ctx, FromServiceKey)
+	return name, ok && name != ""
+}
+
+// Of returns the namespace a request of ctx reads, the one its metadata names or the one of its caller, "" when the
+// caller is unknown, the store checks the caller may read it
+func Of(ctx context.Context, trust bool) string {
+	if target, _ := metadata.Get(ctx, NamespaceKey); target != "" {
+		return target
+	}
+	caller, _ := Caller(ctx, trust)
+	return caller
+}
+
+// certificateName returns the common name of a verified client certificate
+func certificateName(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	return name, name != ""
+}
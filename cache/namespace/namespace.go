@@ -0,0 +1,644 @@
+package namespace
+
+import (
+	"context"
+	"errors"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errSkipped releases the reservation of a write the store skipped
+var errSkipped = errors.New("write skipped")
+
+const (
+	// NamespaceKey request metadata of the namespace a caller reads instead of its own
+	NamespaceKey = "Cache-Namespace"
+	// Separator between the namespace and the key of the stored keys
+	Separator = ":"
+	// LegacyPrefix of the keys the services stored under cache:<service>: before the namespaces, a read of such a
+	// key missing from the namespace of the service falls back to the unprefixed key, until the service writes it
+	LegacyPrefix = "cache:"
+)
+
+// Tenant settings of the namespace of a service
+type Tenant struct {
+	// Service name of the callers of the namespace
+	Service string `json:"service"`
+	// Read other namespaces the service may read, '*' allows every namespace
+	Read []string `json:"read"`
+	// Keys quota of the namespace, 0 is unlimited
+	Keys int64 `json:"keys"`
+	// Memory quota in bytes of the namespace, 0 is unlimited
+	Memory int64 `json:"memory"`
+}
+
+func (t Tenant) readable(namespace string) bool {
+	for _, n := range t.Read {
+		if n == "*" || n == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Tenant) limited() bool {
+	return t.Keys > 0 || t.Memory > 0
+}
+
+type Options struct {
+	Tenants []Tenant
+	// Keys, Memory quotas of the services without a tenant
+	Keys   int64
+	Memory int64
+	// TrustFromService names the callers without a client certificate from the metadata of their client
+	TrustFromService bool
+	// Interval between two measures of the usage of the namespaces with a quota
+	Interval time.Duration
+}
+
+// Store keeps the keys of every caller under its namespace, the name of its service, so callers only see
+// their own keys, the usage of a namespace is measured every interval and tracked in between,
+// so concurrent replicas of the service may exceed a quota until the next measure
+type Store struct {
+	sync.Mutex
+	store   store.Store
+	options Options
+	tenants map[string]Tenant
+	usage   map[string]*usage
+	exit    chan bool
+	once    sync.Once
+}
+
+type usage struct {
+	keys   int64
+	memory int64
+}
+
+// New isolates the callers of s
+func New(s store.Store, opts Options) *Store {
+	n := &Store{
+		store:   s,
+		options: opts,
+		tenants: map[string]Tenant{},
+		usage:   map[string]*usage{},
+		exit:    make(chan bool),
+	}
+	for _, t := range opts.Tenants {
+		n.tenants[t.Service] = t
+	}
+	if opts.Interval > 0 {
+		go n.run()
+	}
+	return n
+}
+
+func (n *Store) run() {
+	ticker := time.NewTicker(n.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.exit:
+			return
+		case <-ticker.C:
+			n.Lock()
+			namespaces := make([]string, 0, len(n.usage))
+			for ns := range n.usage {
+				namespaces = append(namespaces, ns)
+			}
+			n.Unlock()
+			for _, ns := range namespaces {
+				u, err := n.measure(context.Background(), ns)
+				if err != nil {
+					continue
+				}
+				n.Lock()
+				n.usage[ns] = u
+				n.Unlock()
+			}
+		}
+	}
+}
+
+// scope returns the namespace of a request on keys and the tenant of its caller, writes only go to the caller
+// namespace, the keys of the locks are only reached through the locks
+func (n *Store) scope(ctx context.Context, write bool, keys ...string) (string, Tenant, error) {
+	for _, key := range keys {
+		if strings.HasPrefix(key, store.LockPrefix) {
+			return "", Tenant{}, status.Errorf(codes.PermissionDenied, "%s is the key of a lock", key)
+		}
+	}
+	caller, ok := Caller(ctx, n.options.TrustFromService)
+	if !ok {
+		return "", Tenant{}, status.Errorf(codes.Unauthenticated, "unknown caller service")
+	}
+	if strings.Contains(caller, Separator) {
+		return "", Tenant{}, status.Errorf(codes.PermissionDenied, "invalid namespace %s", caller)
+	}
+	t, ok := n.tenants[caller]
+	if !ok {
+		t = Tenant{Service: caller, Keys: n.options.Keys, Memory: n.options.Memory}
+	}
+	target, _ := metadata.Get(ctx, NamespaceKey)
+	if target == "" || target == caller {
+		return caller, t, nil
+	}
+	if write {
+		return "", t, status.Errorf(codes.PermissionDenied, "%s cannot write the namespace %s", caller, target)
+	}
+	if !t.readable(target) {
+		return "", t, status.Errorf(codes.PermissionDenied, "%s cannot read the namespace %s", caller, target)
+	}
+	return target, t, nil
+}
+
+// legacy reports whether the service of the namespace ns may have stored key before the namespaces
+func legacy(ns string, key string) bool {
+	return strings.HasPrefix(key, LegacyPrefix+ns+Separator)
+}
+
+// reserve adds keys and bytes to the usage of the namespace, it fails when it exceeds a quota of the tenant
+func (n *Store) reserve(ctx context.Context, ns string, t Tenant, keys int64, bytes int64) error {
+	n.Lock()
+	u, ok := n.usage[ns]
+	n.Unlock()
+	if !ok {
+		measured, err := n.measure(ctx, ns)
+		if err != nil {
+			return err
+		}
+		n.Lock()
+		if u, ok = n.usage[ns]; !ok {
+			u = measured
+			n.usage[ns] = u
+		}
+		n.Unlock()
+	}
+	n.Lock()
+	defer n.Unlock()
+	if t.Keys > 0 && keys > 0 && u.keys+keys > t.Keys {
+		return status.Errorf(codes.ResourceExhausted, "namespace %s exceeds its quota of %d keys", ns, t.Keys)
+	}
+	if t.Memory > 0 && bytes > 0 && u.memory+bytes > t.Memory {
+		return status.Errorf(codes.ResourceExhausted, "namespace %s exceeds its quota of %d bytes", ns, t.Memory)
+	}
+	u.keys += keys
+	u.memory += bytes
+	return nil
+}
+
+// release gives back keys and bytes of a reservation
+func (n *Store) release(ns string, keys int64, bytes int64) {
+	n.Lock()
+	defer n.Unlock()
+	if u, ok := n.usage[ns]; ok {
+		u.keys -= keys
+		u.memory -= bytes
+	}
+}
+
+// measure counts the keys and the bytes of the namespace
+func (n *Store) measure(ctx context.Context, ns string) (*usage, error) {
+	u := &usage{}
+	cursor := ""
+	for {
+		keys, next, err := n.store.Scan(ctx, cursor, store.Escape(ns+Separator)+"*", 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			size, err := n.store.Size(ctx, k)
+			if err != nil {
+				return nil, err
+			}
+			u.keys++
+			u.memory += size
+		}
+		if next == "" {
+			return u, nil
+		}
+		cursor = next
+	}
+}
+
+// write reserves the usage of a write of key, size estimates the bytes of the key after the write,
+// the returned function releases the reservation when the write fails
+func (n *Store) write(ctx context.Context, ns string, t Tenant, key string, size func(old int64) int64) (func(error), error) {
+	if !t.limited() {
+		return func(error) {}, nil
+	}
+	old, err := n.store.Size(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var keys int64
+	if old == 0 {
+		keys = 1
+	}
+	bytes := size(old) - old
+	if err := n.reserve(ctx, ns, t, keys, bytes); err != nil {
+		return nil, err
+	}
+	return func(err error) {
+		if err != nil {
+			n.release(ns, keys, bytes)
+		}
+	}, nil
+}
+
+func (n *Store) Get(ctx context.Context, key string) (string, time.Duration, error) {
+	ns, _, err := n.scope(ctx, false, key)
+	if err != nil {
+		return "", 0, err
+	}
+	v, ttl, err := n.store.Get(ctx, ns+Separator+key)
+	if err == store.ErrNotFound && legacy(ns, key) {
+		return n.store.Get(ctx, key)
+	}
+	return v, ttl, err
+}
+
+func (n *Store) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	ns, t, err := n.scope(ctx, true, key)
+	if err != nil {
+		return err
+	}
+	key = ns + Separator + key
+	done, err := n.write(ctx, ns, t, key, estimate(store.Op{Kind: store.OpSet, Key: key, Value: value}))
+	if err != nil {
+		return err
+	}
+	err = n.store.Set(ctx, key, value, ttl)
+	done(err)
+	return err
+}
+
+// SetArgs reserves the write like Set, the reservation is given back when the condition fails
+func (n *Store) SetArgs(ctx context.Context, key string, value string, args store.SetArgs) (store.SetResult, error) {
+	ns, t, err := n.scope(ctx, true, key)
+	if err != nil {
+		return store.SetResult{}, err
+	}
+	key = ns + Separator + key
+	done, err := n.write(ctx, ns, t, key, estimate(store.Op{Kind: store.OpSet, Key: key, Value: value}))
+	if err != nil {
+		return store.SetResult{}, err
+	}
+	res, err := n.store.SetArgs(ctx, key, value, args)
+	if err == nil && !res.Set {
+		done(errSkipped)
+	} else {
+		done(err)
+	}
+	return res, err
+}
+
+// Expire of a ttl of 0 or less deletes key, its usage is released at the next measure
+func (n *Store) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ns, _, err := n.scope(ctx, true, key)
+	if err != nil {
+		return false, err
+	}
+	return n.store.Expire(ctx, ns+Separator+key, ttl)
+}
+
+func (n *Store) Persist(ctx context.Context, key string) (bool, error) {
+	ns, _, err := n.scope(ctx, true, key)
+	if err != nil {
+		return false, err
+	}
+	return n.store.Persist(ctx, ns+Separator+key)
+}
+
+func (n *Store) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ns, _, err := n.scope(ctx, false, key)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.TTL(ctx, ns+Separator+key)
+}
+
+func (n *Store) Delete(ctx context.Context, keys ...string) (int64, error) {
+	ns, t, err := n.scope(ctx, true, keys...)
+	if err != nil {
+		return 0, err
+	}
+	var bytes int64
+	stored := make([]string, len(keys))
+	for i, key := range keys {
+		stored[i] = ns + Separator + key
+		if t.limited() {
+			size, err := n.store.Size(ctx, stored[i])
+			if err != nil {
+				return 0, err
+			}
+			bytes += size
+		}
+	}
+	deleted, err := n.store.Delete(ctx, stored...)
+	if err == nil && t.limited() {
+		n.release(ns, deleted, bytes)
+	}
+	return deleted, err
+}
+
+func (n *Store) IncrBy(ctx context.Context, key string, by int64) (int64, error) {
+	ns, t, err := n.scope(ctx, true, key)
+	if err != nil {
+		return 0, err
+	}
+	key = ns + Separator + key
+	done, err := n.write(ctx, ns, t, key, estimate(store.Op{Kind: store.OpIncrBy, Key: key}))
+	if err != nil {
+		return 0, err
+	}
+	v, err := n.store.IncrBy(ctx, key, by)
+	done(err)
+	return v, err
+}
+
+// Scan only returns keys of the namespace, without their namespace
+func (n *Store) Scan(ctx context.Context, cursor string, pattern string, count int64) ([]string, string, error) {
+	ns, _, err := n.scope(ctx, false)
+	if err != nil {
+		return nil, "", err
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	prefix := ns + Separator
+	keys, next, err := n.store.Scan(ctx, cursor, store.Escape(prefix)+pattern, count)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, prefix)
+	}
+	return keys, next, nil
+}
+
+func (n *Store) HGet(ctx context.Context, key string, field string) (string, error) {
+	ns, _, err := n.scope(ctx, false, key)
+	if err != nil {
+		return "", err
+	}
+	v, err := n.store.HGet(ctx, ns+Separator+key, field)
+	if err == store.ErrNotFound && legacy(ns, key) {
+		// the field of a hash written in the namespace is not looked up in the legacy one
+		if size, err := n.store.Size(ctx, ns+Separator+key); err != nil {
+			return "", err
+		} else if size > 0 {
+			return "", store.ErrNotFound
+		}
+		return n.store.HGet(ctx, key, field)
+	}
+	return v, err
+}
+
+func (n *Store) HSet(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	ns, t, err := n.scope(ctx, true, key)
+	if err != nil {
+		return 0, err
+	}
+	key = ns + Separator + key
+	done, err := n.write(ctx, ns, t, key, estimate(store.Op{Kind: store.OpHSet, Key: key, Fields: fields}))
+	if err != nil {
+		return 0, err
+	}
+	added, err := n.store.HSet(ctx, key, fields)
+	done(err)
+	return added, err
+}
+
+func (n *Store) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	ns, _, err := n.scope(ctx, false, key)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := n.store.HGetAll(ctx, ns+Separator+key)
+	if err == nil && len(fields) == 0 && legacy(ns, key) {
+		return n.store.HGetAll(ctx, key)
+	}
+	return fields, err
+}
+
+// Exec runs ops in the namespace of the caller, an op exceeding a quota fails alone unless ops are atomic
+func (n *Store) Exec(ctx context.Context, ops []store.Op, atomic bool) ([]store.Result, error) {
+	writes := false
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		writes = writes || op.Writes()
+		keys[i] = op.Key
+	}
+	ns, t, err := n.scope(ctx, writes, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]store.Result, len(ops))
+	// index of the ops that run, their reservation and the size of the deleted keys
+	var run []int
+	var stored []store.Op
+	dones := make([]func(error), len(ops))
+	sizes := make([]int64, len(ops))
+	cancel := func(err error) {
+		for _, done := range dones {
+			if done != nil {
+				done(err)
+			}
+		}
+	}
+	for i, op := range ops {
+		op.Key = ns + Separator + op.Key
+		switch {
+		case !t.limited() || !op.Writes():
+		case op.Kind == store.OpDelete:
+			if sizes[i], err = n.store.Size(ctx, op.Key); err != nil {
+				cancel(err)
+				return nil, err
+			}
+		default:
+			done, err := n.write(ctx, ns, t, op.Key, estimate(op))
+			if err != nil && (atomic || status.Code(err) != codes.ResourceExhausted) {
+				cancel(err)
+				return nil, err
+			}
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			dones[i] = done
+		}
+		run = append(run, i)
+		stored = append(stored, op)
+	}
+
+	ran, err := n.store.Exec(ctx, stored, atomic)
+	if err != nil {
+		cancel(err)
+		return nil, err
+	}
+	for j, i := range run {
+		results[i] = ran[j]
+		if dones[i] != nil {
+			dones[i](ran[j].Err)
+		}
+		if ops[i].Kind == store.OpDelete && t.limited() && ran[j].Err == nil {
+			n.release(ns, ran[j].Int, sizes[i])
+		}
+	}
+	return results, nil
+}
+
+func (n *Store) Size(ctx context.Context, key string) (int64, error) {
+	ns, _, err := n.scope(ctx, false, key)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.Size(ctx, ns+Separator+key)
+}
+
+func (n *Store) Acquire(ctx context.Context, name string, owner string, ttl time.Duration) (int64, error) {
+	ns, _, err := n.scope(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.Acquire(ctx, ns+Separator+name, owner, ttl)
+}
+
+func (n *Store) Renew(ctx context.Context, name string, owner string, ttl time.Duration) error {
+	ns, _, err := n.scope(ctx, true)
+	if err != nil {
+		return err
+	}
+	return n.store.Renew(ctx, ns+Separator+name, owner, ttl)
+}
+
+func (n *Store) Release(ctx context.Context, name string, owner string) error {
+	ns, _, err := n.scope(ctx, true)
+	if err != nil {
+		return err
+	}
+	return n.store.Release(ctx, ns+Separator+name, owner)
+}
+
+// Publish sends payload on the channel of the namespace of the caller
+func (n *Store) Publish(ctx context.Context, channel string, payload string) (int64, error) {
+	ns, _, err := n.scope(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.Publish(ctx, ns+Separator+channel, payload)
+}
+
+func (n *Store) Subscribe(ctx context.Context, channels []string, patterns []string) (<-chan store.Message, error) {
+	ns, _, err := n.scope(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ns + Separator
+	scoped := make([]string, len(channels))
+	for i, c := range channels {
+		scoped[i] = prefix + c
+	}
+	globs := make([]string, len(patterns))
+	for i, p := range patterns {
+		globs[i] = store.Escape(prefix) + p
+	}
+	ch, err := n.store.Subscribe(ctx, scoped, globs)
+	if err != nil {
+		return nil, err
+	}
+	messages := make(chan store.Message, store.SubscriptionBuffer)
+	go func() {
+		defer close(messages)
+		for m := range ch {
+			m.Channel = strings.TrimPrefix(m.Channel, prefix)
+			m.Pattern = strings.TrimPrefix(m.Pattern, store.Escape(prefix))
+			select {
+			case messages <- m:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+func (n *Store) Watch(ctx context.Context, pattern string) (<-chan store.Event, error) {
+	ns, _, err := n.scope(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	prefix := ns + Separator
+	ch, err := n.store.Watch(ctx, store.Escape(prefix)+pattern)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan store.Event, store.SubscriptionBuffer)
+	go func() {
+		defer close(events)
+		for e := range ch {
+			e.Key = strings.TrimPrefix(e.Key, prefix)
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Unscoped returns the store of the keys of every namespace, prefixed by their namespace
+func (n *Store) Unscoped() store.Store {
+	return n.store
+}
+
+func (n *Store) Ping(ctx context.Context) error {
+	return n.store.Ping(ctx)
+}
+
+func (n *Store) Close() error {
+	n.once.Do(func() {
+		close(n.exit)
+	})
+	return n.store.Close()
+}
+
+func (n *Store) String() string {
+	return n.store.String()
+}
+
+// estimate returns the estimate of the size of the key of a write from its size before the write
+func estimate(op store.Op) func(old int64) int64 {
+	return func(old int64) int64 {
+		switch op.Kind {
+		case store.OpSet:
+			return int64(len(op.Key) + len(op.Value))
+		case store.OpIncrBy:
+			// an integer takes at most 20 bytes
+			if old == 0 {
+				return int64(len(op.Key) + 20)
+			}
+		case store.OpHSet:
+			// overwritten fields are counted twice until the next measure
+			size := old
+			if old == 0 {
+				size = int64(len(op.Key))
+			}
+			for f, v := range op.Fields {
+				size += int64(len(f) + len(v))
+			}
+			return size
+		}
+		return old
+	}
+}
@@ -0,0 +1,206 @@
+package namespace
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func from(service string, namespace string) context.Context {
+	md := metadata.Metadata{FromServiceKey: service}
+	if namespace != "" {
+		md[NamespaceKey] = namespace
+	}
+	return metadata.NewContext(context.Background(), md)
+}
+
+func code(err error) codes.Code {
+	s, _ := status.FromError(err)
+	return s.Code()
+}
+
+func TestStore(t *testing.T) {
+	inner := store.NewMemory(0, 0)
+	n := New(inner, Options{
+		TrustFromService: true,
+		Tenants:          []Tenant{{Service: "config", Read: []string{"identity"}}},
+	})
+	defer n.Close()
+
+	if err := n.Set(from("identity", ""), "cache:identity:rsa:token", "key", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Set(from("config", ""), "cache:identity:rsa:token", "other", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := inner.Get(context.Background(), "identity:cache:identity:rsa:token"); err != nil || v != "key" {
+		t.Fatalf("stored key = %q, %v, want key", v, err)
+	}
+	if v, _, err := n.Get(from("config", ""), "cache:identity:rsa:token"); err != nil || v != "other" {
+		t.Fatalf("Get() own key = %q, %v, want other", v, err)
+	}
+	if v, _, err := n.Get(from("config", "identity"), "cache:identity:rsa:token"); err != nil || v != "key" {
+		t.Fatalf("Get() allowed namespace = %q, %v, want key", v, err)
+	}
+	if _, _, err := n.Get(from("identity", "config"), "cache:identity:rsa:token"); code(err) != codes.PermissionDenied {
+		t.Fatalf("Get() other namespace error = %v, want permission denied", err)
+	}
+	if err := n.Set(from("config", "identity"), "x", "y", 0); code(err) != codes.PermissionDenied {
+		t.Fatalf("Set() other namespace error = %v, want permission denied", err)
+	}
+	if _, _, err := n.Get(context.Background(), "x"); code(err) != codes.Unauthenticated {
+		t.Fatalf("Get() anonymous error = %v, want unauthenticated", err)
+	}
+	if _, err := n.Delete(from("identity", ""), "lock:{job}:fence"); code(err) != codes.PermissionDenied {
+		t.Fatalf("Delete() lock key error = %v, want permission denied", err)
+	}
+	if err := n.Set(from("identity", ""), "lock:{job}", "x", 0); code(err) != codes.PermissionDenied {
+		t.Fatalf("Set() lock key error = %v, want permission denied", err)
+	}
+
+	keys, next, err := n.Scan(from("identity", ""), "", "*", 10)
+	if err != nil || next != "" || len(keys) != 1 || keys[0] != "cache:identity:rsa:token" {
+		t.Fatalf("Scan() = %v, %q, %v, want the identity key only", keys, next, err)
+	}
+}
+
+func TestStoreLegacy(t *testing.T) {
+	inner := store.NewMemory(0, 0)
+	n := New(inner, Options{TrustFromService: true, Tenants: []Tenant{{Service: "config", Read: []string{"identity"}}}})
+	defer n.Close()
+	ctx := context.Background()
+
+	// keys stored before the namespaces
+	if _, err := inner.HSet(ctx, "cache:identity:rsa:token", map[string]string{"public_key": "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.Set(ctx, "cache:identity:version", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := n.HGet(from("config", "identity"), "cache:identity:rsa:token", "public_key"); err != nil || v != "old" {
+		t.Fatalf("HGet() legacy = %q, %v, want old", v, err)
+	}
+	if fields, err := n.HGetAll(from("identity", ""), "cache:identity:rsa:token"); err != nil || fields["public_key"] != "old" {
+		t.Fatalf("HGetAll() legacy = %v, %v, want old", fields, err)
+	}
+	if v, _, err := n.Get(from("identity", ""), "cache:identity:version"); err != nil || v != "1" {
+		t.Fatalf("Get() legacy = %q, %v, want 1", v, err)
+	}
+	// other namespaces do not see them
+	if _, _, err := n.Get(from("config", ""), "cache:identity:version"); err != store.ErrNotFound {
+		t.Fatalf("Get() legacy of another service error = %v, want not found", err)
+	}
+
+	// the key written in the namespace hides the legacy one
+	if _, err := n.HSet(from("identity", ""), "cache:identity:rsa:token", map[string]string{"version": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.HGet(from("identity", ""), "cache:identity:rsa:token", "public_key"); err != store.ErrNotFound {
+		t.Fatalf("HGet() field of the legacy hash error = %v, want not found", err)
+	}
+}
+
+func TestStoreQuota(t *testing.T) {
+	n := New(store.NewMemory(0, 0), Options{TrustFromService: true, Keys: 2, Memory: 100})
+	defer n.Close()
+	ctx := from("identity", "")
+
+	for _, k := range []string{"a", "b"} {
+		if err := n.Set(ctx, k, "value", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := n.Set(ctx, "c", "value", 0); code(err) != codes.ResourceExhausted {
+		t.Fatalf("Set() over the keys quota error = %v, want resource exhausted", err)
+	}
+	// replacing a key does not add one
+	if err := n.Set(ctx, "a", "other", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Set(ctx, "c", "value", 0); err != nil {
+		t.Fatalf("Set() after a delete error = %v", err)
+	}
+	if err := n.Set(ctx, "a", string(make([]byte, 100)), 0); code(err) != codes.ResourceExhausted {
+		t.Fatalf("Set() over the memory quota error = %v, want resource exhausted", err)
+	}
+	// the quota is by namespace
+	if err := n.Set(from("config", ""), "c", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreExec(t *testing.T) {
+	n := New(store.NewMemory(0, 0), Options{TrustFromService: true, Keys: 2})
+	defer n.Close()
+	ctx := from("identity", "")
+
+	results, err := n.Exec(ctx, []store.Op{
+		{Kind: store.OpSet, Key: "a", Value: "1"},
+		{Kind: store.OpSet, Key: "b", Value: "2"},
+		{Kind: store.OpSet, Key: "c", Value: "3"},
+		{Kind: store.OpGet, Key: "a"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err != nil || code(results[2].Err) != codes.ResourceExhausted {
+		t.Fatalf("Exec() = %+v, want the third set over the quota", results)
+	}
+	if results[3].Value != "1" {
+		t.Fatalf("Exec() get = %+v, want 1", results[3])
+	}
+	if _, err := n.Exec(ctx, []store.Op{{Kind: store.OpSet, Key: "c", Value: "3"}}, true); code(err) != codes.ResourceExhausted {
+		t.Fatalf("Exec() atomic error = %v, want resource exhausted", err)
+	}
+	if _, err := n.Exec(from("config", "identity"), []store.Op{{Kind: store.OpDelete, Key: "a"}}, false); code(err) != codes.PermissionDenied {
+		t.Fatalf("Exec() write other namespace error = %v, want permission denied", err)
+	}
+}
+
+func TestStorePubSub(t *testing.T) {
+	n := New(store.NewMemory(0, 0), Options{
+		TrustFromService: true,
+		Tenants:          []Tenant{{Service: "gateway", Read: []string{"identity"}}},
+	})
+	defer n.Close()
+	ctx, cancel := context.WithCancel(from("gateway", "identity"))
+	defer cancel()
+
+	events, err := n.Watch(ctx, "cache:identity:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := n.Subscribe(ctx, nil, []string{"*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Set(from("gateway", ""), "cache:identity:rsa:token", "own", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Publish(from("gateway", ""), "keys", "own"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Set(from("identity", ""), "cache:identity:rsa:token", "key", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Publish(from("identity", ""), "keys", "rotated"); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the key and the channel of the identity namespace are seen, without their prefix
+	if e := <-events; e.Key != "cache:identity:rsa:token" || e.Op != store.EventSet {
+		t.Fatalf("event = %+v", e)
+	}
+	if m := <-messages; m.Channel != "keys" || m.Pattern != "*" || m.Payload != "rotated" {
+		t.Fatalf("message = %+v", m)
+	}
+	if _, err := n.Publish(from("gateway", "identity"), "keys", "forged"); code(err) != codes.PermissionDenied {
+		t.Fatalf("Publish() other namespace error = %v, want permission denied", err)
+	}
+}
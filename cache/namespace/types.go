@@ -0,0 +1,230 @@
+package namespace
+
+import (
+	"context"
+	"github.com/sparrow-community/app/cache/store"
+	"strings"
+	"time"
+)
+
+// grow estimates the size of key after a write adding bytes to it
+func grow(key string, bytes int) func(old int64) int64 {
+	return func(old int64) int64 {
+		if old == 0 {
+			return int64(len(key) + bytes)
+		}
+		return old + int64(bytes)
+	}
+}
+
+// add runs a write adding bytes to key in the namespace of the caller
+func (n *Store) add(ctx context.Context, key string, bytes int, fn func(key string) error) error {
+	ns, t, err := n.scope(ctx, true, key)
+	if err != nil {
+		return err
+	}
+	key = ns + Separator + key
+	done, err := n.write(ctx, ns, t, key, grow(key, bytes))
+	if err != nil {
+		return err
+	}
+	err = fn(key)
+	done(err)
+	return err
+}
+
+// key returns the stored key of a request, writes only go to the namespace of the caller
+func (n *Store) key(ctx context.Context, key string, write bool) (string, error) {
+	ns, _, err := n.scope(ctx, write, key)
+	if err != nil {
+		return "", err
+	}
+	return ns + Separator + key, nil
+}
+
+func length(values []string) int {
+	var l int
+	for _, v := range values {
+		l += len(v)
+	}
+	return l
+}
+
+func (n *Store) LPush(ctx context.Context, key string, values ...string) (l int64, err error) {
+	err = n.add(ctx, key, length(values), func(key string) error {
+		l, err = n.store.LPush(ctx, key, values...)
+		return err
+	})
+	return l, err
+}
+
+func (n *Store) RPush(ctx context.Context, key string, values ...string) (l int64, err error) {
+	err = n.add(ctx, key, length(values), func(key string) error {
+		l, err = n.store.RPush(ctx, key, values...)
+		return err
+	})
+	return l, err
+}
+
+func (n *Store) LPop(ctx context.Context, key string) (string, error) {
+	key, err := n.key(ctx, key, true)
+	if err != nil {
+		return "", err
+	}
+	return n.store.LPop(ctx, key)
+}
+
+func (n *Store) RPop(ctx context.Context, key string) (string, error) {
+	key, err := n.key(ctx, key, true)
+	if err != nil {
+		return "", err
+	}
+	return n.store.RPop(ctx, key)
+}
+
+func (n *Store) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return n.bpop(ctx, keys, func(keys []string) (string, string, error) {
+		return n.store.BLPop(ctx, timeout, keys...)
+	})
+}
+
+func (n *Store) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return n.bpop(ctx, keys, func(keys []string) (string, string, error) {
+		return n.store.BRPop(ctx, timeout, keys...)
+	})
+}
+
+func (n *Store) bpop(ctx context.Context, keys []string, pop func(keys []string) (string, string, error)) (string, string, error) {
+	ns, _, err := n.scope(ctx, true, keys...)
+	if err != nil {
+		return "", "", err
+	}
+	prefix := ns + Separator
+	stored := make([]string, len(keys))
+	for i, k := range keys {
+		stored[i] = prefix + k
+	}
+	key, v, err := pop(stored)
+	return strings.TrimPrefix(key, prefix), v, err
+}
+
+func (n *Store) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.store.LRange(ctx, key, start, stop)
+}
+
+func (n *Store) SAdd(ctx context.Context, key string, members ...string) (added int64, err error) {
+	err = n.add(ctx, key, length(members), func(key string) error {
+		added, err = n.store.SAdd(ctx, key, members...)
+		return err
+	})
+	return added, err
+}
+
+func (n *Store) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	key, err := n.key(ctx, key, true)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.SRem(ctx, key, members...)
+}
+
+func (n *Store) SMembers(ctx context.Context, key string) ([]string, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.store.SMembers(ctx, key)
+}
+
+func (n *Store) SIsMember(ctx context.Context, key string, member string) (bool, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return false, err
+	}
+	return n.store.SIsMember(ctx, key, member)
+}
+
+func (n *Store) ZAdd(ctx context.Context, key string, members ...store.ZMember) (added int64, err error) {
+	bytes := 0
+	for _, m := range members {
+		bytes += len(m.Member) + 8
+	}
+	err = n.add(ctx, key, bytes, func(key string) error {
+		added, err = n.store.ZAdd(ctx, key, members...)
+		return err
+	})
+	return added, err
+}
+
+func (n *Store) ZRangeByScore(ctx context.Context, key string, min float64, max float64, offset int64, count int64) ([]store.ZMember, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.store.ZRangeByScore(ctx, key, min, max, offset, count)
+}
+
+func (n *Store) ZRank(ctx context.Context, key string, member string) (int64, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.ZRank(ctx, key, member)
+}
+
+func (n *Store) ZRevRank(ctx context.Context, key string, member string) (int64, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.ZRevRank(ctx, key, member)
+}
+
+func (n *Store) XAdd(ctx context.Context, key string, id string, fields map[string]string, maxLen int64) (added string, err error) {
+	bytes := 0
+	for f, v := range fields {
+		bytes += len(f) + len(v)
+	}
+	err = n.add(ctx, key, bytes, func(key string) error {
+		added, err = n.store.XAdd(ctx, key, id, fields, maxLen)
+		return err
+	})
+	return added, err
+}
+
+func (n *Store) XRead(ctx context.Context, key string, after string, count int64, block time.Duration) ([]store.StreamEntry, error) {
+	key, err := n.key(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.store.XRead(ctx, key, after, count, block)
+}
+
+func (n *Store) XGroupCreate(ctx context.Context, key string, group string, start string) error {
+	key, err := n.key(ctx, key, true)
+	if err != nil {
+		return err
+	}
+	return n.store.XGroupCreate(ctx, key, group, start)
+}
+
+// XReadGroup changes the group, so it only reads the streams of the caller
+func (n *Store) XReadGroup(ctx context.Context, key string, group string, consumer string, count int64, block time.Duration) ([]store.StreamEntry, error) {
+	key, err := n.key(ctx, key, true)
+	if err != nil {
+		return nil, err
+	}
+	return n.store.XReadGroup(ctx, key, group, consumer, count, block)
+}
+
+func (n *Store) XAck(ctx context.Context, key string, group string, ids ...string) (int64, error) {
+	key, err := n.key(ctx, key, true)
+	if err != nil {
+		return 0, err
+	}
+	return n.store.XAck(ctx, key, group, ids...)
+}
@@ -0,0 +1,891 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var (
+	keysBucket = []byte("keys")
+	// locksBucket leases by lock name, fencesBucket last fencing token by lock name
+	locksBucket  = []byte("locks")
+	fencesBucket = []byte("fences")
+)
+
+// lockRecord is the stored form of a lease
+type lockRecord struct {
+	Owner   string `json:"owner"`
+	Token   int64  `json:"token"`
+	Expires int64  `json:"expires"`
+}
+
+// record is the stored form of a key
+type record struct {
+	Value string            `json:"value,omitempty"`
+	Hash  map[string]string `json:"hash,omitempty"`
+	data
+	// Expires unix nano time of the expiration, 0 never expires
+	Expires int64 `json:"expires,omitempty"`
+}
+
+func (r *record) expired(now time.Time) bool {
+	return r.Expires != 0 && now.UnixNano() >= r.Expires
+}
+
+// ttl returns the time to live of a live record, NoExpiry when it does not expire
+func (r *record) ttl() time.Duration {
+	if r.Expires == 0 {
+		return NoExpiry
+	}
+	return remaining(time.Unix(0, r.Expires))
+}
+
+// Bolt keeps the keys in an embedded bbolt database, they survive the restarts of the service,
+// expired keys are skipped when they are read and removed by a periodic sweep
+type Bolt struct {
+	db   *bolt.DB
+	hub  *hub
+	exit chan bool
+}
+
+// NewBolt opens the bolt store of file, expired keys are swept every sweep
+func NewBolt(file string, sweep time.Duration) (*Bolt, error) {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{keysBucket, locksBucket, fencesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	b := &Bolt{db: db, hub: newHub(), exit: make(chan bool)}
+	if sweep > 0 {
+		go b.run(sweep)
+	}
+	return b, nil
+}
+
+func (b *Bolt) run(sweep time.Duration) {
+	ticker := time.NewTicker(sweep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.exit:
+			return
+		case <-ticker.C:
+			_ = b.sweep()
+		}
+	}
+}
+
+// sweep removes the expired keys
+func (b *Bolt) sweep() error {
+	var expired [][]byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		now := time.Now()
+		err := bucket.ForEach(func(k, v []byte) error {
+			var r record
+			if err := json.Unmarshal(v, &r); err == nil && r.expired(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		b.hub.notify(string(k), EventExpired)
+	}
+	return nil
+}
+
+// get returns the live record of key, nil when it does not exist
+func get(bucket *bolt.Bucket, key string) (*record, error) {
+	v := bucket.Get([]byte(key))
+	if v == nil {
+		return nil, nil
+	}
+	var r record
+	if err := json.Unmarshal(v, &r); err != nil {
+		return nil, err
+	}
+	if r.expired(time.Now()) {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func put(bucket *bolt.Bucket, key string, r *record) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), v)
+}
+
+func (b *Bolt) Get(_ context.Context, key string) (v string, ttl time.Duration, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		v, ttl, err = value(tx.Bucket(keysBucket), key)
+		return err
+	})
+	return v, ttl, err
+}
+
+func (b *Bolt) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	_, err := b.result(b.Exec(ctx, []Op{{Kind: OpSet, Key: key, Value: value, TTL: ttl}}, true))
+	return err
+}
+
+func (b *Bolt) Delete(ctx context.Context, keys ...string) (int64, error) {
+	ops := make([]Op, len(keys))
+	for i, key := range keys {
+		ops[i] = Op{Kind: OpDelete, Key: key}
+	}
+	results, err := b.Exec(ctx, ops, true)
+	var n int64
+	for _, r := range results {
+		if err == nil {
+			err = r.Err
+		}
+		n += r.Int
+	}
+	return n, err
+}
+
+func (b *Bolt) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return b.result(b.Exec(ctx, []Op{{Kind: OpIncrBy, Key: key, By: n}}, true))
+}
+
+// SetArgs runs in one transaction, the event is notified after the commit
+func (b *Bolt) SetArgs(_ context.Context, key string, value string, args SetArgs) (res SetResult, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		old, err := get(bucket, key)
+		if err != nil {
+			return err
+		}
+		if args.Get && old != nil {
+			if kindOf(old.Hash, &old.data) != kindString {
+				return ErrWrongType
+			}
+			res.Old, res.Existed = old.Value, true
+		}
+		if args.skip(old != nil) {
+			return nil
+		}
+		r := &record{Value: value}
+		switch {
+		case args.KeepTTL && old != nil:
+			r.Expires = old.Expires
+		case !args.KeepTTL && args.TTL > 0:
+			r.Expires = time.Now().Add(args.TTL).UnixNano()
+		}
+		res.Set = true
+		return put(bucket, key, r)
+	})
+	if err != nil {
+		return SetResult{}, err
+	}
+	if res.Set {
+		b.hub.notify(key, EventSet)
+	}
+	return res, nil
+}
+
+func (b *Bolt) Expire(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	event := EventExpire
+	if ttl <= 0 {
+		event = EventDel
+	}
+	return b.expiry(key, event, func(bucket *bolt.Bucket, r *record) (bool, error) {
+		if ttl <= 0 {
+			return true, bucket.Delete([]byte(key))
+		}
+		r.Expires = time.Now().Add(ttl).UnixNano()
+		return true, put(bucket, key, r)
+	})
+}
+
+func (b *Bolt) Persist(_ context.Context, key string) (bool, error) {
+	return b.expiry(key, EventPersist, func(bucket *bolt.Bucket, r *record) (bool, error) {
+		if r.Expires == 0 {
+			return false, nil
+		}
+		r.Expires = 0
+		return true, put(bucket, key, r)
+	})
+}
+
+// expiry runs fn on the live record of key in a transaction, event is notified after the commit when fn
+// reports a change
+func (b *Bolt) expiry(key string, event string, fn func(bucket *bolt.Bucket, r *record) (bool, error)) (changed bool, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		r, err := get(bucket, key)
+		if err != nil || r == nil {
+			return err
+		}
+		changed, err = fn(bucket, r)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		b.hub.notify(key, event)
+	}
+	return changed, nil
+}
+
+func (b *Bolt) TTL(_ context.Context, key string) (ttl time.Duration, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		r, err := get(tx.Bucket(keysBucket), key)
+		if err != nil || r == nil {
+			ttl = Missing
+			return err
+		}
+		ttl = r.ttl()
+		return nil
+	})
+	return ttl, err
+}
+
+func (b *Bolt) Scan(_ context.Context, cursor string, pattern string, count int64) ([]string, string, error) {
+	pattern, count = scanDefaults(pattern, count)
+	after, err := afterCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	var keys []string
+	next := ""
+	err = b.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		c := tx.Bucket(keysBucket).Cursor()
+		k, v := c.First()
+		if cursor != "" {
+			k, v = c.Seek([]byte(after))
+			if k != nil && string(k) == after {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			if int64(len(keys)) >= count {
+				next = strconv.Quote(keys[len(keys)-1])
+				return nil
+			}
+			var r record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if !r.expired(now) && Match(pattern, string(k)) {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, next, err
+}
+
+func (b *Bolt) HGet(_ context.Context, key string, field string) (v string, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		v, err = hget(tx.Bucket(keysBucket), key, field)
+		return err
+	})
+	return v, err
+}
+
+func (b *Bolt) HSet(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	return b.result(b.Exec(ctx, []Op{{Kind: OpHSet, Key: key, Fields: fields}}, true))
+}
+
+// result returns the integer result of the single op of an Exec
+func (b *Bolt) result(results []Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return results[0].Int, results[0].Err
+}
+
+func (b *Bolt) HGetAll(_ context.Context, key string) (fields map[string]string, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		fields, err = hgetAll(tx.Bucket(keysBucket), key)
+		return err
+	})
+	return fields, err
+}
+
+// Exec runs ops in one transaction, so they are always atomic
+func (b *Bolt) Exec(_ context.Context, ops []Op, _ bool) ([]Result, error) {
+	results := make([]Result, len(ops))
+	run := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		for i, op := range ops {
+			r := &results[i]
+			switch op.Kind {
+			case OpGet:
+				r.Value, r.TTL, r.Err = value(bucket, op.Key)
+			case OpSet:
+				r.Err = set(bucket, op.Key, op.Value, op.TTL)
+			case OpDelete:
+				r.Int, r.Err = del(bucket, op.Key)
+			case OpIncrBy:
+				r.Int, r.Err = incrBy(bucket, op.Key, op.By)
+			case OpHGet:
+				r.Value, r.Err = hget(bucket, op.Key, op.Field)
+			case OpHSet:
+				r.Int, r.Err = hset(bucket, op.Key, op.Fields)
+			case OpHGetAll:
+				r.Fields, r.Err = hgetAll(bucket, op.Key)
+			default:
+				r.Err = ErrUnknownOp
+			}
+		}
+		return nil
+	}
+	for _, op := range ops {
+		if op.Writes() {
+			if err := b.db.Update(run); err != nil {
+				return results, err
+			}
+			b.notify(ops, results)
+			return results, nil
+		}
+	}
+	return results, b.db.View(run)
+}
+
+// notify sends the events of the committed ops
+func (b *Bolt) notify(ops []Op, results []Result) {
+	for i, op := range ops {
+		if results[i].Err != nil {
+			continue
+		}
+		switch {
+		case op.Kind == OpSet:
+			b.hub.notify(op.Key, EventSet)
+		case op.Kind == OpDelete && results[i].Int > 0:
+			b.hub.notify(op.Key, EventDel)
+		case op.Kind == OpIncrBy:
+			b.hub.notify(op.Key, EventIncrBy)
+		case op.Kind == OpHSet && len(op.Fields) > 0:
+			b.hub.notify(op.Key, EventHSet)
+		}
+	}
+}
+
+func value(bucket *bolt.Bucket, key string) (string, time.Duration, error) {
+	r, err := get(bucket, key)
+	switch {
+	case err != nil:
+		return "", 0, err
+	case r == nil:
+		return "", 0, ErrNotFound
+	case kindOf(r.Hash, &r.data) != kindString:
+		return "", 0, ErrWrongType
+	}
+	return r.Value, r.ttl(), nil
+}
+
+func set(bucket *bolt.Bucket, key string, value string, ttl time.Duration) error {
+	r := &record{Value: value}
+	if ttl > 0 {
+		r.Expires = time.Now().Add(ttl).UnixNano()
+	}
+	return put(bucket, key, r)
+}
+
+func del(bucket *bolt.Bucket, keys ...string) (int64, error) {
+	var n int64
+	for _, key := range keys {
+		r, err := get(bucket, key)
+		if err != nil {
+			return n, err
+		}
+		if r != nil {
+			n++
+		}
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func incrBy(bucket *bolt.Bucket, key string, n int64) (int64, error) {
+	r, err := get(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	if r == nil {
+		r = &record{Value: "0"}
+	}
+	if kindOf(r.Hash, &r.data) != kindString {
+		return 0, ErrWrongType
+	}
+	v, err := strconv.ParseInt(r.Value, 10, 64)
+	if err != nil || (n > 0 && v > math.MaxInt64-n) || (n < 0 && v < math.MinInt64-n) {
+		return 0, ErrNotInteger
+	}
+	v += n
+	r.Value = strconv.FormatInt(v, 10)
+	return v, put(bucket, key, r)
+}
+
+func hget(bucket *bolt.Bucket, key string, field string) (string, error) {
+	r, err := get(bucket, key)
+	switch {
+	case err != nil:
+		return "", err
+	case r == nil:
+		return "", ErrNotFound
+	case r.Hash == nil:
+		return "", ErrWrongType
+	}
+	v, ok := r.Hash[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func hset(bucket *bolt.Bucket, key string, fields map[string]string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	r, err := get(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	if r == nil {
+		r = &record{Hash: map[string]string{}}
+	}
+	if r.Hash == nil {
+		return 0, ErrWrongType
+	}
+	var added int64
+	for f, v := range fields {
+		if _, ok := r.Hash[f]; !ok {
+			added++
+		}
+		r.Hash[f] = v
+	}
+	return added, put(bucket, key, r)
+}
+
+func hgetAll(bucket *bolt.Bucket, key string) (map[string]string, error) {
+	r, err := get(bucket, key)
+	switch {
+	case err != nil:
+		return nil, err
+	case r == nil:
+		return map[string]string{}, nil
+	case r.Hash == nil:
+		return nil, ErrWrongType
+	}
+	return r.Hash, nil
+}
+
+func (b *Bolt) Size(_ context.Context, key string) (int64, error) {
+	var r *record
+	err := b.db.View(func(tx *bolt.Tx) (err error) {
+		r, err = get(tx.Bucket(keysBucket), key)
+		return err
+	})
+	if err != nil || r == nil {
+		return 0, err
+	}
+	return size(key, r.Value, r.Hash) + r.data.size(), nil
+}
+
+// typed returns the live record of key when it holds kind k, a new record when key does not exist
+func typed(bucket *bolt.Bucket, key string, k kind) (*record, error) {
+	r, err := get(bucket, key)
+	switch {
+	case err != nil:
+		return nil, err
+	case r == nil:
+		return &record{data: newData(k)}, nil
+	case kindOf(r.Hash, &r.data) != k:
+		return nil, ErrWrongType
+	}
+	return r, nil
+}
+
+// change runs fn on the record of key holding kind k in a transaction, the record is stored when fn changes
+// it and removed once it is empty, event is notified after the commit
+func (b *Bolt) change(key string, k kind, event string, fn func(r *record) (bool, error)) error {
+	changed, removed := false, false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		r, err := typed(bucket, key, k)
+		if err != nil {
+			return err
+		}
+		if changed, err = fn(r); err != nil || !changed {
+			return err
+		}
+		if r.empty() {
+			removed = true
+			return bucket.Delete([]byte(key))
+		}
+		return put(bucket, key, r)
+	})
+	if err != nil || !changed {
+		return err
+	}
+	b.hub.notify(key, event)
+	if removed {
+		b.hub.notify(key, EventDel)
+	}
+	return nil
+}
+
+// view runs fn on the record of key holding kind k in a read transaction
+func (b *Bolt) view(key string, k kind, fn func(r *record) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		r, err := typed(tx.Bucket(keysBucket), key, k)
+		if err != nil {
+			return err
+		}
+		return fn(r)
+	})
+}
+
+func (b *Bolt) LPush(_ context.Context, key string, values ...string) (int64, error) {
+	return b.push(key, true, values)
+}
+
+func (b *Bolt) RPush(_ context.Context, key string, values ...string) (int64, error) {
+	return b.push(key, false, values)
+}
+
+func (b *Bolt) push(key string, left bool, values []string) (n int64, err error) {
+	err = b.change(key, kindList, side(left, EventLPush, EventRPush), func(r *record) (bool, error) {
+		n = r.push(left, values)
+		return len(values) > 0, nil
+	})
+	return n, err
+}
+
+func (b *Bolt) LPop(_ context.Context, key string) (string, error) {
+	return b.pop(key, true)
+}
+
+func (b *Bolt) RPop(_ context.Context, key string) (string, error) {
+	return b.pop(key, false)
+}
+
+func (b *Bolt) pop(key string, left bool) (v string, err error) {
+	err = b.change(key, kindList, side(left, EventLPop, EventRPop), func(r *record) (bool, error) {
+		if len(r.List) == 0 {
+			return false, ErrNotFound
+		}
+		v = r.data.pop(left)
+		return true, nil
+	})
+	return v, err
+}
+
+func (b *Bolt) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return b.bpop(ctx, true, timeout, keys)
+}
+
+func (b *Bolt) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return b.bpop(ctx, false, timeout, keys)
+}
+
+func (b *Bolt) bpop(ctx context.Context, left bool, timeout time.Duration, keys []string) (key string, v string, err error) {
+	err = wait(ctx, b.hub, keys, timeout, func() (bool, error) {
+		for _, k := range keys {
+			popped, err := b.pop(k, left)
+			if err == ErrNotFound {
+				continue
+			}
+			key, v = k, popped
+			return true, err
+		}
+		return false, nil
+	})
+	if err == nil && key == "" {
+		err = ErrNotFound
+	}
+	return key, v, err
+}
+
+func (b *Bolt) LRange(_ context.Context, key string, start int64, stop int64) (values []string, err error) {
+	err = b.view(key, kindList, func(r *record) error {
+		values = lrange(r.List, start, stop)
+		return nil
+	})
+	return values, err
+}
+
+func (b *Bolt) SAdd(_ context.Context, key string, members ...string) (added int64, err error) {
+	err = b.change(key, kindSet, EventSAdd, func(r *record) (bool, error) {
+		added = r.sadd(members)
+		return added > 0, nil
+	})
+	return added, err
+}
+
+func (b *Bolt) SRem(_ context.Context, key string, members ...string) (removed int64, err error) {
+	err = b.change(key, kindSet, EventSRem, func(r *record) (bool, error) {
+		removed = r.srem(members)
+		return removed > 0, nil
+	})
+	return removed, err
+}
+
+func (b *Bolt) SMembers(_ context.Context, key string) (members []string, err error) {
+	err = b.view(key, kindSet, func(r *record) error {
+		members = r.smembers()
+		return nil
+	})
+	return members, err
+}
+
+func (b *Bolt) SIsMember(_ context.Context, key string, member string) (ok bool, err error) {
+	err = b.view(key, kindSet, func(r *record) error {
+		ok = r.Set[member]
+		return nil
+	})
+	return ok, err
+}
+
+func (b *Bolt) ZAdd(_ context.Context, key string, members ...ZMember) (added int64, err error) {
+	err = b.change(key, kindZSet, EventZAdd, func(r *record) (bool, error) {
+		added = r.zadd(members)
+		return len(members) > 0, nil
+	})
+	return added, err
+}
+
+func (b *Bolt) ZRangeByScore(_ context.Context, key string, min float64, max float64, offset int64, count int64) (members []ZMember, err error) {
+	err = b.view(key, kindZSet, func(r *record) error {
+		members = r.zrangeByScore(min, max, offset, count)
+		return nil
+	})
+	return members, err
+}
+
+func (b *Bolt) ZRank(_ context.Context, key string, member string) (rank int64, err error) {
+	err = b.view(key, kindZSet, func(r *record) error {
+		rank, err = r.zrank(member, false)
+		return err
+	})
+	return rank, err
+}
+
+func (b *Bolt) ZRevRank(_ context.Context, key string, member string) (rank int64, err error) {
+	err = b.view(key, kindZSet, func(r *record) error {
+		rank, err = r.zrank(member, true)
+		return err
+	})
+	return rank, err
+}
+
+func (b *Bolt) XAdd(_ context.Context, key string, id string, fields map[string]string, maxLen int64) (added string, err error) {
+	err = b.change(key, kindStream, EventXAdd, func(r *record) (bool, error) {
+		added, err = r.Stream.add(id, fields, maxLen, time.Now())
+		return err == nil, err
+	})
+	return added, err
+}
+
+func (b *Bolt) XRead(ctx context.Context, key string, after string, count int64, block time.Duration) (entries []StreamEntry, err error) {
+	if after == "$" {
+		err = b.view(key, kindStream, func(r *record) error {
+			after = r.Stream.Last
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = wait(ctx, b.hub, []string{key}, block, func() (bool, error) {
+		err := b.view(key, kindStream, func(r *record) (err error) {
+			entries, err = r.Stream.after(after, count)
+			return err
+		})
+		return len(entries) > 0, err
+	})
+	return entries, err
+}
+
+func (b *Bolt) XGroupCreate(_ context.Context, key string, group string, start string) error {
+	return b.change(key, kindStream, EventXGroup, func(r *record) (bool, error) {
+		return true, r.Stream.createGroup(group, start)
+	})
+}
+
+// XReadGroup runs a write transaction, the delivered entries become pending
+func (b *Bolt) XReadGroup(ctx context.Context, key string, group string, consumer string, count int64, block time.Duration) (entries []StreamEntry, err error) {
+	err = wait(ctx, b.hub, []string{key}, block, func() (bool, error) {
+		err := b.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(keysBucket)
+			r, err := typed(bucket, key, kindStream)
+			if err != nil {
+				return err
+			}
+			if entries, err = r.Stream.readGroup(group, consumer, count); err != nil || len(entries) == 0 {
+				return err
+			}
+			return put(bucket, key, r)
+		})
+		return len(entries) > 0, err
+	})
+	return entries, err
+}
+
+func (b *Bolt) XAck(_ context.Context, key string, group string, ids ...string) (acked int64, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		r, err := typed(bucket, key, kindStream)
+		if err != nil {
+			return err
+		}
+		if acked = r.Stream.ack(group, ids); acked == 0 {
+			return nil
+		}
+		return put(bucket, key, r)
+	})
+	return acked, err
+}
+
+func (b *Bolt) Acquire(_ context.Context, name string, owner string, ttl time.Duration) (int64, error) {
+	var token int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		l, err := lockLease(tx, name)
+		if err != nil {
+			return err
+		}
+		if l != nil && l.Owner != owner {
+			return ErrLocked
+		}
+		if l == nil {
+			fences := tx.Bucket(fencesBucket)
+			var last int64
+			if v := fences.Get([]byte(name)); v != nil {
+				last, _ = strconv.ParseInt(string(v), 10, 64)
+			}
+			l = &lockRecord{Owner: owner, Token: last + 1}
+			if err := fences.Put([]byte(name), []byte(strconv.FormatInt(l.Token, 10))); err != nil {
+				return err
+			}
+		}
+		token = l.Token
+		l.Expires = time.Now().Add(ttl).UnixNano()
+		return putLease(tx, name, l)
+	})
+	return token, err
+}
+
+func (b *Bolt) Renew(_ context.Context, name string, owner string, ttl time.Duration) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		l, err := lockLease(tx, name)
+		if err != nil {
+			return err
+		}
+		if l == nil || l.Owner != owner {
+			return ErrNotOwner
+		}
+		l.Expires = time.Now().Add(ttl).UnixNano()
+		return putLease(tx, name, l)
+	})
+}
+
+func (b *Bolt) Release(_ context.Context, name string, owner string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		l, err := lockLease(tx, name)
+		if err != nil {
+			return err
+		}
+		if l == nil || l.Owner != owner {
+			return ErrNotOwner
+		}
+		return tx.Bucket(locksBucket).Delete([]byte(name))
+	})
+}
+
+// lockLease returns the unexpired lease of the lock name
+func lockLease(tx *bolt.Tx, name string) (*lockRecord, error) {
+	v := tx.Bucket(locksBucket).Get([]byte(name))
+	if v == nil {
+		return nil, nil
+	}
+	var l lockRecord
+	if err := json.Unmarshal(v, &l); err != nil {
+		return nil, err
+	}
+	if time.Now().UnixNano() >= l.Expires {
+		return nil, nil
+	}
+	return &l, nil
+}
+
+func putLease(tx *bolt.Tx, name string, l *lockRecord) error {
+	v, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(locksBucket).Put([]byte(name), v)
+}
+
+func (b *Bolt) Publish(_ context.Context, channel string, payload string) (int64, error) {
+	return b.hub.publish(channel, payload), nil
+}
+
+func (b *Bolt) Subscribe(ctx context.Context, channels []string, patterns []string) (<-chan Message, error) {
+	return b.hub.subscribe(ctx, channels, patterns), nil
+}
+
+func (b *Bolt) Watch(ctx context.Context, pattern string) (<-chan Event, error) {
+	return b.hub.watch(ctx, pattern), nil
+}
+
+func (b *Bolt) Ping(context.Context) error {
+	return nil
+}
+
+func (b *Bolt) Close() error {
+	select {
+	case <-b.exit:
+		return nil
+	default:
+		close(b.exit)
+	}
+	b.hub.close()
+	return b.db.Close()
+}
+
+func (b *Bolt) String() string {
+	return "bolt"
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBolt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.db")
+	s, err := NewBolt(file, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	testExec(t, s)
+	testLocker(t, s)
+	testExpiry(t, s)
+	testPubSub(t, s)
+	testTypes(t, s)
+	if err := s.Set(context.Background(), "kept", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewBolt(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, _, err := s.Get(context.Background(), "kept"); err != nil || v != "value" {
+		t.Fatalf("Get() after reopen = %q, %v, want value", v, err)
+	}
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// data of the lists, sets, sorted sets and streams of the local stores, at most one of them is set
+type data struct {
+	List   []string           `json:"list,omitempty"`
+	Set    map[string]bool    `json:"set,omitempty"`
+	ZSet   map[string]float64 `json:"zset,omitempty"`
+	Stream *stream            `json:"stream,omitempty"`
+}
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindHash
+	kindList
+	kindSet
+	kindZSet
+	kindStream
+)
+
+// kindOf returns the kind of a key of the local stores
+func kindOf(hash map[string]string, d *data) kind {
+	switch {
+	case hash != nil:
+		return kindHash
+	case d.List != nil:
+		return kindList
+	case d.Set != nil:
+		return kindSet
+	case d.ZSet != nil:
+		return kindZSet
+	case d.Stream != nil:
+		return kindStream
+	}
+	return kindString
+}
+
+// newData returns the empty data of a new key of kind k
+func newData(k kind) data {
+	switch k {
+	case kindList:
+		return data{List: []string{}}
+	case kindSet:
+		return data{Set: map[string]bool{}}
+	case kindZSet:
+		return data{ZSet: map[string]float64{}}
+	case kindStream:
+		return data{Stream: &stream{Last: "0-0"}}
+	}
+	return data{}
+}
+
+// empty reports whether a list, a set or a sorted set lost its last element, empty streams are kept
+func (d *data) empty() bool {
+	return (d.List != nil && len(d.List) == 0) || (d.Set != nil && len(d.Set) == 0) ||
+		(d.ZSet != nil && len(d.ZSet) == 0)
+}
+
+func (d *data) size() int64 {
+	var n int
+	for _, v := range d.List {
+		n += len(v)
+	}
+	for m := range d.Set {
+		n += len(m)
+	}
+	for m := range d.ZSet {
+		n += len(m) + 8
+	}
+	if d.Stream != nil {
+		for _, e := range d.Stream.Entries {
+			n += len(e.ID)
+			for f, v := range e.Fields {
+				n += len(f) + len(v)
+			}
+		}
+	}
+	return int64(n)
+}
+
+// side returns the event of the head of a list when left is set, else the event of its tail
+func side(left bool, head string, tail string) string {
+	if left {
+		return head
+	}
+	return tail
+}
+
+// push adds values at the head, one after the other, or at the tail of the list
+func (d *data) push(left bool, values []string) int64 {
+	if !left {
+		d.List = append(d.List, values...)
+		return int64(len(d.List))
+	}
+	list := make([]string, 0, len(values)+len(d.List))
+	for i := len(values) - 1; i >= 0; i-- {
+		list = append(list, values[i])
+	}
+	d.List = append(list, d.List...)
+	return int64(len(d.List))
+}
+
+// pop removes the head or the tail of a list that is not empty
+func (d *data) pop(left bool) string {
+	if left {
+		v := d.List[0]
+		d.List = d.List[1:]
+		return v
+	}
+	v := d.List[len(d.List)-1]
+	d.List = d.List[:len(d.List)-1]
+	return v
+}
+
+// lrange returns the elements from start to stop included, negative indexes count from the tail
+func lrange(list []string, start int64, stop int64) []string {
+	n := int64(len(list))
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []string{}
+	}
+	return append([]string{}, list[start:stop+1]...)
+}
+
+func (d *data) sadd(members []string) int64 {
+	var added int64
+	for _, m := range members {
+		if !d.Set[m] {
+			d.Set[m] = true
+			added++
+		}
+	}
+	return added
+}
+
+func (d *data) srem(members []string) int64 {
+	var removed int64
+	for _, m := range members {
+		if d.Set[m] {
+			delete(d.Set, m)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (d *data) smembers() []string {
+	members := make([]string, 0, len(d.Set))
+	for m := range d.Set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (d *data) zadd(members []ZMember) int64 {
+	var added int64
+	for _, m := range members {
+		if _, ok := d.ZSet[m.Member]; !ok {
+			added++
+		}
+		d.ZSet[m.Member] = m.Score
+	}
+	return added
+}
+
+// zsorted returns the members ordered by score then by member
+func (d *data) zsorted() []ZMember {
+	members := make([]ZMember, 0, len(d.ZSet))
+	for m, s := range d.ZSet {
+		members = append(members, ZMember{Member: m, Score: s})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+		return members[i].Member < members[j].Member
+	})
+	return members
+}
+
+func (d *data) zrangeByScore(min float64, max float64, offset int64, count int64) []ZMember {
+	members := []ZMember{}
+	for _, m := range d.zsorted() {
+		if m.Score < min || m.Score > max {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count > 0 && int64(len(members)) >= count {
+			break
+		}
+		members = append(members, m)
+	}
+	return members
+}
+
+func (d *data) zrank(member string, reverse bool) (int64, error) {
+	if _, ok := d.ZSet[member]; !ok {
+		return 0, ErrNotFound
+	}
+	members := d.zsorted()
+	for i, m := range members {
+		if m.Member != member {
+			continue
+		}
+		if reverse {
+			return int64(len(members) - 1 - i), nil
+		}
+		return int64(i), nil
+	}
+	return 0, ErrNotFound
+}
+
+// stream of the local stores, Last is the id of the last added entry
+type stream struct {
+	Entries []StreamEntry           `json:"entries,omitempty"`
+	Last    string                  `json:"last"`
+	Groups  map[string]*streamGroup `json:"groups,omitempty"`
+}
+
+// streamGroup Last is the id of the last entry delivered to the group, Pending the consumer by pending entry
+type streamGroup struct {
+	Last    string            `json:"last"`
+	Pending map[string]string `json:"pending,omitempty"`
+}
+
+type streamID struct {
+	ms  uint64
+	seq uint64
+}
+
+// parseStreamID parses "ms-seq" or "ms"
+func parseStreamID(s string) (streamID, error) {
+	ms, seq, ok := strings.Cut(s, "-")
+	var id streamID
+	var err error
+	if id.ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, ErrStreamID
+	}
+	if ok {
+		if id.seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+			return id, ErrStreamID
+		}
+	}
+	return id, nil
+}
+
+func (i streamID) String() string {
+	return fmt.Sprintf("%d-%d", i.ms, i.seq)
+}
+
+func (i streamID) less(o streamID) bool {
+	return i.ms < o.ms || (i.ms == o.ms && i.seq < o.seq)
+}
+
+func (s *stream) add(id string, fields map[string]string, maxLen int64, now time.Time) (string, error) {
+	last, err := parseStreamID(s.Last)
+	if err != nil {
+		return "", err
+	}
+	var next streamID
+	if id == "" || id == "*" {
+		next = streamID{ms: uint64(now.UnixMilli())}
+		if !last.less(next) {
+			next = streamID{ms: last.ms, seq: last.seq + 1}
+		}
+	} else if next, err = parseStreamID(id); err != nil || !last.less(next) {
+		return "", ErrStreamID
+	}
+	entry := StreamEntry{ID: next.String(), Fields: map[string]string{}}
+	for f, v := range fields {
+		entry.Fields[f] = v
+	}
+	s.Entries = append(s.Entries, entry)
+	s.Last = entry.ID
+	if maxLen > 0 && int64(len(s.Entries)) > maxLen {
+		s.Entries = append([]StreamEntry{}, s.Entries[int64(len(s.Entries))-maxLen:]...)
+	}
+	return entry.ID, nil
+}
+
+// after returns up to count entries after the id after, every entry for a count of 0
+func (s *stream) after(after string, count int64) ([]StreamEntry, error) {
+	if after == "" {
+		after = "0"
+	}
+	from, err := parseStreamID(after)
+	if err != nil {
+		return nil, err
+	}
+	entries := []StreamEntry{}
+	for _, e := range s.Entries {
+		if count > 0 && int64(len(entries)) >= count {
+			break
+		}
+		id, _ := parseStreamID(e.ID)
+		if !from.less(id) {
+			continue
+		}
+		fields := make(map[string]string, len(e.Fields))
+		for f, v := range e.Fields {
+			fields[f] = v
+		}
+		entries = append(entries, StreamEntry{ID: e.ID, Fields: fields})
+	}
+	return entries, nil
+}
+
+func (s *stream) createGroup(group string, start string) error {
+	if _, ok := s.Groups[group]; ok {
+		return ErrGroupExists
+	}
+	if start == "$" {
+		start = s.Last
+	}
+	if _, err := parseStreamID(start); err != nil {
+		return err
+	}
+	if s.Groups == nil {
+		s.Groups = map[string]*streamGroup{}
+	}
+	s.Groups[group] = &streamGroup{Last: start, Pending: map[string]string{}}
+	return nil
+}
+
+func (s *stream) readGroup(group string, consumer string, count int64) ([]StreamEntry, error) {
+	g, ok := s.Groups[group]
+	if !ok {
+		return nil, ErrNoGroup
+	}
+	entries, err := s.after(g.Last, count)
+	if err != nil || len(entries) == 0 {
+		return entries, err
+	}
+	if g.Pending == nil {
+		g.Pending = map[string]string{}
+	}
+	for _, e := range entries {
+		g.Pending[e.ID] = consumer
+	}
+	g.Last = entries[len(entries)-1].ID
+	return entries, nil
+}
+
+func (s *stream) ack(group string, ids []string) int64 {
+	g, ok := s.Groups[group]
+	if !ok {
+		return 0
+	}
+	var acked int64
+	for _, id := range ids {
+		if _, ok := g.Pending[id]; ok {
+			delete(g.Pending, id)
+			acked++
+		}
+	}
+	return acked
+}
+
+// wait runs read until it reports a result, it runs read again when one of keys changes during up to timeout,
+// a timeout of 0 runs read once
+func wait(ctx context.Context, h *hub, keys []string, timeout time.Duration, read func() (bool, error)) error {
+	if timeout <= 0 {
+		_, err := read()
+		return err
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	watched := map[string]bool{}
+	for _, k := range keys {
+		watched[k] = true
+	}
+	pattern := "*"
+	if len(keys) == 1 {
+		pattern = Escape(keys[0])
+	}
+	events := h.watch(ctx, pattern)
+	for {
+		if ok, err := read(); ok || err != nil {
+			return err
+		}
+		for changed := false; !changed; {
+			e, ok := <-events
+			if !ok {
+				// the timeout elapsed or the store is closed
+				return parent.Err()
+			}
+			changed = watched[e.Key]
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTooLarge a value, a field, an element or a member exceeds the maximum size of the values
+var ErrTooLarge = errors.New("value exceeds the maximum size")
+
+// WithMaxValue returns s rejecting the writes of a value, a hash field, a list element, a member or a stream
+// field longer than max bytes, an Exec with such a write runs no op
+func WithMaxValue(s Store, max int64) Store {
+	return &limitedStore{Store: s, max: max}
+}
+
+type limitedStore struct {
+	Store
+	max int64
+}
+
+func (s *limitedStore) check(values ...string) error {
+	for _, v := range values {
+		if int64(len(v)) > s.max {
+			return ErrTooLarge
+		}
+	}
+	return nil
+}
+
+func (s *limitedStore) checkFields(fields map[string]string) error {
+	for _, v := range fields {
+		if int64(len(v)) > s.max {
+			return ErrTooLarge
+		}
+	}
+	return nil
+}
+
+func (s *limitedStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if err := s.check(value); err != nil {
+		return err
+	}
+	return s.Store.Set(ctx, key, value, ttl)
+}
+
+func (s *limitedStore) SetArgs(ctx context.Context, key string, value string, args SetArgs) (SetResult, error) {
+	if err := s.check(value); err != nil {
+		return SetResult{}, err
+	}
+	return s.Store.SetArgs(ctx, key, value, args)
+}
+
+func (s *limitedStore) HSet(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	if err := s.checkFields(fields); err != nil {
+		return 0, err
+	}
+	return s.Store.HSet(ctx, key, fields)
+}
+
+func (s *limitedStore) Exec(ctx context.Context, ops []Op, atomic bool) ([]Result, error) {
+	for _, op := range ops {
+		if err := s.check(op.Value); err != nil {
+			return nil, err
+		}
+		if err := s.checkFields(op.Fields); err != nil {
+			return nil, err
+		}
+	}
+	return s.Store.Exec(ctx, ops, atomic)
+}
+
+func (s *limitedStore) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	if err := s.check(values...); err != nil {
+		return 0, err
+	}
+	return s.Store.LPush(ctx, key, values...)
+}
+
+func (s *limitedStore) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	if err := s.check(values...); err != nil {
+		return 0, err
+	}
+	return s.Store.RPush(ctx, key, values...)
+}
+
+func (s *limitedStore) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	if err := s.check(members...); err != nil {
+		return 0, err
+	}
+	return s.Store.SAdd(ctx, key, members...)
+}
+
+func (s *limitedStore) ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error) {
+	for _, m := range members {
+		if err := s.check(m.Member); err != nil {
+			return 0, err
+		}
+	}
+	return s.Store.ZAdd(ctx, key, members...)
+}
+
+func (s *limitedStore) XAdd(ctx context.Context, key string, id string, fields map[string]string, maxLen int64) (string, error) {
+	if err := s.checkFields(fields); err != nil {
+		return "", err
+	}
+	return s.Store.XAdd(ctx, key, id, fields, maxLen)
+}
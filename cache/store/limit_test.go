@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithMaxValue(t *testing.T) {
+	m := NewMemory(0, 0)
+	defer m.Close()
+	s := WithMaxValue(m, 4)
+	ctx := context.Background()
+
+	if err := s.Set(ctx, "a", "1234", 0); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := s.Set(ctx, "a", "12345", 0); err != ErrTooLarge {
+		t.Fatalf("Set() large = %v, want %v", err, ErrTooLarge)
+	}
+	if _, err := s.HSet(ctx, "h", map[string]string{"f": "12345"}); err != ErrTooLarge {
+		t.Fatalf("HSet() large = %v, want %v", err, ErrTooLarge)
+	}
+	if _, err := s.RPush(ctx, "l", "1", "12345"); err != ErrTooLarge {
+		t.Fatalf("RPush() large = %v, want %v", err, ErrTooLarge)
+	}
+	ops := []Op{{Kind: OpSet, Key: "b", Value: "1"}, {Kind: OpSet, Key: "c", Value: "12345"}}
+	if _, err := s.Exec(ctx, ops, false); err != ErrTooLarge {
+		t.Fatalf("Exec() large = %v, want %v", err, ErrTooLarge)
+	}
+	if _, _, err := s.Get(ctx, "b"); err != ErrNotFound {
+		t.Fatalf("Get() of a rejected exec = %v, want %v", err, ErrNotFound)
+	}
+	if v, _, err := s.Get(ctx, "a"); err != nil || v != "1234" {
+		t.Fatalf("Get() = %q, %v", v, err)
+	}
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Match reports whether key matches the redis glob pattern, * matches any sequence, ? any character,
+// [abc], [^abc] and [a-z] a class of characters and \ escapes the next character
+func Match(pattern string, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if Match(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			n, ok := matchClass(pattern, key[0])
+			if !ok {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[n:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches c with the class at the start of pattern, it returns the length of the class
+func matchClass(pattern string, c byte) (int, bool) {
+	i := 1
+	not := i < len(pattern) && pattern[i] == '^'
+	if not {
+		i++
+	}
+	match := false
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			match = match || pattern[i] == c
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || (c >= lo && c <= hi)
+			i += 2
+		default:
+			match = match || pattern[i] == c
+		}
+	}
+	if i < len(pattern) {
+		// the closing bracket
+		i++
+	}
+	return i, match != not
+}
+
+// scanDefaults matches every key with an empty pattern and returns 10 keys without a count like SCAN
+func scanDefaults(pattern string, count int64) (string, int64) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if count <= 0 {
+		count = 10
+	}
+	return pattern, count
+}
+
+// scanSorted returns a page of the sorted keys matching pattern after cursor, the cursor of the
+// local stores is the quoted last key of the previous page
+func scanSorted(keys []string, cursor string, pattern string, count int64) ([]string, string, error) {
+	after, err := afterCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(keys)
+	i := 0
+	if cursor != "" {
+		i = sort.Search(len(keys), func(i int) bool { return keys[i] > after })
+	}
+	var page []string
+	for ; i < len(keys) && int64(len(page)) < count; i++ {
+		if Match(pattern, keys[i]) {
+			page = append(page, keys[i])
+		}
+	}
+	if i >= len(keys) {
+		return page, "", nil
+	}
+	return page, strconv.Quote(keys[i-1]), nil
+}
+
+// afterCursor returns the last key of the previous page of a local store cursor
+func afterCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	after, err := strconv.Unquote(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return after, nil
+}
+
+// Escape quotes the glob characters of s
+func Escape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
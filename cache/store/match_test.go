@@ -0,0 +1,32 @@
+package store
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "cache:identity", true},
+		{"cache:*", "cache:identity:rsa", true},
+		{"cache:*:rsa", "cache:identity:rsa", true},
+		{"cache:*:rsa", "cache:identity:user", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"a**b", "axyb", true},
+	} {
+		if got := Match(c.pattern, c.key); got != c.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", c.pattern, c.key, got, c.want)
+		}
+	}
+}
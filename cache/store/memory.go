@@ -0,0 +1,754 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory keeps the keys in process, expired keys are removed when they are read and by a periodic sweep,
+// the least recently used keys are evicted beyond the maximum number of keys
+type Memory struct {
+	sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	// lru keys, the most recently used first
+	lru *list.List
+	// locks leases by lock name and fences last fencing token by lock name
+	locks  map[string]*lease
+	fences map[string]int64
+	hub    *hub
+	exit   chan bool
+	once   sync.Once
+}
+
+type lease struct {
+	owner   string
+	token   int64
+	expires time.Time
+}
+
+type entry struct {
+	key   string
+	value string
+	// hash fields, nil for a string value
+	hash map[string]string
+	data
+	expires time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// ttl returns the time to live of a live entry, NoExpiry when it does not expire
+func (e *entry) ttl() time.Duration {
+	if e.expires.IsZero() {
+		return NoExpiry
+	}
+	return remaining(e.expires)
+}
+
+// NewMemory returns a memory store keeping up to max keys, 0 is unlimited, expired keys are swept every sweep
+func NewMemory(max int, sweep time.Duration) *Memory {
+	m := &Memory{
+		max:     max,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		locks:   map[string]*lease{},
+		fences:  map[string]int64{},
+		hub:     newHub(),
+		exit:    make(chan bool),
+	}
+	if sweep > 0 {
+		go m.run(sweep)
+	}
+	return m
+}
+
+func (m *Memory) run(sweep time.Duration) {
+	ticker := time.NewTicker(sweep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.exit:
+			return
+		case <-ticker.C:
+			m.sweep()
+		}
+	}
+}
+
+// sweep removes the expired keys and locks
+func (m *Memory) sweep() {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	for _, el := range m.entries {
+		if el.Value.(*entry).expired(now) {
+			m.remove(el)
+			m.hub.notify(el.Value.(*entry).key, EventExpired)
+		}
+	}
+	for name, l := range m.locks {
+		if !now.Before(l.expires) {
+			delete(m.locks, name)
+		}
+	}
+}
+
+// get returns the live entry of key and marks it as used, the lock must be held
+func (m *Memory) get(key string) *entry {
+	el, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	if e.expired(time.Now()) {
+		m.remove(el)
+		m.hub.notify(key, EventExpired)
+		return nil
+	}
+	m.lru.MoveToFront(el)
+	return e
+}
+
+// put adds a new entry and evicts the least recently used ones beyond max, the lock must be held
+func (m *Memory) put(e *entry) {
+	if el, ok := m.entries[e.key]; ok {
+		m.remove(el)
+	}
+	m.entries[e.key] = m.lru.PushFront(e)
+	for m.max > 0 && m.lru.Len() > m.max {
+		el := m.lru.Back()
+		m.remove(el)
+		m.hub.notify(el.Value.(*entry).key, EventEvicted)
+	}
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*entry).key)
+}
+
+func (m *Memory) Get(_ context.Context, key string) (string, time.Duration, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.value(key)
+}
+
+func (m *Memory) Set(_ context.Context, key string, value string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.delete(keys...), nil
+}
+
+func (m *Memory) IncrBy(_ context.Context, key string, n int64) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.incrBy(key, n)
+}
+
+func (m *Memory) Scan(_ context.Context, cursor string, pattern string, count int64) ([]string, string, error) {
+	pattern, count = scanDefaults(pattern, count)
+	m.Lock()
+	now := time.Now()
+	keys := make([]string, 0, len(m.entries))
+	for key, el := range m.entries {
+		if !el.Value.(*entry).expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	m.Unlock()
+	return scanSorted(keys, cursor, pattern, count)
+}
+
+func (m *Memory) SetArgs(_ context.Context, key string, value string, args SetArgs) (SetResult, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.setArgs(key, value, args)
+}
+
+func (m *Memory) Expire(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	e := m.get(key)
+	if e == nil {
+		return false, nil
+	}
+	if ttl <= 0 {
+		m.remove(m.entries[key])
+		m.hub.notify(key, EventDel)
+		return true, nil
+	}
+	e.expires = time.Now().Add(ttl)
+	m.hub.notify(key, EventExpire)
+	return true, nil
+}
+
+func (m *Memory) Persist(_ context.Context, key string) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	e := m.get(key)
+	if e == nil || e.expires.IsZero() {
+		return false, nil
+	}
+	e.expires = time.Time{}
+	m.hub.notify(key, EventPersist)
+	return true, nil
+}
+
+func (m *Memory) TTL(_ context.Context, key string) (time.Duration, error) {
+	m.Lock()
+	defer m.Unlock()
+	e := m.get(key)
+	if e == nil {
+		return Missing, nil
+	}
+	return e.ttl(), nil
+}
+
+func (m *Memory) HGet(_ context.Context, key string, field string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.hget(key, field)
+}
+
+func (m *Memory) HSet(_ context.Context, key string, fields map[string]string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.hset(key, fields)
+}
+
+func (m *Memory) HGetAll(_ context.Context, key string) (map[string]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.hgetAll(key)
+}
+
+// Exec runs ops under the lock of the store, so they are always atomic
+func (m *Memory) Exec(_ context.Context, ops []Op, _ bool) ([]Result, error) {
+	m.Lock()
+	defer m.Unlock()
+	results := make([]Result, len(ops))
+	for i, op := range ops {
+		r := &results[i]
+		switch op.Kind {
+		case OpGet:
+			r.Value, r.TTL, r.Err = m.value(op.Key)
+		case OpSet:
+			m.set(op.Key, op.Value, op.TTL)
+		case OpDelete:
+			r.Int = m.delete(op.Key)
+		case OpIncrBy:
+			r.Int, r.Err = m.incrBy(op.Key, op.By)
+		case OpHGet:
+			r.Value, r.Err = m.hget(op.Key, op.Field)
+		case OpHSet:
+			r.Int, r.Err = m.hset(op.Key, op.Fields)
+		case OpHGetAll:
+			r.Fields, r.Err = m.hgetAll(op.Key)
+		default:
+			r.Err = ErrUnknownOp
+		}
+	}
+	return results, nil
+}
+
+// the operations below expect the lock to be held
+
+func (m *Memory) value(key string) (string, time.Duration, error) {
+	e := m.get(key)
+	if e == nil {
+		return "", 0, ErrNotFound
+	}
+	if kindOf(e.hash, &e.data) != kindString {
+		return "", 0, ErrWrongType
+	}
+	return e.value, e.ttl(), nil
+}
+
+func (m *Memory) set(key string, value string, ttl time.Duration) {
+	e := &entry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	m.put(e)
+	m.hub.notify(key, EventSet)
+}
+
+func (m *Memory) setArgs(key string, value string, args SetArgs) (SetResult, error) {
+	var res SetResult
+	old := m.get(key)
+	if args.Get && old != nil {
+		if kindOf(old.hash, &old.data) != kindString {
+			return res, ErrWrongType
+		}
+		res.Old, res.Existed = old.value, true
+	}
+	if args.skip(old != nil) {
+		return res, nil
+	}
+	e := &entry{key: key, value: value}
+	switch {
+	case args.KeepTTL && old != nil:
+		e.expires = old.expires
+	case !args.KeepTTL && args.TTL > 0:
+		e.expires = time.Now().Add(args.TTL)
+	}
+	m.put(e)
+	m.hub.notify(key, EventSet)
+	res.Set = true
+	return res, nil
+}
+
+func (m *Memory) delete(keys ...string) int64 {
+	var n int64
+	for _, key := range keys {
+		if e := m.get(key); e != nil {
+			m.remove(m.entries[key])
+			m.hub.notify(key, EventDel)
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Memory) incrBy(key string, n int64) (int64, error) {
+	e := m.get(key)
+	if e == nil {
+		e = &entry{key: key, value: "0"}
+		m.put(e)
+	}
+	if kindOf(e.hash, &e.data) != kindString {
+		return 0, ErrWrongType
+	}
+	v, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	if (n > 0 && v > math.MaxInt64-n) || (n < 0 && v < math.MinInt64-n) {
+		return 0, ErrNotInteger
+	}
+	v += n
+	e.value = strconv.FormatInt(v, 10)
+	m.hub.notify(key, EventIncrBy)
+	return v, nil
+}
+
+func (m *Memory) hget(key string, field string) (string, error) {
+	e := m.get(key)
+	if e == nil {
+		return "", ErrNotFound
+	}
+	if e.hash == nil {
+		return "", ErrWrongType
+	}
+	v, ok := e.hash[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (m *Memory) hset(key string, fields map[string]string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	e := m.get(key)
+	if e == nil {
+		e = &entry{key: key, hash: map[string]string{}}
+		m.put(e)
+	}
+	if e.hash == nil {
+		return 0, ErrWrongType
+	}
+	var added int64
+	for f, v := range fields {
+		if _, ok := e.hash[f]; !ok {
+			added++
+		}
+		e.hash[f] = v
+	}
+	m.hub.notify(key, EventHSet)
+	return added, nil
+}
+
+func (m *Memory) hgetAll(key string) (map[string]string, error) {
+	e := m.get(key)
+	if e == nil {
+		return map[string]string{}, nil
+	}
+	if e.hash == nil {
+		return nil, ErrWrongType
+	}
+	fields := make(map[string]string, len(e.hash))
+	for f, v := range e.hash {
+		fields[f] = v
+	}
+	return fields, nil
+}
+
+func (m *Memory) Size(_ context.Context, key string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	e := m.get(key)
+	if e == nil {
+		return 0, nil
+	}
+	return size(key, e.value, e.hash) + e.data.size(), nil
+}
+
+// typed returns the live entry of key when it holds kind k, a new entry that is not added yet when key
+// does not exist, the lock must be held
+func (m *Memory) typed(key string, k kind) (*entry, error) {
+	e := m.get(key)
+	if e == nil {
+		return &entry{key: key, data: newData(k)}, nil
+	}
+	if kindOf(e.hash, &e.data) != k {
+		return nil, ErrWrongType
+	}
+	return e, nil
+}
+
+// change runs fn on the entry of key holding kind k, a new entry is added when fn changes it and an entry
+// is removed once it is empty, event is notified when fn changes the entry, the lock must be held
+func (m *Memory) change(key string, k kind, event string, fn func(e *entry) (bool, error)) error {
+	e, err := m.typed(key, k)
+	if err != nil {
+		return err
+	}
+	changed, err := fn(e)
+	if err != nil || !changed {
+		return err
+	}
+	el, exists := m.entries[key]
+	switch {
+	case e.empty():
+		if exists {
+			m.remove(el)
+		}
+		m.hub.notify(key, event)
+		m.hub.notify(key, EventDel)
+		return nil
+	case !exists:
+		m.put(e)
+	}
+	m.hub.notify(key, event)
+	return nil
+}
+
+func (m *Memory) LPush(_ context.Context, key string, values ...string) (int64, error) {
+	return m.push(key, true, values)
+}
+
+func (m *Memory) RPush(_ context.Context, key string, values ...string) (int64, error) {
+	return m.push(key, false, values)
+}
+
+func (m *Memory) push(key string, left bool, values []string) (n int64, err error) {
+	m.Lock()
+	defer m.Unlock()
+	err = m.change(key, kindList, side(left, EventLPush, EventRPush), func(e *entry) (bool, error) {
+		n = e.push(left, values)
+		return len(values) > 0, nil
+	})
+	return n, err
+}
+
+func (m *Memory) LPop(_ context.Context, key string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.pop(key, true)
+}
+
+func (m *Memory) RPop(_ context.Context, key string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.pop(key, false)
+}
+
+// pop expects the lock to be held
+func (m *Memory) pop(key string, left bool) (v string, err error) {
+	err = m.change(key, kindList, side(left, EventLPop, EventRPop), func(e *entry) (bool, error) {
+		if len(e.List) == 0 {
+			return false, ErrNotFound
+		}
+		v = e.data.pop(left)
+		return true, nil
+	})
+	return v, err
+}
+
+func (m *Memory) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return m.bpop(ctx, true, timeout, keys)
+}
+
+func (m *Memory) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return m.bpop(ctx, false, timeout, keys)
+}
+
+func (m *Memory) bpop(ctx context.Context, left bool, timeout time.Duration, keys []string) (key string, v string, err error) {
+	err = wait(ctx, m.hub, keys, timeout, func() (bool, error) {
+		m.Lock()
+		defer m.Unlock()
+		for _, k := range keys {
+			popped, err := m.pop(k, left)
+			if err == ErrNotFound {
+				continue
+			}
+			key, v = k, popped
+			return true, err
+		}
+		return false, nil
+	})
+	if err == nil && key == "" {
+		err = ErrNotFound
+	}
+	return key, v, err
+}
+
+func (m *Memory) LRange(_ context.Context, key string, start int64, stop int64) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindList)
+	if err != nil {
+		return nil, err
+	}
+	return lrange(e.List, start, stop), nil
+}
+
+func (m *Memory) SAdd(_ context.Context, key string, members ...string) (added int64, err error) {
+	m.Lock()
+	defer m.Unlock()
+	err = m.change(key, kindSet, EventSAdd, func(e *entry) (bool, error) {
+		added = e.sadd(members)
+		return added > 0, nil
+	})
+	return added, err
+}
+
+func (m *Memory) SRem(_ context.Context, key string, members ...string) (removed int64, err error) {
+	m.Lock()
+	defer m.Unlock()
+	err = m.change(key, kindSet, EventSRem, func(e *entry) (bool, error) {
+		removed = e.srem(members)
+		return removed > 0, nil
+	})
+	return removed, err
+}
+
+func (m *Memory) SMembers(_ context.Context, key string) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindSet)
+	if err != nil {
+		return nil, err
+	}
+	return e.smembers(), nil
+}
+
+func (m *Memory) SIsMember(_ context.Context, key string, member string) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindSet)
+	if err != nil {
+		return false, err
+	}
+	return e.Set[member], nil
+}
+
+func (m *Memory) ZAdd(_ context.Context, key string, members ...ZMember) (added int64, err error) {
+	m.Lock()
+	defer m.Unlock()
+	err = m.change(key, kindZSet, EventZAdd, func(e *entry) (bool, error) {
+		added = e.zadd(members)
+		return len(members) > 0, nil
+	})
+	return added, err
+}
+
+func (m *Memory) ZRangeByScore(_ context.Context, key string, min float64, max float64, offset int64, count int64) ([]ZMember, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindZSet)
+	if err != nil {
+		return nil, err
+	}
+	return e.zrangeByScore(min, max, offset, count), nil
+}
+
+func (m *Memory) ZRank(_ context.Context, key string, member string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindZSet)
+	if err != nil {
+		return 0, err
+	}
+	return e.zrank(member, false)
+}
+
+func (m *Memory) ZRevRank(_ context.Context, key string, member string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindZSet)
+	if err != nil {
+		return 0, err
+	}
+	return e.zrank(member, true)
+}
+
+func (m *Memory) XAdd(_ context.Context, key string, id string, fields map[string]string, maxLen int64) (added string, err error) {
+	m.Lock()
+	defer m.Unlock()
+	err = m.change(key, kindStream, EventXAdd, func(e *entry) (bool, error) {
+		added, err = e.Stream.add(id, fields, maxLen, time.Now())
+		return err == nil, err
+	})
+	return added, err
+}
+
+func (m *Memory) XRead(ctx context.Context, key string, after string, count int64, block time.Duration) (entries []StreamEntry, err error) {
+	if after == "$" {
+		m.Lock()
+		e, err := m.typed(key, kindStream)
+		m.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		after = e.Stream.Last
+	}
+	err = wait(ctx, m.hub, []string{key}, block, func() (bool, error) {
+		m.Lock()
+		defer m.Unlock()
+		e, err := m.typed(key, kindStream)
+		if err != nil {
+			return false, err
+		}
+		entries, err = e.Stream.after(after, count)
+		return len(entries) > 0, err
+	})
+	return entries, err
+}
+
+func (m *Memory) XGroupCreate(_ context.Context, key string, group string, start string) error {
+	m.Lock()
+	defer m.Unlock()
+	return m.change(key, kindStream, EventXGroup, func(e *entry) (bool, error) {
+		return true, e.Stream.createGroup(group, start)
+	})
+}
+
+func (m *Memory) XReadGroup(ctx context.Context, key string, group string, consumer string, count int64, block time.Duration) (entries []StreamEntry, err error) {
+	err = wait(ctx, m.hub, []string{key}, block, func() (bool, error) {
+		m.Lock()
+		defer m.Unlock()
+		e, err := m.typed(key, kindStream)
+		if err != nil {
+			return false, err
+		}
+		entries, err = e.Stream.readGroup(group, consumer, count)
+		return len(entries) > 0, err
+	})
+	return entries, err
+}
+
+func (m *Memory) XAck(_ context.Context, key string, group string, ids ...string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	e, err := m.typed(key, kindStream)
+	if err != nil {
+		return 0, err
+	}
+	return e.Stream.ack(group, ids), nil
+}
+
+func (m *Memory) Acquire(_ context.Context, name string, owner string, ttl time.Duration) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	l := m.lease(name)
+	if l != nil && l.owner != owner {
+		return 0, ErrLocked
+	}
+	if l == nil {
+		m.fences[name]++
+		l = &lease{owner: owner, token: m.fences[name]}
+		m.locks[name] = l
+	}
+	l.expires = time.Now().Add(ttl)
+	return l.token, nil
+}
+
+func (m *Memory) Renew(_ context.Context, name string, owner string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	l := m.lease(name)
+	if l == nil || l.owner != owner {
+		return ErrNotOwner
+	}
+	l.expires = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *Memory) Release(_ context.Context, name string, owner string) error {
+	m.Lock()
+	defer m.Unlock()
+	l := m.lease(name)
+	if l == nil || l.owner != owner {
+		return ErrNotOwner
+	}
+	delete(m.locks, name)
+	return nil
+}
+
+// lease returns the unexpired lease of the lock name, the lock must be held
+func (m *Memory) lease(name string) *lease {
+	l, ok := m.locks[name]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(l.expires) {
+		delete(m.locks, name)
+		return nil
+	}
+	return l
+}
+
+func (m *Memory) Publish(_ context.Context, channel string, payload string) (int64, error) {
+	return m.hub.publish(channel, payload), nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, channels []string, patterns []string) (<-chan Message, error) {
+	return m.hub.subscribe(ctx, channels, patterns), nil
+}
+
+func (m *Memory) Watch(ctx context.Context, pattern string) (<-chan Event, error) {
+	return m.hub.watch(ctx, pattern), nil
+}
+
+func (m *Memory) Ping(context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.once.Do(func() {
+		close(m.exit)
+		m.hub.close()
+	})
+	return nil
+}
+
+func (m *Memory) String() string {
+	return "memory"
+}
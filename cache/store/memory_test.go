@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	s := NewMemory(0, time.Minute)
+	defer s.Close()
+	testStore(t, s)
+	testExec(t, s)
+	testLocker(t, s)
+	testExpiry(t, s)
+	testPubSub(t, s)
+	testTypes(t, s)
+}
+
+func TestMemoryEviction(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory(2, 0)
+	defer s.Close()
+	for _, k := range []string{"a", "b"} {
+		if err := s.Set(ctx, k, k, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a is used after b, so b is evicted
+	if _, _, err := s.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "c", "c", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, "b"); err != ErrNotFound {
+		t.Fatalf("Get() evicted error = %v, want %v", err, ErrNotFound)
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, _, err := s.Get(ctx, k); err != nil {
+			t.Fatalf("Get(%s) error = %v", k, err)
+		}
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory(0, 10*time.Millisecond)
+	defer s.Close()
+	if err := s.Set(ctx, "a", "a", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	s.Lock()
+	n := len(s.entries)
+	s.Unlock()
+	if n != 0 {
+		t.Fatalf("entries = %d after the sweep, want 0", n)
+	}
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// NearChannel channel on which the replicas broadcast the keys they write
+const NearChannel = "cache:near:invalidate"
+
+// Near keeps in process copies of the string values read from a store shared by replicas, it is bounded
+// by a maximum number of keys and the copies live up to a ttl, every write drops the copies of its keys
+// on every replica through NearChannel, a copy read before an invalidation is never kept after it,
+// the writes of other clients of the store and lost broadcasts are only seen once the copies expire
+type Near struct {
+	Store
+	sync.Mutex
+	max     int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	// lru copies, the most recently used first
+	lru *list.List
+	// generation counts the invalidations, a read fills its copy only when none happened meanwhile
+	generation uint64
+	// ended is set once the broadcasts are no longer received
+	ended  bool
+	cancel context.CancelFunc
+}
+
+type nearEntry struct {
+	key   string
+	value string
+	// expires of the key, zero when it does not expire, until of the copy
+	expires time.Time
+	until   time.Time
+}
+
+// WithNear returns s with a near tier of up to max copies living up to ttl
+func WithNear(s Store, max int, ttl time.Duration) (*Near, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, err := s.Subscribe(ctx, []string{NearChannel}, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	n := &Near{
+		Store:   s,
+		max:     max,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		cancel:  cancel,
+	}
+	go n.run(messages)
+	return n, nil
+}
+
+func (n *Near) run(messages <-chan Message) {
+	for m := range messages {
+		var keys []string
+		if err := json.Unmarshal([]byte(m.Payload), &keys); err != nil {
+			continue
+		}
+		n.drop(keys...)
+	}
+	// the subscription ended, no copy can be trusted anymore
+	n.Lock()
+	defer n.Unlock()
+	n.generation++
+	n.entries = map[string]*list.Element{}
+	n.lru.Init()
+	n.ended = true
+}
+
+// Get returns the copy of key or reads key from the store and keeps a copy
+func (n *Near) Get(ctx context.Context, key string) (string, time.Duration, error) {
+	n.Lock()
+	if el, ok := n.entries[key]; ok {
+		e := el.Value.(*nearEntry)
+		now := time.Now()
+		if now.Before(e.until) {
+			n.lru.MoveToFront(el)
+			n.Unlock()
+			if e.expires.IsZero() {
+				return e.value, NoExpiry, nil
+			}
+			return e.value, remaining(e.expires), nil
+		}
+		n.remove(el)
+	}
+	generation := n.generation
+	n.Unlock()
+
+	v, ttl, err := n.Store.Get(ctx, key)
+	if err != nil {
+		return v, ttl, err
+	}
+	n.Lock()
+	defer n.Unlock()
+	if n.generation == generation && !n.ended {
+		n.put(key, v, ttl)
+	}
+	return v, ttl, nil
+}
+
+// put keeps a copy of key up to the ttl of the tier or the expiration of key, the lock must be held
+func (n *Near) put(key string, value string, ttl time.Duration) {
+	now := time.Now()
+	e := &nearEntry{key: key, value: value, until: now.Add(n.ttl)}
+	if ttl != NoExpiry {
+		e.expires = now.Add(ttl)
+		if e.expires.Before(e.until) {
+			e.until = e.expires
+		}
+	}
+	if el, ok := n.entries[key]; ok {
+		n.remove(el)
+	}
+	n.entries[key] = n.lru.PushFront(e)
+	for n.max > 0 && n.lru.Len() > n.max {
+		n.remove(n.lru.Back())
+	}
+}
+
+func (n *Near) remove(el *list.Element) {
+	n.lru.Remove(el)
+	delete(n.entries, el.Value.(*nearEntry).key)
+}
+
+// drop removes the copies of keys and fails the reads in progress
+func (n *Near) drop(keys ...string) {
+	n.Lock()
+	defer n.Unlock()
+	n.generation++
+	for _, key := range keys {
+		if el, ok := n.entries[key]; ok {
+			n.remove(el)
+		}
+	}
+}
+
+// invalidate drops the copies of keys on this replica and broadcasts them to the others, it runs after
+// a write whatever its outcome, even when the caller gave up
+func (n *Near) invalidate(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	n.drop(keys...)
+	payload, _ := json.Marshal(keys)
+	_, _ = n.Store.Publish(context.Background(), NearChannel, string(payload))
+}
+
+func (n *Near) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	defer n.invalidate(key)
+	return n.Store.Set(ctx, key, value, ttl)
+}
+
+func (n *Near) SetArgs(ctx context.Context, key string, value string, args SetArgs) (SetResult, error) {
+	defer n.invalidate(key)
+	return n.Store.SetArgs(ctx, key, value, args)
+}
+
+func (n *Near) Delete(ctx context.Context, keys ...string) (int64, error) {
+	defer n.invalidate(keys...)
+	return n.Store.Delete(ctx, keys...)
+}
+
+func (n *Near) IncrBy(ctx context.Context, key string, by int64) (int64, error) {
+	defer n.invalidate(key)
+	return n.Store.IncrBy(ctx, key, by)
+}
+
+func (n *Near) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	defer n.invalidate(key)
+	return n.Store.Expire(ctx, key, ttl)
+}
+
+func (n *Near) Persist(ctx context.Context, key string) (bool, error) {
+	defer n.invalidate(key)
+	return n.Store.Persist(ctx, key)
+}
+
+// Exec reads the store, not the copies
+func (n *Near) Exec(ctx context.Context, ops []Op, atomic bool) ([]Result, error) {
+	var keys []string
+	for _, op := range ops {
+		if op.Writes() {
+			keys = append(keys, op.Key)
+		}
+	}
+	defer n.invalidate(keys...)
+	return n.Store.Exec(ctx, ops, atomic)
+}
+
+func (n *Near) Close() error {
+	n.cancel()
+	return n.Store.Close()
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNear(t *testing.T) {
+	s, err := WithNear(NewMemory(0, 0), 100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	testStore(t, s)
+	testExec(t, s)
+	testExpiry(t, s)
+}
+
+func TestNearInvalidation(t *testing.T) {
+	ctx := context.Background()
+	shared := NewMemory(0, 0)
+	defer shared.Close()
+	a, err := WithNear(shared, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := WithNear(shared, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.cancel()
+	defer a.cancel()
+
+	if err := a.Set(ctx, "rsa", "v1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := b.Get(ctx, "rsa"); err != nil || v != "v1" {
+		t.Fatalf("Get() = %q, %v, want v1", v, err)
+	}
+	// a write behind the back of the replicas is not seen by the copy
+	if err := shared.Set(ctx, "rsa", "outside", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := b.Get(ctx, "rsa"); v != "v1" {
+		t.Fatalf("Get() = %q, want the copy v1", v)
+	}
+
+	if err := a.Set(ctx, "rsa", "v2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		v, ttl, err := b.Get(ctx, "rsa")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v == "v2" {
+			if ttl <= 0 || ttl > time.Minute {
+				t.Fatalf("Get() ttl = %v, want about a minute", ttl)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Get() = %q after the broadcast, want v2", v)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := a.Delete(ctx, "rsa"); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(time.Second)
+	for {
+		if _, _, err := b.Get(ctx, "rsa"); err == ErrNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Get() found the deleted key after the broadcast")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, k := range []string{"x", "y", "z"} {
+		_ = shared.Set(ctx, k, k, 0)
+		_, _, _ = b.Get(ctx, k)
+	}
+	b.Lock()
+	n := b.lru.Len()
+	b.Unlock()
+	if n != 2 {
+		t.Fatalf("copies = %d, want at most 2", n)
+	}
+}
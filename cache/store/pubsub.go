@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// SubscriptionBuffer messages or events a subscription keeps for a slow subscriber, the next ones are dropped
+const SubscriptionBuffer = 100
+
+// Key events, named like the redis keyspace notifications
+const (
+	EventSet     = "set"
+	EventDel     = "del"
+	EventIncrBy  = "incrby"
+	EventHSet    = "hset"
+	EventExpire  = "expire"
+	EventPersist = "persist"
+	EventExpired = "expired"
+	EventEvicted = "evicted"
+	EventLPush   = "lpush"
+	EventRPush   = "rpush"
+	EventLPop    = "lpop"
+	EventRPop    = "rpop"
+	EventSAdd    = "sadd"
+	EventSRem    = "srem"
+	EventZAdd    = "zadd"
+	EventXAdd    = "xadd"
+	EventXGroup  = "xgroup-create"
+)
+
+// Message published on Channel, Pattern is the pattern of the subscription that matched it, empty for
+// a subscription to the channel
+type Message struct {
+	Channel string
+	Pattern string
+	Payload string
+}
+
+// Event change of a key, Op is one of the key events or an other redis keyspace event
+type Event struct {
+	Key string
+	Op  string
+}
+
+// PubSub publishes messages on channels and notifies the changes of the keys
+type PubSub interface {
+	// Publish sends payload to the subscribers of channel and returns how many received it
+	Publish(ctx context.Context, channel string, payload string) (int64, error)
+	// Subscribe returns the messages of channels and of the channels matching the glob patterns,
+	// the subscription ends and the returned channel is closed once ctx is done
+	Subscribe(ctx context.Context, channels []string, patterns []string) (<-chan Message, error)
+	// Watch returns the events of the keys matching the glob pattern, the watch ends and the returned
+	// channel is closed once ctx is done
+	Watch(ctx context.Context, pattern string) (<-chan Event, error)
+}
+
+// hub is the pub/sub of the local stores
+type hub struct {
+	sync.Mutex
+	subscribers map[*subscriber]bool
+	watchers    map[*watcher]bool
+	exit        chan bool
+	once        sync.Once
+}
+
+type subscriber struct {
+	channels map[string]bool
+	patterns []string
+	messages chan Message
+}
+
+type watcher struct {
+	pattern string
+	events  chan Event
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: map[*subscriber]bool{},
+		watchers:    map[*watcher]bool{},
+		exit:        make(chan bool),
+	}
+}
+
+func (h *hub) publish(channel string, payload string) int64 {
+	h.Lock()
+	defer h.Unlock()
+	var n int64
+	for s := range h.subscribers {
+		if s.channels[channel] && s.send(Message{Channel: channel, Payload: payload}) {
+			n++
+		}
+		for _, p := range s.patterns {
+			if Match(p, channel) && s.send(Message{Channel: channel, Pattern: p, Payload: payload}) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// send drops the message when the buffer of s is full
+func (s *subscriber) send(m Message) bool {
+	select {
+	case s.messages <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *hub) subscribe(ctx context.Context, channels []string, patterns []string) <-chan Message {
+	s := &subscriber{
+		channels: map[string]bool{},
+		patterns: patterns,
+		messages: make(chan Message, SubscriptionBuffer),
+	}
+	for _, c := range channels {
+		s.channels[c] = true
+	}
+	h.Lock()
+	h.subscribers[s] = true
+	h.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.exit:
+		}
+		h.Lock()
+		defer h.Unlock()
+		delete(h.subscribers, s)
+		close(s.messages)
+	}()
+	return s.messages
+}
+
+func (h *hub) watch(ctx context.Context, pattern string) <-chan Event {
+	w := &watcher{pattern: pattern, events: make(chan Event, SubscriptionBuffer)}
+	h.Lock()
+	h.watchers[w] = true
+	h.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.exit:
+		}
+		h.Lock()
+		defer h.Unlock()
+		delete(h.watchers, w)
+		close(w.events)
+	}()
+	return w.events
+}
+
+// notify sends the event op of key to the watchers, it never blocks so it can run under the lock of a store
+func (h *hub) notify(key string, op string) {
+	h.Lock()
+	defer h.Unlock()
+	for w := range h.watchers {
+		if !Match(w.pattern, key) {
+			continue
+		}
+		select {
+		case w.events <- Event{Key: key, Op: op}:
+		default:
+		}
+	}
+}
+
+// close ends every subscription and watch
+func (h *hub) close() {
+	h.once.Do(func() {
+		close(h.exit)
+	})
+}
@@ -0,0 +1,732 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// acquireScript leases KEYS[1] to the owner ARGV[1] for ARGV[2] milliseconds and returns its fencing
+	// token counted by KEYS[2], -1 when another owner holds it
+	acquireScript = redis.NewScript(`
+local owner = redis.call('HGET', KEYS[1], 'owner')
+if owner and owner ~= ARGV[1] then
+	return -1
+end
+if owner then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return tonumber(redis.call('HGET', KEYS[1], 'token'))
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('HSET', KEYS[1], 'owner', ARGV[1], 'token', token)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return token
+`)
+	// renewScript extends the lease of KEYS[1] to ARGV[2] milliseconds when ARGV[1] owns it
+	renewScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'owner') == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	// releaseScript deletes KEYS[1] when ARGV[1] owns it
+	releaseScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'owner') == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// Redis keeps the keys in a standalone, sentinel or cluster redis
+type Redis struct {
+	client redis.UniversalClient
+}
+
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client}
+}
+
+// Get reads the value and the time to live in one transaction, so the key cannot expire in between
+func (r *Redis) Get(ctx context.Context, key string) (string, time.Duration, error) {
+	results, err := r.Exec(ctx, []Op{{Kind: OpGet, Key: key}}, true)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Value, results[0].TTL, results[0].Err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return redisError(r.client.Set(ctx, key, value, ttl).Err())
+}
+
+// SetArgs runs SET with its NX, XX, PX, KEEPTTL and GET arguments, GET needs redis 6.2 and GET with NX
+// redis 7.0
+func (r *Redis) SetArgs(ctx context.Context, key string, value string, args SetArgs) (SetResult, error) {
+	a := redis.SetArgs{Mode: string(args.Mode), KeepTTL: args.KeepTTL, Get: args.Get}
+	if !args.KeepTTL {
+		a.TTL = args.TTL
+	}
+	old, err := r.client.SetArgs(ctx, key, value, a).Result()
+	// a nil reply is a missing previous value with GET, a failed condition without it
+	missing := errors.Is(err, redis.Nil)
+	if err != nil && !missing {
+		return SetResult{}, redisError(err)
+	}
+	if !args.Get {
+		return SetResult{Set: !missing}, nil
+	}
+	return SetResult{Set: !args.skip(!missing), Old: old, Existed: !missing}, nil
+}
+
+// Expire runs PEXPIRE, the time to live is rounded down to the millisecond
+func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		n, err := r.client.Del(ctx, key).Result()
+		return n > 0, redisError(err)
+	}
+	ok, err := r.client.PExpire(ctx, key, ttl).Result()
+	return ok, redisError(err)
+}
+
+func (r *Redis) Persist(ctx context.Context, key string) (bool, error) {
+	ok, err := r.client.Persist(ctx, key).Result()
+	return ok, redisError(err)
+}
+
+// TTL runs PTTL, its -1 and -2 replies are NoExpiry and Missing
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	return ttl, redisError(err)
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	n, err := r.client.Del(ctx, keys...).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	v, err := r.client.IncrBy(ctx, key, n).Result()
+	return v, redisError(err)
+}
+
+// Scan runs SCAN, the cursor of a cluster is the index of the master, ordered by address, and its own cursor,
+// a scan started before the masters changed may skip or repeat keys
+func (r *Redis) Scan(ctx context.Context, cursor string, pattern string, count int64) ([]string, string, error) {
+	pattern, count = scanDefaults(pattern, count)
+	nodes, err := r.masters(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	node, c := 0, uint64(0)
+	if cursor != "" {
+		n, sc, ok := strings.Cut(cursor, ":")
+		node, err = strconv.Atoi(n)
+		if err == nil && ok {
+			c, err = strconv.ParseUint(sc, 10, 64)
+		}
+		if err != nil || !ok || node < 0 || node >= len(nodes) {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+	var keys []string
+	for int64(len(keys)) < count {
+		page, next, err := nodes[node].Scan(ctx, c, pattern, count-int64(len(keys))).Result()
+		if err != nil {
+			return nil, "", redisError(err)
+		}
+		keys = append(keys, page...)
+		c = next
+		if c == 0 {
+			if node++; node == len(nodes) {
+				return keys, "", nil
+			}
+		}
+	}
+	return keys, fmt.Sprintf("%d:%d", node, c), nil
+}
+
+// masters returns the client of every master of a cluster, ordered by address, or the client of the redis
+func (r *Redis) masters(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{r.client}, nil
+	}
+	var mu sync.Mutex
+	var masters []*redis.Client
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		masters = append(masters, client)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Options().Addr < masters[j].Options().Addr
+	})
+	nodes := make([]redis.Cmdable, len(masters))
+	for i, m := range masters {
+		nodes[i] = m
+	}
+	return nodes, nil
+}
+
+func (r *Redis) HGet(ctx context.Context, key string, field string) (string, error) {
+	v, err := r.client.HGet(ctx, key, field).Result()
+	return v, redisError(err)
+}
+
+func (r *Redis) HSet(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	n, err := r.client.HSet(ctx, key, fields).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	v, err := r.client.HGetAll(ctx, key).Result()
+	return v, redisError(err)
+}
+
+// Exec runs ops in a pipeline, in a MULTI/EXEC transaction when atomic is set, a cluster runs one
+// transaction by hash slot so only the ops of keys of the same slot are atomic together
+func (r *Redis) Exec(ctx context.Context, ops []Op, atomic bool) ([]Result, error) {
+	pipe := r.client.Pipeline()
+	if atomic {
+		pipe = r.client.TxPipeline()
+	}
+	cmds := make([][]redis.Cmder, len(ops))
+	for i, op := range ops {
+		switch op.Kind {
+		case OpGet:
+			cmds[i] = []redis.Cmder{pipe.Get(ctx, op.Key), pipe.PTTL(ctx, op.Key)}
+		case OpSet:
+			cmds[i] = []redis.Cmder{pipe.Set(ctx, op.Key, op.Value, op.TTL)}
+		case OpDelete:
+			cmds[i] = []redis.Cmder{pipe.Del(ctx, op.Key)}
+		case OpIncrBy:
+			cmds[i] = []redis.Cmder{pipe.IncrBy(ctx, op.Key, op.By)}
+		case OpHGet:
+			cmds[i] = []redis.Cmder{pipe.HGet(ctx, op.Key, op.Field)}
+		case OpHSet:
+			if len(op.Fields) > 0 {
+				cmds[i] = []redis.Cmder{pipe.HSet(ctx, op.Key, op.Fields)}
+			}
+		case OpHGetAll:
+			cmds[i] = []redis.Cmder{pipe.HGetAll(ctx, op.Key)}
+		}
+	}
+	// the error of the first failed command, only a connection error fails the whole pipeline
+	if _, err := pipe.Exec(ctx); err != nil && !isReply(err) {
+		return nil, err
+	}
+
+	results := make([]Result, len(ops))
+	for i, op := range ops {
+		res := &results[i]
+		if len(cmds[i]) == 0 {
+			if op.Kind != OpHSet {
+				res.Err = ErrUnknownOp
+			}
+			continue
+		}
+		if res.Err = redisError(cmds[i][0].Err()); res.Err != nil {
+			continue
+		}
+		switch cmd := cmds[i][0].(type) {
+		case *redis.StringCmd:
+			res.Value = cmd.Val()
+		case *redis.IntCmd:
+			res.Int = cmd.Val()
+		case *redis.MapStringStringCmd:
+			res.Fields = cmd.Val()
+		}
+		if op.Kind == OpGet {
+			res.TTL = cmds[i][1].(*redis.DurationCmd).Val()
+			if res.TTL < 0 {
+				res.TTL = NoExpiry
+			}
+		}
+	}
+	return results, nil
+}
+
+// isReply reports whether err is a reply of redis rather than a connection error
+func isReply(err error) bool {
+	var reply redis.Error
+	return errors.Is(err, redis.Nil) || errors.As(err, &reply)
+}
+
+// Size returns the MEMORY USAGE of key
+func (r *Redis) Size(ctx context.Context, key string) (int64, error) {
+	n, err := r.client.MemoryUsage(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, redisError(err)
+}
+
+// Acquire keeps the lease in the hash lock:{name} and the fencing token in lock:{name}:fence, the hash tag
+// keeps both keys in the same cluster slot, the lock ns:name is kept in the keys of ns, see lockKey
+func (r *Redis) Acquire(ctx context.Context, name string, owner string, ttl time.Duration) (int64, error) {
+	key := lockKey(name)
+	token, err := acquireScript.Run(ctx, r.client, []string{key, key + ":fence"}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, redisError(err)
+	}
+	if token < 0 {
+		return 0, ErrLocked
+	}
+	return token, nil
+}
+
+func (r *Redis) Renew(ctx context.Context, name string, owner string, ttl time.Duration) error {
+	ok, err := renewScript.Run(ctx, r.client, []string{lockKey(name)}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return redisError(err)
+	}
+	if ok == 0 {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+func (r *Redis) Release(ctx context.Context, name string, owner string) error {
+	ok, err := releaseScript.Run(ctx, r.client, []string{lockKey(name)}, owner).Int64()
+	if err != nil {
+		return redisError(err)
+	}
+	if ok == 0 {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+func (r *Redis) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	if len(values) == 0 {
+		return r.client.LLen(ctx, key).Result()
+	}
+	n, err := r.client.LPush(ctx, key, toArgs(values)...).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	if len(values) == 0 {
+		return r.client.LLen(ctx, key).Result()
+	}
+	n, err := r.client.RPush(ctx, key, toArgs(values)...).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) LPop(ctx context.Context, key string) (string, error) {
+	v, err := r.client.LPop(ctx, key).Result()
+	return v, redisError(err)
+}
+
+func (r *Redis) RPop(ctx context.Context, key string) (string, error) {
+	v, err := r.client.RPop(ctx, key).Result()
+	return v, redisError(err)
+}
+
+func (r *Redis) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return r.bpop(ctx, true, timeout, keys)
+}
+
+func (r *Redis) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return r.bpop(ctx, false, timeout, keys)
+}
+
+// bpop pops without blocking for a timeout of 0, redis blocks forever for it
+func (r *Redis) bpop(ctx context.Context, left bool, timeout time.Duration, keys []string) (string, string, error) {
+	if timeout <= 0 {
+		for _, key := range keys {
+			pop := r.RPop
+			if left {
+				pop = r.LPop
+			}
+			v, err := pop(ctx, key)
+			if err == ErrNotFound {
+				continue
+			}
+			return key, v, err
+		}
+		return "", "", ErrNotFound
+	}
+	bpop := r.client.BRPop
+	if left {
+		bpop = r.client.BLPop
+	}
+	kv, err := bpop(ctx, timeout, keys...).Result()
+	if err != nil {
+		return "", "", redisError(err)
+	}
+	return kv[0], kv[1], nil
+}
+
+func (r *Redis) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	values, err := r.client.LRange(ctx, key, start, stop).Result()
+	return values, redisError(err)
+}
+
+func (r *Redis) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := r.client.SAdd(ctx, key, toArgs(members)...).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := r.client.SRem(ctx, key, toArgs(members)...).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := r.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, redisError(err)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (r *Redis) SIsMember(ctx context.Context, key string, member string) (bool, error) {
+	ok, err := r.client.SIsMember(ctx, key, member).Result()
+	return ok, redisError(err)
+}
+
+func (r *Redis) ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	zs := make([]redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = redis.Z{Member: m.Member, Score: m.Score}
+	}
+	n, err := r.client.ZAdd(ctx, key, zs...).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) ZRangeByScore(ctx context.Context, key string, min float64, max float64, offset int64, count int64) ([]ZMember, error) {
+	by := &redis.ZRangeBy{Min: score(min), Max: score(max), Offset: offset, Count: count}
+	if count <= 0 {
+		// a limit needs a count, a negative one returns every member
+		by.Count = -1
+		if offset == 0 {
+			by.Count = 0
+		}
+	}
+	zs, err := r.client.ZRangeByScoreWithScores(ctx, key, by).Result()
+	if err != nil {
+		return nil, redisError(err)
+	}
+	members := make([]ZMember, len(zs))
+	for i, z := range zs {
+		members[i] = ZMember{Member: fmt.Sprint(z.Member), Score: z.Score}
+	}
+	return members, nil
+}
+
+// score formats a score bound of a sorted set range
+func score(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (r *Redis) ZRank(ctx context.Context, key string, member string) (int64, error) {
+	n, err := r.client.ZRank(ctx, key, member).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) ZRevRank(ctx context.Context, key string, member string) (int64, error) {
+	n, err := r.client.ZRevRank(ctx, key, member).Result()
+	return n, redisError(err)
+}
+
+func (r *Redis) XAdd(ctx context.Context, key string, id string, fields map[string]string, maxLen int64) (string, error) {
+	if id == "" {
+		id = "*"
+	}
+	v, err := r.client.XAdd(ctx, &redis.XAddArgs{Stream: key, ID: id, Values: fields, MaxLen: maxLen}).Result()
+	return v, redisError(err)
+}
+
+func (r *Redis) XRead(ctx context.Context, key string, after string, count int64, block time.Duration) ([]StreamEntry, error) {
+	if after == "" {
+		after = "0"
+	}
+	streams, err := r.client.XRead(ctx, &redis.XReadArgs{Streams: []string{key, after}, Count: count, Block: blocking(block)}).Result()
+	return streamEntries(streams, err)
+}
+
+func (r *Redis) XGroupCreate(ctx context.Context, key string, group string, start string) error {
+	return redisError(r.client.XGroupCreateMkStream(ctx, key, group, start).Err())
+}
+
+func (r *Redis) XReadGroup(ctx context.Context, key string, group string, consumer string, count int64, block time.Duration) ([]StreamEntry, error) {
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{key, ">"},
+		Count:    count,
+		Block:    blocking(block),
+	}).Result()
+	return streamEntries(streams, err)
+}
+
+func (r *Redis) XAck(ctx context.Context, key string, group string, ids ...string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	n, err := r.client.XAck(ctx, key, group, ids...).Result()
+	return n, redisError(err)
+}
+
+// blocking returns the block argument of a read, a negative one does not block while 0 blocks forever
+func blocking(block time.Duration) time.Duration {
+	if block <= 0 {
+		return -1
+	}
+	return block
+}
+
+// streamEntries returns the entries of a stream read, no entry when the read timed out
+func streamEntries(streams []redis.XStream, err error) ([]StreamEntry, error) {
+	if errors.Is(err, redis.Nil) {
+		return []StreamEntry{}, nil
+	}
+	if err != nil {
+		return nil, redisError(err)
+	}
+	entries := []StreamEntry{}
+	for _, s := range streams {
+		for _, m := range s.Messages {
+			fields := make(map[string]string, len(m.Values))
+			for f, v := range m.Values {
+				fields[f] = fmt.Sprint(v)
+			}
+			entries = append(entries, StreamEntry{ID: m.ID, Fields: fields})
+		}
+	}
+	return entries, nil
+}
+
+// toArgs converts values to command arguments
+func toArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+func (r *Redis) Publish(ctx context.Context, channel string, payload string) (int64, error) {
+	return r.client.Publish(ctx, channel, payload).Result()
+}
+
+// Subscribe holds a connection of the redis until ctx is done, in a cluster the messages published on
+// any node reach it
+func (r *Redis) Subscribe(ctx context.Context, channels []string, patterns []string) (<-chan Message, error) {
+	ps := r.client.Subscribe(ctx)
+	if len(channels) > 0 {
+		if err := ps.Subscribe(ctx, channels...); err != nil {
+			_ = ps.Close()
+			return nil, err
+		}
+	}
+	if len(patterns) > 0 {
+		if err := ps.PSubscribe(ctx, patterns...); err != nil {
+			_ = ps.Close()
+			return nil, err
+		}
+	}
+	// the first confirmation tells the subscription is active
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+	messages := make(chan Message, SubscriptionBuffer)
+	go func() {
+		defer close(messages)
+		defer ps.Close()
+		ch := ps.Channel(redis.WithChannelSize(SubscriptionBuffer))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- Message{Channel: m.Channel, Pattern: m.Pattern, Payload: m.Payload}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// psubscriber is a client of a redis node
+type psubscriber interface {
+	PSubscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// Watch subscribes to the keyspace notifications of every master, ErrNoNotifications when a master does not
+// publish them, the key events of a cluster are only published on the node of the key
+func (r *Redis) Watch(ctx context.Context, pattern string) (<-chan Event, error) {
+	nodes, err := r.masters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db := 0
+	if c, ok := r.client.(*redis.Client); ok {
+		db = c.Options().DB
+	}
+	channel := fmt.Sprintf("__keyspace@%d__:%s", db, pattern)
+	var subs []*redis.PubSub
+	closeAll := func() {
+		for _, ps := range subs {
+			_ = ps.Close()
+		}
+	}
+	for _, node := range nodes {
+		if err := notifications(ctx, node); err != nil {
+			closeAll()
+			return nil, err
+		}
+		ps := node.(psubscriber).PSubscribe(ctx, channel)
+		if _, err := ps.Receive(ctx); err != nil {
+			_ = ps.Close()
+			closeAll()
+			return nil, err
+		}
+		subs = append(subs, ps)
+	}
+
+	events := make(chan Event, SubscriptionBuffer)
+	var wg sync.WaitGroup
+	for _, ps := range subs {
+		wg.Add(1)
+		go func(ps *redis.PubSub) {
+			defer wg.Done()
+			defer ps.Close()
+			ch := ps.Channel(redis.WithChannelSize(SubscriptionBuffer))
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case m, ok := <-ch:
+					if !ok {
+						return
+					}
+					_, key, _ := strings.Cut(m.Channel, "__:")
+					select {
+					case events <- Event{Key: key, Op: m.Payload}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}(ps)
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events, nil
+}
+
+// notifyClasses event classes of notify-keyspace-events the key events need, A stands for all of them
+const notifyClasses = "g$lshzxet"
+
+// notifications checks that the operator enabled the keyspace notifications of every key event on node,
+// with notify-keyspace-events set to KA or more, a node refusing CONFIG GET is expected to be configured
+func notifications(ctx context.Context, node redis.Cmdable) error {
+	config, err := node.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return nil
+	}
+	flags := config["notify-keyspace-events"]
+	if !strings.Contains(flags, "K") {
+		return fmt.Errorf("%w: notify-keyspace-events %q lacks K", ErrNoNotifications, flags)
+	}
+	if strings.Contains(flags, "A") {
+		return nil
+	}
+	for _, c := range notifyClasses {
+		if !strings.ContainsRune(flags, c) {
+			return fmt.Errorf("%w: notify-keyspace-events %q lacks %c, set it to KA", ErrNoNotifications, flags, c)
+		}
+	}
+	return nil
+}
+
+// lockKey returns the key of the lease of the lock name, the lock of a name ns:name is ns:lock:{name}, inside
+// the keys of the namespace ns
+func lockKey(name string) string {
+	ns, name, ok := strings.Cut(name, ":")
+	if !ok {
+		return LockPrefix + ns + "}"
+	}
+	return ns + ":" + LockPrefix + name + "}"
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+func (r *Redis) String() string {
+	return "redis"
+}
+
+// redisError maps the redis replies to the store errors
+func redisError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, redis.Nil):
+		return ErrNotFound
+	case strings.HasPrefix(err.Error(), "WRONGTYPE"):
+		return ErrWrongType
+	case strings.HasPrefix(err.Error(), "ERR value is not an integer"),
+		strings.HasPrefix(err.Error(), "ERR increment or decrement would overflow"):
+		return ErrNotInteger
+	case strings.HasPrefix(err.Error(), "BUSYGROUP"):
+		return ErrGroupExists
+	case strings.HasPrefix(err.Error(), "NOGROUP"):
+		return ErrNoGroup
+	case strings.HasPrefix(err.Error(), "ERR The ID specified in XADD"),
+		strings.HasPrefix(err.Error(), "ERR Invalid stream ID"):
+		return ErrStreamID
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"testing"
+)
+
+// TestRedis runs against the redis of REDIS_ADDR, its keys are prefixed and removed afterwards
+func TestRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{addr}})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatal(err)
+	}
+	s := NewRedis(client)
+	defer s.Close()
+	// the operator enables the keyspace notifications
+	ctx := context.Background()
+	if err := client.ConfigSet(ctx, "notify-keyspace-events", "").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Watch(ctx, "*"); !errors.Is(err, ErrNoNotifications) {
+		t.Fatalf("Watch() without notifications error = %v, want %v", err, ErrNoNotifications)
+	}
+	if err := client.ConfigSet(ctx, "notify-keyspace-events", "KA").Err(); err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	testExec(t, s)
+	testLocker(t, s)
+	testExpiry(t, s)
+	testPubSub(t, s)
+	testTypes(t, s)
+}
+
+func TestLockKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"orders:job": "orders:lock:{job}",
+		"orders:a:b": "orders:lock:{a:b}",
+		"unscoped":   "lock:{unscoped}",
+	} {
+		if got := lockKey(name); got != want {
+			t.Errorf("lockKey(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Redlock leases locks on a majority of independent lockers, like the Redlock algorithm over several redis
+// masters, a lock is held while its lease is valid on a majority of them, the fencing token is the highest
+// token of the majority so it only grows while a majority of the lockers keep their counters
+type Redlock struct {
+	lockers []Locker
+}
+
+func NewRedlock(lockers ...Locker) *Redlock {
+	return &Redlock{lockers: lockers}
+}
+
+func (r *Redlock) quorum() int {
+	return len(r.lockers)/2 + 1
+}
+
+// each runs fn on every locker in parallel
+func (r *Redlock) each(fn func(i int, l Locker) (int64, error)) ([]int64, []error) {
+	tokens := make([]int64, len(r.lockers))
+	errs := make([]error, len(r.lockers))
+	var wg sync.WaitGroup
+	for i, l := range r.lockers {
+		wg.Add(1)
+		go func(i int, l Locker) {
+			defer wg.Done()
+			tokens[i], errs[i] = fn(i, l)
+		}(i, l)
+	}
+	wg.Wait()
+	return tokens, errs
+}
+
+// Acquire succeeds when a majority of the lockers lease the lock before the lease expires, minus the clock
+// drift, otherwise the leases of this attempt are released, the lockers where owner already held the lock
+// keep it
+func (r *Redlock) Acquire(ctx context.Context, name string, owner string, ttl time.Duration) (int64, error) {
+	start := time.Now()
+	held := make([]bool, len(r.lockers))
+	tokens, errs := r.each(func(i int, l Locker) (int64, error) {
+		held[i] = l.Renew(ctx, name, owner, ttl) == nil
+		return l.Acquire(ctx, name, owner, ttl)
+	})
+	var token int64
+	acquired, locked := 0, 0
+	var failure error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			acquired++
+			if tokens[i] > token {
+				token = tokens[i]
+			}
+		case err == ErrLocked:
+			locked++
+		case failure == nil:
+			failure = err
+		}
+	}
+	drift := ttl/100 + 2*time.Millisecond
+	if acquired >= r.quorum() && ttl-time.Since(start)-drift > 0 {
+		return token, nil
+	}
+	r.each(func(i int, l Locker) (int64, error) {
+		if errs[i] != nil || held[i] {
+			return 0, nil
+		}
+		return 0, l.Release(ctx, name, owner)
+	})
+	if locked > 0 || failure == nil {
+		return 0, ErrLocked
+	}
+	return 0, failure
+}
+
+// Renew succeeds when a majority of the lockers extend the lease
+func (r *Redlock) Renew(ctx context.Context, name string, owner string, ttl time.Duration) error {
+	_, errs := r.each(func(_ int, l Locker) (int64, error) {
+		return 0, l.Renew(ctx, name, owner, ttl)
+	})
+	renewed := 0
+	for _, err := range errs {
+		if err == nil {
+			renewed++
+		}
+	}
+	if renewed < r.quorum() {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// Release frees the lock on every locker, it fails when no locker held it
+func (r *Redlock) Release(ctx context.Context, name string, owner string) error {
+	_, errs := r.each(func(_ int, l Locker) (int64, error) {
+		return 0, l.Release(ctx, name, owner)
+	})
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return ErrNotOwner
+}
+
+// Close closes the lockers that are stores
+func (r *Redlock) Close() error {
+	var first error
+	for _, l := range r.lockers {
+		if s, ok := l.(Store); ok {
+			if err := s.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// WithLocker returns s with the locks of l, l is closed with s when it is a Redlock
+func WithLocker(s Store, l Locker) Store {
+	return &lockedStore{Store: s, locker: l}
+}
+
+type lockedStore struct {
+	Store
+	locker Locker
+}
+
+func (s *lockedStore) Acquire(ctx context.Context, name string, owner string, ttl time.Duration) (int64, error) {
+	return s.locker.Acquire(ctx, name, owner, ttl)
+}
+
+func (s *lockedStore) Renew(ctx context.Context, name string, owner string, ttl time.Duration) error {
+	return s.locker.Renew(ctx, name, owner, ttl)
+}
+
+func (s *lockedStore) Release(ctx context.Context, name string, owner string) error {
+	return s.locker.Release(ctx, name, owner)
+}
+
+func (s *lockedStore) Close() error {
+	if r, ok := s.locker.(*Redlock); ok {
+		_ = r.Close()
+	}
+	return s.Store.Close()
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRedlock(t *testing.T) {
+	nodes := []*Memory{NewMemory(0, 0), NewMemory(0, 0), NewMemory(0, 0)}
+	r := NewRedlock(nodes[0], nodes[1], nodes[2])
+	defer r.Close()
+	testLocker(t, r)
+
+	// a majority of the nodes is enough
+	ctx := context.Background()
+	if _, err := nodes[0].Acquire(ctx, "quorum", "other", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Acquire(ctx, "quorum", "owner", time.Minute); err != nil {
+		t.Fatalf("Acquire() with a majority error = %v", err)
+	}
+	if err := r.Release(ctx, "quorum", "owner"); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a majority the lock is released everywhere
+	if _, err := nodes[1].Acquire(ctx, "quorum", "other", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Acquire(ctx, "quorum", "owner", time.Minute); err != ErrLocked {
+		t.Fatalf("Acquire() without a majority error = %v, want %v", err, ErrLocked)
+	}
+	if _, err := nodes[2].Acquire(ctx, "quorum", "third", time.Minute); err != nil {
+		t.Fatalf("Acquire() after a failed redlock error = %v, the lock was not released", err)
+	}
+
+	// a failed attempt keeps the leases owner held before it
+	if _, err := nodes[0].Acquire(ctx, "held", "owner", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes[1:] {
+		if _, err := node.Acquire(ctx, "held", "other", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Acquire(ctx, "held", "owner", time.Minute); err != ErrLocked {
+		t.Fatalf("Acquire() without a majority error = %v, want %v", err, ErrLocked)
+	}
+	if err := nodes[0].Renew(ctx, "held", "owner", time.Minute); err != nil {
+		t.Fatalf("Renew() of the lease held before the attempt error = %v", err)
+	}
+}
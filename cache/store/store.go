@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// NoExpiry time to live of a key without expiration
+	NoExpiry time.Duration = -1
+	// Missing time to live of a key that does not exist
+	Missing time.Duration = -2
+	// LockPrefix starts the keys of the locks kept with the keys, the lock ns:name of the redis store is kept
+	// in ns:lock:{name} and its fencing token in ns:lock:{name}:fence
+	LockPrefix = "lock:{"
+)
+
+var (
+	ErrNotFound   = errors.New("key not found")
+	ErrWrongType  = errors.New("operation against a key holding the wrong kind of value")
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+	// ErrInvalidCursor a cursor that was not returned by Scan
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrUnknownOp     = errors.New("unknown operation")
+	ErrLocked        = errors.New("lock is held by another owner")
+	ErrNotOwner      = errors.New("lock is not held by the owner")
+	// ErrNoNotifications the redis keyspace notifications of the key events are not enabled
+	ErrNoNotifications = errors.New("keyspace notifications are disabled")
+)
+
+// Locker leases named locks to owners, every acquisition of a lock gets a fencing token greater than the
+// tokens of the previous acquisitions, so a resource can reject the writes of an owner whose lease expired
+type Locker interface {
+	// Acquire leases the lock name to owner for ttl and returns its fencing token, ErrLocked when another
+	// owner holds it, an owner acquiring a lock it holds extends its lease and keeps its token
+	Acquire(ctx context.Context, name string, owner string, ttl time.Duration) (int64, error)
+	// Renew extends the lease of owner to ttl, ErrNotOwner when owner does not hold the lock
+	Renew(ctx context.Context, name string, owner string, ttl time.Duration) error
+	// Release frees the lock held by owner, ErrNotOwner when owner does not hold the lock
+	Release(ctx context.Context, name string, owner string) error
+}
+
+// SetMode condition of a conditional set
+type SetMode string
+
+const (
+	// SetAlways sets the key whether it exists or not
+	SetAlways SetMode = ""
+	// SetNX only sets a key that does not exist
+	SetNX SetMode = "nx"
+	// SetXX only sets a key that exists
+	SetXX SetMode = "xx"
+)
+
+// SetArgs options of a conditional set, like the arguments of the redis SET
+type SetArgs struct {
+	Mode SetMode
+	// TTL of the key, 0 never expires
+	TTL time.Duration
+	// KeepTTL keeps the time to live of an existing key instead of TTL
+	KeepTTL bool
+	// Get returns the previous value of the key, like GETSET
+	Get bool
+}
+
+// skip reports whether the condition of a set fails for a key that exists or not
+func (a SetArgs) skip(exists bool) bool {
+	return (a.Mode == SetNX && exists) || (a.Mode == SetXX && !exists)
+}
+
+// SetResult of a conditional set, Old and Existed are only reported when the previous value was asked
+type SetResult struct {
+	// Set is false when the condition failed
+	Set     bool
+	Old     string
+	Existed bool
+}
+
+// Expiry manages the time to live of keys of any kind
+type Expiry interface {
+	// Expire sets the time to live of key, it reports whether key exists, a ttl of 0 or less deletes key
+	Expire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Persist removes the time to live of key, it reports whether key existed with a time to live
+	Persist(ctx context.Context, key string) (bool, error)
+	// TTL returns the time to live of key, NoExpiry when it does not expire, Missing when it does not exist
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// SetArgs replaces key by a string value when the condition of args holds, ErrWrongType when the
+	// previous value is asked from a key that is not a string
+	SetArgs(ctx context.Context, key string, value string, args SetArgs) (SetResult, error)
+}
+
+type OpKind string
+
+const (
+	OpGet     OpKind = "get"
+	OpSet     OpKind = "set"
+	OpDelete  OpKind = "delete"
+	OpIncrBy  OpKind = "incrby"
+	OpHGet    OpKind = "hget"
+	OpHSet    OpKind = "hset"
+	OpHGetAll OpKind = "hgetall"
+)
+
+// Op operation of an Exec, with the arguments of the Store method of its kind
+type Op struct {
+	Kind   OpKind
+	Key    string
+	Field  string
+	Value  string
+	Fields map[string]string
+	TTL    time.Duration
+	By     int64
+}
+
+// Writes reports whether the operation changes the store
+func (o Op) Writes() bool {
+	switch o.Kind {
+	case OpSet, OpDelete, OpIncrBy, OpHSet:
+		return true
+	}
+	return false
+}
+
+// Result of an Op, Int is the value of an incrby, the deleted keys of a delete or the added fields of a hset
+type Result struct {
+	Value  string
+	Fields map[string]string
+	Int    int64
+	TTL    time.Duration
+	Err    error
+}
+
+// Store keeps the cache keys, a key holds a string value, a hash of string fields, a list, a set, a sorted set
+// or a stream
+type Store interface {
+	// Get returns the value of key and its time to live, NoExpiry when it does not expire,
+	// ErrNotFound when key does not exist
+	Get(ctx context.Context, key string) (string, time.Duration, error)
+	// Set replaces key by value, a ttl of 0 never expires
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	// Delete removes keys and returns how many of them existed
+	Delete(ctx context.Context, keys ...string) (int64, error)
+	// IncrBy adds n to the integer value of key, a missing key counts as 0, the time to live is kept
+	IncrBy(ctx context.Context, key string, n int64) (int64, error)
+	// Scan returns up to about count keys matching the glob pattern from cursor, "" starts a scan, the next
+	// cursor is "" once every key was returned, keys added or removed during a scan may be missed
+	Scan(ctx context.Context, cursor string, pattern string, count int64) ([]string, string, error)
+	// HGet returns the value of field in the hash at key, ErrNotFound when one of them does not exist
+	HGet(ctx context.Context, key string, field string) (string, error)
+	// HSet sets fields in the hash at key and returns how many of them were added, no fields is a no-op
+	HSet(ctx context.Context, key string, fields map[string]string) (int64, error)
+	// HGetAll returns the fields of the hash at key, empty when key does not exist
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	// Exec runs ops in one round trip and returns the result of every op, atomic runs them with no other
+	// command in between, like MULTI/EXEC an op that fails does not undo the others, the error is only set
+	// when ops could not run at all
+	Exec(ctx context.Context, ops []Op, atomic bool) ([]Result, error)
+	// Size returns an estimate of the bytes used by key, 0 when it does not exist
+	Size(ctx context.Context, key string) (int64, error)
+	Lists
+	Sets
+	SortedSets
+	Streams
+	Expiry
+	Locker
+	PubSub
+	// Ping checks that the store is reachable, the local stores always are
+	Ping(ctx context.Context) error
+	Close() error
+	String() string
+}
+
+// remaining returns the time to live of a key of the local stores expiring at expires, never below 0 so it
+// cannot be mistaken for NoExpiry or Missing
+func remaining(expires time.Time) time.Duration {
+	if d := time.Until(expires); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// size of a key of the local stores, its name and its value or the names and values of its fields
+func size(key string, value string, hash map[string]string) int64 {
+	n := len(key) + len(value)
+	for f, v := range hash {
+		n += len(f) + len(v)
+	}
+	return int64(n)
+}
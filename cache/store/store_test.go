@@ -0,0 +1,501 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour every store shares, keys are prefixed so a shared redis can run it
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	p := fmt.Sprintf("test:%d:", time.Now().UnixNano())
+	defer s.Delete(ctx, p+"name", p+"ttl", p+"counter", p+"hash", p+"deleted", p+"batch")
+
+	if _, _, err := s.Get(ctx, p+"name"); err != ErrNotFound {
+		t.Fatalf("Get() missing error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Set(ctx, p+"name", "micro", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, ttl, err := s.Get(ctx, p+"name"); err != nil || v != "micro" || ttl != NoExpiry {
+		t.Fatalf("Get() = %q, %v, %v, want micro, %v", v, ttl, err, NoExpiry)
+	}
+	if err := s.Set(ctx, p+"ttl", "short", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, ttl, err := s.Get(ctx, p+"ttl"); err != nil || ttl <= 58*time.Second || ttl > time.Minute {
+		t.Fatalf("Get() ttl = %v, %v, want about a minute", ttl, err)
+	}
+
+	if v, err := s.IncrBy(ctx, p+"counter", 5); err != nil || v != 5 {
+		t.Fatalf("IncrBy() = %d, %v, want 5", v, err)
+	}
+	if v, err := s.IncrBy(ctx, p+"counter", -7); err != nil || v != -2 {
+		t.Fatalf("IncrBy() = %d, %v, want -2", v, err)
+	}
+	if _, err := s.IncrBy(ctx, p+"name", 1); err != ErrNotInteger {
+		t.Fatalf("IncrBy() not integer error = %v, want %v", err, ErrNotInteger)
+	}
+
+	if n, err := s.HSet(ctx, p+"hash", map[string]string{"a": "1", "b": "2"}); err != nil || n != 2 {
+		t.Fatalf("HSet() = %d, %v, want 2", n, err)
+	}
+	if n, err := s.HSet(ctx, p+"hash", map[string]string{"b": "3", "c": "4"}); err != nil || n != 1 {
+		t.Fatalf("HSet() = %d, %v, want 1", n, err)
+	}
+	if v, err := s.HGet(ctx, p+"hash", "b"); err != nil || v != "3" {
+		t.Fatalf("HGet() = %q, %v, want 3", v, err)
+	}
+	if _, err := s.HGet(ctx, p+"hash", "z"); err != ErrNotFound {
+		t.Fatalf("HGet() missing field error = %v, want %v", err, ErrNotFound)
+	}
+	all, err := s.HGetAll(ctx, p+"hash")
+	if err != nil || len(all) != 3 || all["a"] != "1" || all["c"] != "4" {
+		t.Fatalf("HGetAll() = %v, %v", all, err)
+	}
+	if all, err := s.HGetAll(ctx, p+"missing"); err != nil || len(all) != 0 {
+		t.Fatalf("HGetAll() missing = %v, %v, want empty", all, err)
+	}
+	if _, _, err := s.Get(ctx, p+"hash"); err != ErrWrongType {
+		t.Fatalf("Get() hash error = %v, want %v", err, ErrWrongType)
+	}
+	if _, err := s.HGet(ctx, p+"name", "a"); err != ErrWrongType {
+		t.Fatalf("HGet() string error = %v, want %v", err, ErrWrongType)
+	}
+
+	if n, err := s.Size(ctx, p+"name"); err != nil || n < int64(len("micro")) {
+		t.Fatalf("Size() = %d, %v, want at least the value length", n, err)
+	}
+	if n, err := s.Size(ctx, p+"missing"); err != nil || n != 0 {
+		t.Fatalf("Size() missing = %d, %v, want 0", n, err)
+	}
+
+	keys := scanAll(t, s, p+"*", 2)
+	if len(keys) != 4 || keys[0] != p+"counter" || keys[1] != p+"hash" || keys[2] != p+"name" || keys[3] != p+"ttl" {
+		t.Fatalf("Scan() = %v, want the 4 keys", keys)
+	}
+	if keys := scanAll(t, s, p+"[nt]*", 1); len(keys) != 2 || keys[0] != p+"name" || keys[1] != p+"ttl" {
+		t.Fatalf("Scan() = %v, want name and ttl", keys)
+	}
+	if _, _, err := s.Scan(ctx, "not a cursor", "*", 10); err != ErrInvalidCursor {
+		t.Fatalf("Scan() error = %v, want %v", err, ErrInvalidCursor)
+	}
+
+	if err := s.Set(ctx, p+"deleted", "x", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Delete(ctx, p+"deleted", p+"missing"); err != nil || n != 1 {
+		t.Fatalf("Delete() = %d, %v, want 1", n, err)
+	}
+	if _, _, err := s.Get(ctx, p+"deleted"); err != ErrNotFound {
+		t.Fatalf("Get() deleted error = %v, want %v", err, ErrNotFound)
+	}
+
+	// a set replaces a hash and drops the time to live
+	if err := s.Set(ctx, p+"hash", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, p+"ttl", "long", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, ttl, err := s.Get(ctx, p+"ttl"); err != nil || v != "long" || ttl != NoExpiry {
+		t.Fatalf("Get() = %q, %v, %v, want long, %v", v, ttl, err, NoExpiry)
+	}
+}
+
+// testExec checks every op of an exec has its own result, for atomic and not atomic execs
+func testExec(t *testing.T, s Store) {
+	ctx := context.Background()
+	p := fmt.Sprintf("test:%d:", time.Now().UnixNano())
+	defer s.Delete(ctx, p+"a", p+"b", p+"h")
+	for _, atomic := range []bool{false, true} {
+		results, err := s.Exec(ctx, []Op{
+			{Kind: OpSet, Key: p + "a", Value: "1", TTL: time.Minute},
+			{Kind: OpIncrBy, Key: p + "a", By: 2},
+			{Kind: OpGet, Key: p + "a"},
+			{Kind: OpGet, Key: p + "missing"},
+			{Kind: OpHSet, Key: p + "h", Fields: map[string]string{"f": "v"}},
+			{Kind: OpHGet, Key: p + "h", Field: "f"},
+			{Kind: OpHGetAll, Key: p + "h"},
+			{Kind: OpIncrBy, Key: p + "h", By: 1},
+			{Kind: OpSet, Key: p + "b", Value: "x"},
+			{Kind: OpDelete, Key: p + "b"},
+			{Kind: OpDelete, Key: p + "h"},
+		}, atomic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 11 {
+			t.Fatalf("Exec() = %d results, want 11", len(results))
+		}
+		if r := results[1]; r.Err != nil || r.Int != 3 {
+			t.Fatalf("Exec() incrby = %+v, want 3", r)
+		}
+		if r := results[2]; r.Err != nil || r.Value != "3" || r.TTL <= 58*time.Second {
+			t.Fatalf("Exec() get = %+v, want 3 expiring in a minute", r)
+		}
+		if r := results[3]; r.Err != ErrNotFound {
+			t.Fatalf("Exec() get missing = %+v, want %v", r, ErrNotFound)
+		}
+		if r := results[4]; r.Err != nil || r.Int != 1 {
+			t.Fatalf("Exec() hset = %+v, want 1 added", r)
+		}
+		if r := results[5]; r.Err != nil || r.Value != "v" {
+			t.Fatalf("Exec() hget = %+v, want v", r)
+		}
+		if r := results[6]; r.Err != nil || len(r.Fields) != 1 || r.Fields["f"] != "v" {
+			t.Fatalf("Exec() hgetall = %+v", r)
+		}
+		// a failed op does not stop the next ones
+		if r := results[7]; r.Err != ErrWrongType {
+			t.Fatalf("Exec() incrby hash = %+v, want %v", r, ErrWrongType)
+		}
+		if r := results[9]; r.Err != nil || r.Int != 1 {
+			t.Fatalf("Exec() delete = %+v, want 1", r)
+		}
+		if r := results[10]; r.Err != nil || r.Int != 1 {
+			t.Fatalf("Exec() delete hash = %+v, want 1", r)
+		}
+	}
+}
+
+// testExpiry checks keys expire and their time to live is managed, it sleeps past the expiration
+func testExpiry(t *testing.T, s Store) {
+	ctx := context.Background()
+	p := fmt.Sprintf("test:%d:", time.Now().UnixNano())
+	if err := s.Set(ctx, p+"expiring", "x", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.IncrBy(ctx, p+"expiring", 0); err != ErrNotInteger {
+		t.Fatalf("IncrBy() error = %v, want %v", err, ErrNotInteger)
+	}
+	if ttl, err := s.TTL(ctx, p+"expiring"); err != nil || ttl <= 0 || ttl > 50*time.Millisecond {
+		t.Fatalf("TTL() = %v, %v, want at most 50ms", ttl, err)
+	}
+	if ttl, err := s.TTL(ctx, p+"missing"); err != nil || ttl != Missing {
+		t.Fatalf("TTL() missing = %v, %v, want %v", ttl, err, Missing)
+	}
+
+	if err := s.Set(ctx, p+"kept", "x", 0); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Expire(ctx, p+"kept", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("Expire() = %v, %v, want true", ok, err)
+	}
+	if ok, err := s.Persist(ctx, p+"kept"); err != nil || !ok {
+		t.Fatalf("Persist() = %v, %v, want true", ok, err)
+	}
+	if ok, err := s.Persist(ctx, p+"kept"); err != nil || ok {
+		t.Fatalf("Persist() without ttl = %v, %v, want false", ok, err)
+	}
+	if ttl, err := s.TTL(ctx, p+"kept"); err != nil || ttl != NoExpiry {
+		t.Fatalf("TTL() persisted = %v, %v, want %v", ttl, err, NoExpiry)
+	}
+	if ok, err := s.Expire(ctx, p+"missing", time.Minute); err != nil || ok {
+		t.Fatalf("Expire() missing = %v, %v, want false", ok, err)
+	}
+	if _, err := s.SAdd(ctx, p+"set", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Expire(ctx, p+"set", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("Expire() set = %v, %v, want true", ok, err)
+	}
+
+	if res, err := s.SetArgs(ctx, p+"kept", "y", SetArgs{Mode: SetNX}); err != nil || res.Set {
+		t.Fatalf("SetArgs() nx existing = %+v, %v, want not set", res, err)
+	}
+	if res, err := s.SetArgs(ctx, p+"new", "y", SetArgs{Mode: SetXX}); err != nil || res.Set {
+		t.Fatalf("SetArgs() xx missing = %+v, %v, want not set", res, err)
+	}
+	res, err := s.SetArgs(ctx, p+"kept", "", SetArgs{Mode: SetXX, TTL: 50 * time.Millisecond, Get: true})
+	if err != nil || !res.Set || !res.Existed || res.Old != "x" {
+		t.Fatalf("SetArgs() xx get = %+v, %v, want set, old x", res, err)
+	}
+	if v, ttl, err := s.Get(ctx, p+"kept"); err != nil || v != "" || ttl <= 0 || ttl > 50*time.Millisecond {
+		t.Fatalf("Get() empty value = %q, %v, %v, want an empty value expiring", v, ttl, err)
+	}
+	if res, err := s.SetArgs(ctx, p+"kept", "z", SetArgs{KeepTTL: true}); err != nil || !res.Set {
+		t.Fatalf("SetArgs() keep ttl = %+v, %v, want set", res, err)
+	}
+	if res, err := s.SetArgs(ctx, p+"new", "y", SetArgs{Mode: SetNX, TTL: 50 * time.Millisecond}); err != nil || !res.Set {
+		t.Fatalf("SetArgs() nx missing = %+v, %v, want set", res, err)
+	}
+	if _, err := s.HSet(ctx, p+"hash", map[string]string{"a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetArgs(ctx, p+"hash", "x", SetArgs{Get: true}); err != ErrWrongType {
+		t.Fatalf("SetArgs() get hash error = %v, want %v", err, ErrWrongType)
+	}
+	if ok, err := s.Expire(ctx, p+"hash", -1); err != nil || !ok {
+		t.Fatalf("Expire() negative = %v, %v, want true", ok, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, _, err := s.Get(ctx, p+"expiring"); err != ErrNotFound {
+		t.Fatalf("Get() expired error = %v, want %v", err, ErrNotFound)
+	}
+	if keys := scanAll(t, s, p+"*", 10); len(keys) != 0 {
+		t.Fatalf("Scan() = %v, has the expired keys", keys)
+	}
+}
+
+// scanAll returns the sorted keys matching pattern, scanned by pages of count
+func scanAll(t *testing.T, s Store, pattern string, count int64) []string {
+	seen := map[string]bool{}
+	var keys []string
+	cursor := ""
+	for i := 0; ; i++ {
+		page, next, err := s.Scan(context.Background(), cursor, pattern, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range page {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		if next == "" {
+			break
+		}
+		if i > 1000 {
+			t.Fatalf("Scan() did not end")
+		}
+		cursor = next
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// testLocker checks the leases and the fencing tokens of a locker
+func testLocker(t *testing.T, l Locker) {
+	ctx := context.Background()
+	name := fmt.Sprintf("test:%d", time.Now().UnixNano())
+
+	first, err := l.Acquire(ctx, name, "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(ctx, name, "b", time.Minute); err != ErrLocked {
+		t.Fatalf("Acquire() held lock error = %v, want %v", err, ErrLocked)
+	}
+	if token, err := l.Acquire(ctx, name, "a", time.Minute); err != nil || token != first {
+		t.Fatalf("Acquire() by the owner = %d, %v, want %d", token, err, first)
+	}
+	if err := l.Renew(ctx, name, "b", time.Minute); err != ErrNotOwner {
+		t.Fatalf("Renew() by another owner error = %v, want %v", err, ErrNotOwner)
+	}
+	if err := l.Renew(ctx, name, "a", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(ctx, name, "b"); err != ErrNotOwner {
+		t.Fatalf("Release() by another owner error = %v, want %v", err, ErrNotOwner)
+	}
+
+	// the lease expires
+	time.Sleep(100 * time.Millisecond)
+	second, err := l.Acquire(ctx, name, "b", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second <= first {
+		t.Fatalf("Acquire() token = %d, want more than %d", second, first)
+	}
+	if err := l.Release(ctx, name, "a"); err != ErrNotOwner {
+		t.Fatalf("Release() expired lease error = %v, want %v", err, ErrNotOwner)
+	}
+	if err := l.Release(ctx, name, "b"); err != nil {
+		t.Fatal(err)
+	}
+	third, err := l.Acquire(ctx, name, "a", time.Minute)
+	if err != nil || third <= second {
+		t.Fatalf("Acquire() after release = %d, %v, want more than %d", third, err, second)
+	}
+	_ = l.Release(ctx, name, "a")
+}
+
+// testPubSub checks the messages of the subscriptions and the events of the watched keys
+func testPubSub(t *testing.T, s Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	prefix := fmt.Sprintf("test:%d:", time.Now().UnixNano())
+	messages, err := s.Subscribe(ctx, []string{prefix + "a"}, []string{prefix + "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := s.Watch(ctx, prefix+"*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.Publish(ctx, prefix+"a", "hello"); err != nil || n != 2 {
+		t.Fatalf("Publish() = %d, %v, want 2", n, err)
+	}
+	patterns := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-messages:
+			if m.Channel != prefix+"a" || m.Payload != "hello" {
+				t.Fatalf("message = %+v", m)
+			}
+			patterns[m.Pattern] = true
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	if !patterns[""] || !patterns[prefix+"*"] {
+		t.Fatalf("message patterns = %v", patterns)
+	}
+
+	if err := s.Set(ctx, prefix+"k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, prefix+"k", prefix+"missing"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []Event{{Key: prefix + "k", Op: EventSet}, {Key: prefix + "k", Op: EventDel}} {
+		select {
+		case e := <-events:
+			if e != want {
+				t.Fatalf("event = %+v, want %+v", e, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %+v not received", want)
+		}
+	}
+
+	// the subscriptions end with their context
+	cancel()
+	for range messages {
+	}
+	for range events {
+	}
+}
+
+// testTypes checks the lists, sets, sorted sets and streams
+func testTypes(t *testing.T, s Store) {
+	ctx := context.Background()
+	p := fmt.Sprintf("test:%d:", time.Now().UnixNano())
+
+	if n, err := s.RPush(ctx, p+"list", "b", "c"); err != nil || n != 2 {
+		t.Fatalf("RPush() = %d, %v, want 2", n, err)
+	}
+	if n, err := s.LPush(ctx, p+"list", "a", "z"); err != nil || n != 4 {
+		t.Fatalf("LPush() = %d, %v, want 4", n, err)
+	}
+	if v, err := s.LRange(ctx, p+"list", 0, -1); err != nil || fmt.Sprint(v) != "[z a b c]" {
+		t.Fatalf("LRange() = %v, %v, want [z a b c]", v, err)
+	}
+	if v, err := s.LRange(ctx, p+"list", -2, 10); err != nil || fmt.Sprint(v) != "[b c]" {
+		t.Fatalf("LRange(-2, 10) = %v, %v, want [b c]", v, err)
+	}
+	if v, err := s.LPop(ctx, p+"list"); err != nil || v != "z" {
+		t.Fatalf("LPop() = %q, %v, want z", v, err)
+	}
+	if v, err := s.RPop(ctx, p+"list"); err != nil || v != "c" {
+		t.Fatalf("RPop() = %q, %v, want c", v, err)
+	}
+	if _, _, err := s.Get(ctx, p+"list"); err != ErrWrongType {
+		t.Fatalf("Get() list error = %v, want %v", err, ErrWrongType)
+	}
+	if k, v, err := s.BLPop(ctx, 0, p+"empty", p+"list"); err != nil || k != p+"list" || v != "a" {
+		t.Fatalf("BLPop() = %q, %q, %v, want a", k, v, err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = s.RPush(ctx, p+"queue", "job")
+	}()
+	if k, v, err := s.BRPop(ctx, time.Second, p+"queue"); err != nil || k != p+"queue" || v != "job" {
+		t.Fatalf("BRPop() waiting = %q, %q, %v, want job", k, v, err)
+	}
+	if _, _, err := s.BRPop(ctx, 50*time.Millisecond, p+"queue"); err != ErrNotFound {
+		t.Fatalf("BRPop() timeout error = %v, want %v", err, ErrNotFound)
+	}
+	// a list is removed with its last value
+	if _, _ = s.RPop(ctx, p+"list"); len(scanAll(t, s, p+"list", 10)) != 0 {
+		t.Fatalf("empty list %s is kept", p+"list")
+	}
+
+	if n, err := s.SAdd(ctx, p+"set", "b", "a", "b"); err != nil || n != 2 {
+		t.Fatalf("SAdd() = %d, %v, want 2", n, err)
+	}
+	if ok, err := s.SIsMember(ctx, p+"set", "a"); err != nil || !ok {
+		t.Fatalf("SIsMember() = %v, %v, want true", ok, err)
+	}
+	if n, err := s.SRem(ctx, p+"set", "a", "c"); err != nil || n != 1 {
+		t.Fatalf("SRem() = %d, %v, want 1", n, err)
+	}
+	if v, err := s.SMembers(ctx, p+"set"); err != nil || fmt.Sprint(v) != "[b]" {
+		t.Fatalf("SMembers() = %v, %v, want [b]", v, err)
+	}
+	if v, err := s.SMembers(ctx, p+"none"); err != nil || len(v) != 0 {
+		t.Fatalf("SMembers() missing = %v, %v, want none", v, err)
+	}
+
+	if n, err := s.ZAdd(ctx, p+"zset", ZMember{"a", 3}, ZMember{"b", 1}, ZMember{"c", 2}); err != nil || n != 3 {
+		t.Fatalf("ZAdd() = %d, %v, want 3", n, err)
+	}
+	if n, err := s.ZAdd(ctx, p+"zset", ZMember{"b", 4}); err != nil || n != 0 {
+		t.Fatalf("ZAdd() update = %d, %v, want 0", n, err)
+	}
+	members, err := s.ZRangeByScore(ctx, p+"zset", 2, math.Inf(1), 0, 2)
+	if err != nil || len(members) != 2 || members[0] != (ZMember{"c", 2}) || members[1] != (ZMember{"a", 3}) {
+		t.Fatalf("ZRangeByScore() = %v, %v, want c a", members, err)
+	}
+	if members, err := s.ZRangeByScore(ctx, p+"zset", math.Inf(-1), math.Inf(1), 1, 0); err != nil || len(members) != 2 {
+		t.Fatalf("ZRangeByScore() offset = %v, %v, want a b", members, err)
+	}
+	if r, err := s.ZRank(ctx, p+"zset", "b"); err != nil || r != 2 {
+		t.Fatalf("ZRank() = %d, %v, want 2", r, err)
+	}
+	if r, err := s.ZRevRank(ctx, p+"zset", "b"); err != nil || r != 0 {
+		t.Fatalf("ZRevRank() = %d, %v, want 0", r, err)
+	}
+	if _, err := s.ZRank(ctx, p+"zset", "x"); err != ErrNotFound {
+		t.Fatalf("ZRank() missing error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := s.XGroupCreate(ctx, p+"stream", "workers", "$"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.XGroupCreate(ctx, p+"stream", "workers", "$"); err != ErrGroupExists {
+		t.Fatalf("XGroupCreate() twice error = %v, want %v", err, ErrGroupExists)
+	}
+	first, err := s.XAdd(ctx, p+"stream", "", map[string]string{"n": "1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.XAdd(ctx, p+"stream", "1-0", map[string]string{"n": "0"}, 0); err != ErrStreamID {
+		t.Fatalf("XAdd() older id error = %v, want %v", err, ErrStreamID)
+	}
+	if _, err := s.XAdd(ctx, p+"stream", "", map[string]string{"n": "2"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.XRead(ctx, p+"stream", first, 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].Fields["n"] != "2" {
+		t.Fatalf("XRead() = %v, %v, want the second entry", entries, err)
+	}
+	entries, err = s.XReadGroup(ctx, p+"stream", "workers", "w1", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].ID != first {
+		t.Fatalf("XReadGroup() = %v, %v, want the first entry", entries, err)
+	}
+	if entries, err = s.XReadGroup(ctx, p+"stream", "workers", "w2", 10, 0); err != nil || len(entries) != 1 {
+		t.Fatalf("XReadGroup() second consumer = %v, %v, want the second entry", entries, err)
+	}
+	if entries, err = s.XReadGroup(ctx, p+"stream", "workers", "w1", 10, 0); err != nil || len(entries) != 0 {
+		t.Fatalf("XReadGroup() = %v, %v, want no entry", entries, err)
+	}
+	if n, err := s.XAck(ctx, p+"stream", "workers", first, first); err != nil || n != 1 {
+		t.Fatalf("XAck() = %d, %v, want 1", n, err)
+	}
+	if _, err := s.XReadGroup(ctx, p+"stream", "other", "w1", 1, 0); err != ErrNoGroup {
+		t.Fatalf("XReadGroup() unknown group error = %v, want %v", err, ErrNoGroup)
+	}
+
+	if _, err := s.Delete(ctx, p+"set", p+"zset", p+"stream"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrStreamID an invalid stream id or one not greater than the last id of the stream
+	ErrStreamID    = errors.New("invalid stream id")
+	ErrGroupExists = errors.New("consumer group already exists")
+	ErrNoGroup     = errors.New("no such consumer group")
+)
+
+// Lists keeps lists of strings, a list is removed with its last element
+type Lists interface {
+	// LPush adds values at the head of the list at key, one after the other, and returns its length
+	LPush(ctx context.Context, key string, values ...string) (int64, error)
+	// RPush adds values at the tail of the list at key and returns its length
+	RPush(ctx context.Context, key string, values ...string) (int64, error)
+	// LPop removes and returns the head of the list at key, ErrNotFound when it does not exist
+	LPop(ctx context.Context, key string) (string, error)
+	// RPop removes and returns the tail of the list at key, ErrNotFound when it does not exist
+	RPop(ctx context.Context, key string) (string, error)
+	// BLPop pops the head of the first list of keys that is not empty and returns its key, it waits up to
+	// timeout for a value, ErrNotFound once it elapses, a timeout of 0 does not wait, the keys of a redis
+	// cluster must share a hash slot
+	BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error)
+	// BRPop pops the tail like BLPop
+	BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error)
+	// LRange returns the elements from start to stop included, negative indexes count from the tail
+	LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error)
+}
+
+// Sets keeps sets of strings, a set is removed with its last member
+type Sets interface {
+	// SAdd adds members to the set at key and returns how many were not members yet
+	SAdd(ctx context.Context, key string, members ...string) (int64, error)
+	// SRem removes members from the set at key and returns how many were members
+	SRem(ctx context.Context, key string, members ...string) (int64, error)
+	// SMembers returns the sorted members of the set at key, empty when it does not exist
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SIsMember(ctx context.Context, key string, member string) (bool, error)
+}
+
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSets keeps sets of members ordered by score then by member
+type SortedSets interface {
+	// ZAdd adds members to the sorted set at key or updates their score, it returns how many were added
+	ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error)
+	// ZRangeByScore returns the members scored from min to max included, ordered by score, from offset,
+	// a count of 0 returns every member
+	ZRangeByScore(ctx context.Context, key string, min float64, max float64, offset int64, count int64) ([]ZMember, error)
+	// ZRank returns the rank of member ordered by score from the lowest, ErrNotFound when it is no member
+	ZRank(ctx context.Context, key string, member string) (int64, error)
+	// ZRevRank returns the rank of member ordered by score from the highest
+	ZRevRank(ctx context.Context, key string, member string) (int64, error)
+}
+
+// StreamEntry of a stream, its id is "milliseconds-sequence"
+type StreamEntry struct {
+	ID     string
+	Fields map[string]string
+}
+
+// Streams keeps append only logs of entries read by consumer groups
+type Streams interface {
+	// XAdd appends an entry to the stream at key and returns its id, an empty id or "*" generates it,
+	// a maxLen above 0 removes the oldest entries beyond it
+	XAdd(ctx context.Context, key string, id string, fields map[string]string, maxLen int64) (string, error)
+	// XRead returns up to count entries after the id after, "" or "0" reads from the start and "$" only
+	// the next entries, it waits up to block for an entry, a block of 0 does not wait
+	XRead(ctx context.Context, key string, after string, count int64, block time.Duration) ([]StreamEntry, error)
+	// XGroupCreate creates the consumer group of the stream at key, which is created when it does not exist,
+	// the group reads the entries after the id start, "$" only the next entries
+	XGroupCreate(ctx context.Context, key string, group string, start string) error
+	// XReadGroup delivers to consumer up to count entries never delivered to the group, they are pending
+	// until they are acknowledged, it waits up to block like XRead
+	XReadGroup(ctx context.Context, key string, group string, consumer string, count int64, block time.Duration) ([]StreamEntry, error)
+	// XAck acknowledges the pending entries of ids and returns how many were pending
+	XAck(ctx context.Context, key string, group string, ids ...string) (int64, error)
+}
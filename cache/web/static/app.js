@@ -0,0 +1,131 @@
+(function () {
+  'use strict';
+
+  const api = location.pathname.replace(/\/ui\/.*$/, '') + '/api/admin';
+  const $ = (id) => document.getElementById(id);
+  const token = $('token');
+
+  let current = null; // key shown in the details
+  let cursor = '';    // cursor of the next page of keys
+
+  token.value = sessionStorage.getItem('cache.token') || '';
+  token.addEventListener('change', () => {
+    sessionStorage.setItem('cache.token', token.value);
+    namespaces();
+  });
+
+  function request(method, url) {
+    const headers = {};
+    if (token.value) headers.Authorization = 'Bearer ' + token.value;
+    return fetch(api + url, { method, headers }).then(async (r) => {
+      if (!r.ok) {
+        const e = await r.json().catch(() => ({ message: r.statusText }));
+        throw new Error(e.message);
+      }
+      return r;
+    });
+  }
+
+  function message(text, error) {
+    $('message').textContent = text || '';
+    $('message').className = error ? 'error' : '';
+  }
+
+  function namespaces() {
+    request('GET', '/namespaces').then((r) => r.json()).then((r) => {
+      const select = $('namespace');
+      const selected = select.value;
+      select.length = 1;
+      for (const ns of r.namespaces) {
+        select.add(new Option(ns, ns, false, ns === selected));
+      }
+      if (r.truncated) select.add(new Option('… more namespaces not scanned', '', false, false));
+      keys(true);
+    }).catch((e) => alert(e.message));
+  }
+
+  // keys lists the first page of keys when reset is set, else appends the next page
+  function keys(reset) {
+    if (reset) {
+      cursor = '';
+      $('keys').textContent = '';
+    }
+    const q = new URLSearchParams({ namespace: $('namespace').value, match: $('match').value, cursor: cursor });
+    request('GET', '/keys?' + q).then((r) => r.json()).then((r) => {
+      for (const k of r.keys) {
+        const li = document.createElement('li');
+        li.textContent = k;
+        li.classList.toggle('active', k === current);
+        li.addEventListener('click', () => open(k));
+        $('keys').appendChild(li);
+      }
+      cursor = r.cursor || '';
+      $('more').hidden = !cursor;
+    }).catch((e) => alert(e.message));
+  }
+
+  function row(name, value) {
+    const tr = document.createElement('tr');
+    const th = document.createElement('th');
+    const td = document.createElement('td');
+    th.textContent = name;
+    td.textContent = value;
+    tr.append(th, td);
+    $('details').tBodies[0].appendChild(tr);
+  }
+
+  function ttl(ms) {
+    return ms < 0 ? 'never expires' : (ms / 1000).toFixed(1) + 's';
+  }
+
+  // show renders a json value as is and pretty prints a json string value
+  function show(k) {
+    if (k.value !== undefined) {
+      try {
+        return JSON.stringify(JSON.parse(k.value), null, 2);
+      } catch (e) {
+        return k.value;
+      }
+    }
+    if (k.data) return 'binary, base64\n' + k.data;
+    return JSON.stringify(k.fields || k.elements || k.members || k.entries || null, null, 2);
+  }
+
+  function open(k) {
+    current = k;
+    for (const li of $('keys').children) li.classList.toggle('active', li.textContent === k);
+    $('key').hidden = false;
+    $('name').textContent = k;
+    $('state').textContent = 'loading';
+    message('');
+    request('GET', '/keys/' + encodeURIComponent(k)).then((r) => r.json()).then((r) => {
+      $('details').tBodies[0].textContent = '';
+      row('kind', r.kind);
+      row('ttl', ttl(r.ttl_ms));
+      row('size', r.size + ' bytes');
+      if (r.content_type) row('content type', r.content_type);
+      if (r.compression) row('compression', r.compression);
+      if (r.created_ms) row('created', new Date(r.created_ms).toISOString());
+      $('value').textContent = show(r);
+      $('state').textContent = '';
+    }).catch((e) => {
+      $('state').textContent = '';
+      message(e.message, true);
+    });
+  }
+
+  $('namespace').addEventListener('change', () => keys(true));
+  $('match').addEventListener('change', () => keys(true));
+  $('more').addEventListener('click', () => keys(false));
+  $('refresh').addEventListener('click', () => current && open(current));
+  $('delete').addEventListener('click', () => {
+    if (!current || !confirm('Delete ' + current + '?')) return;
+    request('DELETE', '/keys/' + encodeURIComponent(current)).then(() => {
+      $('key').hidden = true;
+      current = null;
+      keys(true);
+    }).catch((e) => message(e.message, true));
+  });
+
+  namespaces();
+})();
@@ -0,0 +1,15 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed static
+var static embed.FS
+
+// UI admin web ui files
+func UI() fs.FS {
+	ui, _ := fs.Sub(static, "static")
+	return ui
+}
@@ -0,0 +1,137 @@
+package access
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+type Permission string
+
+const (
+	Read  Permission = "read"
+	Write Permission = "write"
+	Watch Permission = "watch"
+)
+
+// Policy grants permissions on the paths matching Paths to Subjects.
+// Paths are globs relative to the config root, '*' matches inside one path segment and '**' across segments.
+// Subjects are principals of a kind, user:<user id> or service:<service name>, user:* and service:* match any
+// caller of their kind and '*' matches any caller including anonymous ones.
+type Policy struct {
+	Subjects    []string     `json:"subjects"`
+	Paths       []string     `json:"paths"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Control check callers against policies, every request is denied when there is no policy unless Disabled
+type Control struct {
+	Policies      []Policy
+	Authenticator *Authenticator
+	// Disabled allows every request when there is no policy
+	Disabled bool
+	patterns map[string]*regexp.Regexp
+}
+
+func NewControl(authenticator *Authenticator, policies ...Policy) (*Control, error) {
+	c := &Control{
+		Policies:      policies,
+		Authenticator: authenticator,
+		patterns:      map[string]*regexp.Regexp{},
+	}
+	for _, p := range policies {
+		for _, g := range p.Paths {
+			re, err := compile(g)
+			if err != nil {
+				return nil, err
+			}
+			c.patterns[g] = re
+		}
+	}
+	return c, nil
+}
+
+// Enabled reports whether any policy is configured
+func (c *Control) Enabled() bool {
+	return len(c.Policies) > 0
+}
+
+// Allowed reports whether caller holds perm on p
+func (c *Control) Allowed(caller Caller, perm Permission, p string) bool {
+	if !c.Enabled() {
+		return c.Disabled
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	for _, policy := range c.Policies {
+		if !containsSubject(policy.Subjects, caller) || !containsPermission(policy.Permissions, perm) {
+			continue
+		}
+		for _, g := range policy.Paths {
+			if c.patterns[g].MatchString(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsSubject(subjects []string, caller Caller) bool {
+	for _, s := range subjects {
+		if s == "*" {
+			return true
+		}
+		kind, name, ok := strings.Cut(s, ":")
+		if ok && kind == caller.Kind && caller.Kind != KindAnonymous && (name == "*" || name == caller.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPermission(permissions []Permission, perm Permission) bool {
+	for _, p := range permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether p matches glob, with the glob syntax of Policy.Paths
+func Match(glob string, p string) (bool, error) {
+	re, err := compile(glob)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(strings.TrimPrefix(p, "/")), nil
+}
+
+// compile turns a path glob into a regular expression
+func compile(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(glob, "/")
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// "**/" also matches no directory at all
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
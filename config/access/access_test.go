@@ -0,0 +1,58 @@
+package access
+
+import "testing"
+
+func TestControl_Allowed(t *testing.T) {
+	c, err := NewControl(nil,
+		Policy{Subjects: []string{"service:gateway"}, Paths: []string{"gateway/*.yaml"}, Permissions: []Permission{Read, Watch}},
+		Policy{Subjects: []string{"user:admin"}, Paths: []string{"**"}, Permissions: []Permission{Read, Write, Watch}},
+		Policy{Subjects: []string{"*"}, Paths: []string{"public/**/*.json"}, Permissions: []Permission{Read}},
+		Policy{Subjects: []string{"user:*"}, Paths: []string{"users/**"}, Permissions: []Permission{Read}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := Caller{Name: "gateway", Kind: KindService}
+	tests := []struct {
+		name   string
+		caller Caller
+		perm   Permission
+		path   string
+		want   bool
+	}{
+		{name: "granted", caller: gateway, perm: Read, path: "gateway/routes.yaml", want: true},
+		{name: "leading slash", caller: gateway, perm: Watch, path: "/gateway/routes.yaml", want: true},
+		{name: "not granted permission", caller: gateway, perm: Write, path: "gateway/routes.yaml"},
+		{name: "star does not cross directories", caller: gateway, perm: Read, path: "gateway/v1/routes.yaml"},
+		{name: "other subject", caller: Caller{Name: "cache", Kind: KindService}, perm: Read, path: "gateway/routes.yaml"},
+		{name: "user named like a service", caller: Caller{Name: "gateway", Kind: KindUser}, perm: Read, path: "gateway/routes.yaml"},
+		{name: "double star", caller: Caller{Name: "admin", Kind: KindUser}, perm: Write, path: "identity/database.yaml", want: true},
+		{name: "anonymous", caller: Anonymous, perm: Read, path: "public/micro.json", want: true},
+		{name: "anonymous nested", caller: Anonymous, perm: Read, path: "public/a/b/micro.json", want: true},
+		{name: "anonymous write", caller: Anonymous, perm: Write, path: "public/micro.json"},
+		{name: "any user", caller: Caller{Name: "ada", Kind: KindUser}, perm: Read, path: "users/ada.json", want: true},
+		{name: "any user is no service", caller: gateway, perm: Read, path: "users/ada.json"},
+		{name: "any user is no anonymous", caller: Anonymous, perm: Read, path: "users/ada.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Allowed(tt.caller, tt.perm, tt.path); got != tt.want {
+				t.Errorf("Control.Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestControl_AllowedWithoutPolicy(t *testing.T) {
+	c, err := NewControl(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Allowed(Anonymous, Write, "micro.json") {
+		t.Error("Control.Allowed() = true without policy")
+	}
+	c.Disabled = true
+	if !c.Allowed(Anonymous, Write, "micro.json") {
+		t.Error("Control.Allowed() = false with access control disabled")
+	}
+}
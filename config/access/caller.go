@@ -0,0 +1,113 @@
+package access
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/sparrow-community/pkgs/auth"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"strings"
+	"sync"
+)
+
+const (
+	KindAnonymous = "anonymous"
+	KindUser      = "user"
+	KindService   = "service"
+)
+
+// Caller identity of a request
+type Caller struct {
+	// Name user id of a JWT subject or service name of a client certificate
+	Name string
+	Kind string
+}
+
+var Anonymous = Caller{Kind: KindAnonymous}
+
+// KeyLoader returns the verifier of identity access tokens
+type KeyLoader func(ctx context.Context) (*auth.Authenticate, error)
+
+// Authenticator resolves the caller of a request from its bearer token or its mTLS client certificate
+type Authenticator struct {
+	sync.Mutex
+	load KeyLoader
+	n    *auth.Authenticate
+}
+
+// NewAuthenticator verifies bearer tokens with the key returned by load, a nil load disables bearer tokens
+func NewAuthenticator(load KeyLoader) *Authenticator {
+	return &Authenticator{load: load}
+}
+
+// Caller returns the caller of ctx, an invalid bearer token is an error while no credential at all is Anonymous
+func (a *Authenticator) Caller(ctx context.Context) (Caller, error) {
+	if token, ok := bearer(ctx); ok {
+		n, err := a.verifier(ctx)
+		if err != nil {
+			return Anonymous, err
+		}
+		t, err := n.Parse([]byte(token))
+		if err != nil {
+			return Anonymous, err
+		}
+		return Caller{Name: t.Subject, Kind: KindUser}, nil
+	}
+	if name, ok := certificateName(ctx); ok {
+		return Caller{Name: name, Kind: KindService}, nil
+	}
+	return Anonymous, nil
+}
+
+// verifier loads the token verifier once it succeeds, identity creates its key on first start
+func (a *Authenticator) verifier(ctx context.Context) (*auth.Authenticate, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.n != nil {
+		return a.n, nil
+	}
+	if a.load == nil {
+		return nil, errors.New("bearer token is not supported")
+	}
+	n, err := a.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.n = n
+	return n, nil
+}
+
+func bearer(ctx context.Context) (string, bool) {
+	v, ok := metadata.Get(ctx, "Authorization")
+	if !ok {
+		return "", false
+	}
+	schema, token, ok := strings.Cut(v, " ")
+	if !ok || !strings.EqualFold(schema, "bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// certificateName returns the common name of a verified client certificate
+func certificateName(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return "", false
+	}
+	return verifiedName(info.State)
+}
+
+func verifiedName(state tls.ConnectionState) (string, bool) {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	name := state.VerifiedChains[0][0].Subject.CommonName
+	return name, name != ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	mcgrpc "github.com/go-micro/plugins/v4/client/grpc"
+	"github.com/sparrow-community/app/config/config"
+	"github.com/sparrow-community/app/config/storage"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/util/wrapper"
+)
+
+// migrate copies every document from a storage to another one, e.g.
+//
+//	migrate -from file -to bolt -path ./conf -bolt ./config.db
+func main() {
+	from := flag.String("from", config.StorageFile, "source storage, file, bolt or redis")
+	to := flag.String("to", config.StorageBolt, "destination storage, file, bolt or redis")
+	flag.StringVar(&config.Conf.Configs.Path, "path", config.Conf.Configs.Path, "file storage directory")
+	flag.StringVar(&config.Conf.Storage.Bolt, "bolt", config.Conf.Storage.Bolt, "bolt storage database file")
+	flag.Parse()
+
+	if *from == *to {
+		logger.Fatalf("source and destination are both %s", *from)
+	}
+
+	// the cache names the namespace of the documents after the service name of its client
+	c := wrapper.FromService(config.Conf.Server.Name, mcgrpc.NewClient())
+	src, err := config.Conf.OpenStorage(*from, c)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	dst, err := config.Conf.OpenStorage(*to, c)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	paths, err := storage.Copy(dst, src)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	for _, p := range paths {
+		logger.Infof("copied %s", p)
+	}
+	logger.Infof("%d documents copied from %s to %s", len(paths), src, dst)
+}
@@ -1,9 +1,26 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/event"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/storage"
+	"github.com/sparrow-community/pkgs/auth"
 	mconfig "github.com/sparrow-community/pkgs/config"
+	"github.com/sparrow-community/protos/cache"
 	"github.com/urfave/cli/v2"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/client"
 	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/registry"
+	"os"
+	"time"
 )
 
 var (
@@ -14,6 +31,32 @@ var (
 		Configs: Configs{
 			Path: "./conf",
 		},
+		Secrets: Secrets{
+			Keyfile: "./config.key",
+		},
+		Access: Access{
+			TLS: TLS{
+				Address: ":8443",
+			},
+		},
+		Storage: Storage{
+			Type:     StorageFile,
+			Bolt:     "./config.db",
+			Interval: "5s",
+		},
+		History: History{
+			File:  "./history.db",
+			Limit: 50,
+		},
+		Replication: Replication{
+			Interval: "2s",
+		},
+		Events: Events{
+			Log:      "./events.log",
+			Topic:    "config.change",
+			Attempts: 5,
+			Backoff:  "1s",
+		},
 	}
 )
 
@@ -21,17 +64,125 @@ type Configs struct {
 	Path string `json:"path"`
 }
 
+// Secrets ENC[...] values, Keyfile must live outside Configs.Path, the replicas share it and reload it for the keys
+// the leader rotates
+type Secrets struct {
+	Keyfile string `json:"keyfile"`
+	// Decrypt services allowed to read decrypted values
+	Decrypt []string `json:"decrypt"`
+}
+
+// TLS server certificate, client certificates signed by CA identify services by their common name
+type TLS struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	CA   string `json:"ca"`
+	// Address of the grpc server with tls, the listener multiplexer cannot tell tls connections apart
+	Address string `json:"address"`
+}
+
+// Access policies of the callers, every request is denied without a policy unless Disabled
+type Access struct {
+	TLS      TLS             `json:"tls"`
+	Policies []access.Policy `json:"policies"`
+	// Disabled allows every request when there is no policy
+	Disabled bool `json:"disabled"`
+}
+
+const (
+	StorageFile  = "file"
+	StorageBolt  = "bolt"
+	StorageRedis = "redis"
+)
+
+// Storage documents backend, file serves Configs.Path
+type Storage struct {
+	Type string `json:"type"`
+	// Bolt database file of the bolt storage
+	Bolt string `json:"bolt"`
+	// Interval redis storage polling interval of watched documents
+	Interval string `json:"interval"`
+}
+
+// History revisions of the documents, 0 Limit keeps every revision
+type History struct {
+	File  string `json:"file"`
+	Limit int    `json:"limit"`
+}
+
+// Replication between the replicas registered under the service name, the oldest replica is the leader,
+// every replica needs the same Secret and Secrets.Keyfile. The replicas reach each other through the multiplexed
+// listener, which has no tls, and send Secret as plaintext metadata: keep them on a private network
+type Replication struct {
+	Enabled bool   `json:"enabled"`
+	Secret  string `json:"secret"`
+	// Interval of the followers sync from the leader
+	Interval string `json:"interval"`
+}
+
+// Webhook receives the change events, Secret signs them
+type Webhook struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// Events of the documents changes, appended to Log, published on the broker Topic and posted to the Webhooks,
+// an empty Log or Topic disables it
+type Events struct {
+	Log      string    `json:"log"`
+	Topic    string    `json:"topic"`
+	Webhooks []Webhook `json:"webhooks"`
+	// Attempts to post an event to a webhook
+	Attempts int `json:"attempts"`
+	// Backoff delay before the first retry of a webhook, doubled after each retry
+	Backoff string `json:"backoff"`
+}
+
 type Config struct {
-	Server  mconfig.Server `json:"server"`
-	Configs Configs        `json:"configs"`
+	Server      mconfig.Server `json:"server"`
+	Configs     Configs        `json:"configs"`
+	Secrets     Secrets        `json:"secrets"`
+	Access      Access         `json:"access"`
+	Storage     Storage        `json:"storage"`
+	History     History        `json:"history"`
+	Replication Replication    `json:"replication"`
+	Events      Events         `json:"events"`
+
+	Control *access.Control `json:"-"`
 }
 
+const (
+	CacheIdentityRSATokenKey = "cache:identity:rsa:token"
+	CacheIdentityRSAPublic   = "public_key"
+	// CacheIdentityNamespace cache namespace of the identity keys, config needs to be allowed to read it
+	CacheIdentityNamespace = "identity"
+)
+
 // Init .
 func (c *Config) Init() error {
 	mc, err := mconfig.New(
 		mconfig.WithDefaultConfig(c),
 		mconfig.WithFlags(
 			&cli.StringFlag{Name: "configs_path", Usage: "config files directory", EnvVars: []string{"CONFIGS_PATH"}},
+			&cli.StringFlag{Name: "secrets_keyfile", Usage: "secret values key file", EnvVars: []string{"SECRETS_KEYFILE"}},
+			&cli.StringSliceFlag{Name: "secrets_decrypt", Usage: "services allowed to read decrypted secret values", EnvVars: []string{"SECRETS_DECRYPT"}},
+			&cli.StringFlag{Name: "access_tls_cert", Usage: "tls certificate file", EnvVars: []string{"ACCESS_TLS_CERT"}},
+			&cli.StringFlag{Name: "access_tls_key", Usage: "tls private key file", EnvVars: []string{"ACCESS_TLS_KEY"}},
+			&cli.StringFlag{Name: "access_tls_ca", Usage: "client certificates authority file", EnvVars: []string{"ACCESS_TLS_CA"}},
+			&cli.StringFlag{Name: "access_tls_address", Usage: "grpc tls server address", EnvVars: []string{"ACCESS_TLS_ADDRESS"}},
+			&cli.BoolFlag{Name: "access_disabled", Usage: "allow every request when there is no access policy", EnvVars: []string{"ACCESS_DISABLED"}},
+			&cli.StringFlag{Name: "storage_type", Usage: "documents storage, file, bolt or redis", EnvVars: []string{"STORAGE_TYPE"}},
+			&cli.StringFlag{Name: "storage_bolt", Usage: "bolt storage database file", EnvVars: []string{"STORAGE_BOLT"}},
+			&cli.StringFlag{Name: "storage_interval", Usage: "redis storage polling interval", EnvVars: []string{"STORAGE_INTERVAL"}},
+			&cli.StringFlag{Name: "history_file", Usage: "documents revisions database file", EnvVars: []string{"HISTORY_FILE"}},
+			&cli.IntFlag{Name: "history_limit", Usage: "revisions kept by document, 0 keeps every revision", EnvVars: []string{"HISTORY_LIMIT"}},
+			&cli.BoolFlag{Name: "replication_enabled", Usage: "replicate the documents between the service replicas", EnvVars: []string{"REPLICATION_ENABLED"}},
+			&cli.StringFlag{Name: "replication_secret", Usage: "secret shared by the replicas, sent in plaintext, keep the replicas on a private network", EnvVars: []string{"REPLICATION_SECRET"}},
+			&cli.StringFlag{Name: "replication_interval", Usage: "followers sync interval", EnvVars: []string{"REPLICATION_INTERVAL"}},
+			&cli.StringFlag{Name: "events_log", Usage: "change events audit log file", EnvVars: []string{"EVENTS_LOG"}},
+			&cli.StringFlag{Name: "events_topic", Usage: "change events broker topic", EnvVars: []string{"EVENTS_TOPIC"}},
+			&cli.IntFlag{Name: "events_attempts", Usage: "attempts to post an event to a webhook", EnvVars: []string{"EVENTS_ATTEMPTS"}},
+			&cli.StringFlag{Name: "events_backoff", Usage: "webhook first retry delay", EnvVars: []string{"EVENTS_BACKOFF"}},
 		),
 	)
 	if err != nil {
@@ -42,7 +193,135 @@ func (c *Config) Init() error {
 		return err
 	}
 
-	logger.Infof("Read config: %+#v", c)
+	logger.Infof("Read config: %+#v", c.redacted())
 
 	return nil
 }
+
+// redacted returns a copy of c without its secrets, for the logs
+func (c *Config) redacted() *Config {
+	r := *c
+	r.Replication.Secret = redact(r.Replication.Secret)
+	r.Events.Webhooks = make([]Webhook, len(c.Events.Webhooks))
+	for i, w := range c.Events.Webhooks {
+		r.Events.Webhooks[i] = Webhook{URL: w.URL, Secret: redact(w.Secret)}
+	}
+	return &r
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
+// InitAccess verifies bearer tokens with the identity public key stored in cache
+func (c *Config) InitAccess(client cache.CacheService) error {
+	load := func(ctx context.Context) (*auth.Authenticate, error) {
+		ctx = metadata.MergeContext(ctx, metadata.Metadata{"Cache-Namespace": CacheIdentityNamespace}, true)
+		ret, err := client.HGet(ctx, &cache.HGetRequest{Key: CacheIdentityRSATokenKey, Field: CacheIdentityRSAPublic})
+		if err != nil {
+			return nil, err
+		}
+		if len(ret.Value) <= 0 {
+			return nil, errors.New("identity public key not found")
+		}
+		return auth.New(auth.WithRsaPublicKeyBytes([]byte(ret.Value)))
+	}
+	control, err := access.NewControl(access.NewAuthenticator(load), c.Access.Policies...)
+	if err != nil {
+		return err
+	}
+	control.Disabled = c.Access.Disabled
+	switch {
+	case control.Enabled():
+	case control.Disabled:
+		logger.Warn("access control disabled, every caller can read, write and watch every path")
+	default:
+		logger.Warn("no access policy, every request is denied")
+	}
+	c.Control = control
+	return nil
+}
+
+// TLSConfig returns nil when no certificate is configured
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	t := c.Access.TLS
+	if t.Cert == "" || t.Key == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", t.CA)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tc, nil
+}
+
+// NewReplica returns the replica of the server id, nil when the replication is disabled
+func (c *Config) NewReplica(id string, cl client.Client, r registry.Registry) (*replica.Replica, error) {
+	if !c.Replication.Enabled {
+		return nil, nil
+	}
+	if c.Replication.Secret == "" {
+		return nil, errors.New("replication needs a secret")
+	}
+	interval, err := time.ParseDuration(c.Replication.Interval)
+	if err != nil {
+		return nil, err
+	}
+	return replica.New(c.Server.Name, id, c.Replication.Secret, interval, cl, r), nil
+}
+
+// NewNotifier returns the notifier of the change events, b publishes them on Events.Topic
+func (c *Config) NewNotifier(b broker.Broker) (*event.Notifier, error) {
+	var sinks []event.Sink
+	if c.Events.Log != "" {
+		l, err := event.NewLog(c.Events.Log)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, l)
+	}
+	if c.Events.Topic != "" {
+		sinks = append(sinks, event.NewBroker(b, c.Events.Topic))
+	}
+	backoff, err := time.ParseDuration(c.Events.Backoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range c.Events.Webhooks {
+		sinks = append(sinks, event.NewWebhook(w.URL, w.Secret, c.Events.Attempts, backoff))
+	}
+	return event.NewNotifier(1000, sinks...), nil
+}
+
+// OpenStorage opens a storage of type kind, cl calls the cache service of the redis storage
+func (c *Config) OpenStorage(kind string, cl client.Client) (storage.Storage, error) {
+	switch kind {
+	case StorageFile:
+		return storage.NewFile(c.Configs.Path)
+	case StorageBolt:
+		return storage.NewBolt(c.Storage.Bolt)
+	case StorageRedis:
+		interval, err := time.ParseDuration(c.Storage.Interval)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewRedis(cl, interval), nil
+	}
+	return nil, fmt.Errorf("unknown storage %s", kind)
+}
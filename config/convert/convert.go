@@ -0,0 +1,109 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ghodss/yaml"
+	"strconv"
+	"strings"
+)
+
+const (
+	JSON = "json"
+	YAML = "yaml"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// Decode parses data of format into maps, slices and scalar values
+func Decode(format string, data []byte) (interface{}, error) {
+	var v interface{}
+	switch normalize(format) {
+	case JSON:
+		if len(data) == 0 {
+			return nil, nil
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+	case YAML:
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+	return v, nil
+}
+
+// Encode marshals v into format
+func Encode(format string, v interface{}) ([]byte, error) {
+	switch normalize(format) {
+	case JSON:
+		return json.MarshalIndent(v, "", "  ")
+	case YAML:
+		return yaml.Marshal(v)
+	}
+	return nil, fmt.Errorf("unsupported format %s", format)
+}
+
+// Lookup returns the value at key, a dot separated path of map keys and slice indexes like database.replicas.0.dsn
+func Lookup(v interface{}, key string) (interface{}, bool) {
+	if key == "" {
+		return v, true
+	}
+	for _, k := range strings.Split(key, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			next, ok := t[k]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Select returns the value at key of data converted from format from to format to
+func Select(data []byte, from string, to string, key string) ([]byte, error) {
+	if key == "" && normalize(from) == normalize(to) {
+		return data, nil
+	}
+	v, err := Decode(from, data)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := Lookup(v, key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return Encode(to, v)
+}
+
+// Supported reports whether format can be decoded and encoded
+func Supported(format string) bool {
+	switch normalize(format) {
+	case JSON, YAML:
+		return true
+	}
+	return false
+}
+
+func normalize(format string) string {
+	switch f := strings.ToLower(format); f {
+	case "yml":
+		return YAML
+	default:
+		return f
+	}
+}
@@ -0,0 +1,42 @@
+package convert
+
+import (
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	doc := []byte(`database:
+  dsn: postgres://localhost:5432/postgres
+  replicas:
+    - dsn: postgres://replica:5432/postgres
+redis:
+  addr: localhost:6379
+`)
+	tests := []struct {
+		name    string
+		to      string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "same format", to: "yml", want: string(doc)},
+		{name: "sub tree", to: "json", key: "redis", want: "{\n  \"addr\": \"localhost:6379\"\n}"},
+		{name: "scalar", to: "json", key: "database.dsn", want: `"postgres://localhost:5432/postgres"`},
+		{name: "slice index", to: "yaml", key: "database.replicas.0.dsn", want: "postgres://replica:5432/postgres\n"},
+		{name: "missing key", to: "json", key: "database.user", wantErr: true},
+		{name: "index out of range", to: "json", key: "database.replicas.1", wantErr: true},
+		{name: "unsupported format", to: "toml", key: "redis", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(doc, "yaml", tt.to, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Select() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
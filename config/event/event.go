@@ -0,0 +1,90 @@
+package event
+
+import (
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Event change of a document
+type Event struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+	// OldChecksum empty for a created document
+	OldChecksum string `json:"old_checksum"`
+	// NewChecksum empty for a deleted document
+	NewChecksum string `json:"new_checksum"`
+	// Revision of the new version in the history, 0 without history
+	Revision int64 `json:"revision"`
+	// Actor caller who made the change, empty for a change made directly in the storage
+	Actor string `json:"actor"`
+	Diff  Diff   `json:"diff"`
+	// Time unix time of the change
+	Time int64 `json:"time"`
+}
+
+// Diff summary of a change
+type Diff struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Sink receives the events
+type Sink interface {
+	Publish(e *Event) error
+	String() string
+}
+
+// Notifier sends the events to its sinks in order, the logs are written by the change so they keep every event,
+// the other sinks are queued without blocking the changes
+type Notifier struct {
+	logs  []Sink
+	sinks []Sink
+	queue chan *Event
+}
+
+// NewNotifier queues up to size events for the sinks other than the logs
+func NewNotifier(size int, sinks ...Sink) *Notifier {
+	n := &Notifier{queue: make(chan *Event, size)}
+	for _, s := range sinks {
+		if _, ok := s.(*Log); ok {
+			n.logs = append(n.logs, s)
+		} else {
+			n.sinks = append(n.sinks, s)
+		}
+	}
+	go n.run()
+	return n
+}
+
+// Notify writes e to the logs and queues it for the other sinks, e is dropped from them when the queue is full
+func (n *Notifier) Notify(e *Event) {
+	for _, s := range n.logs {
+		if err := s.Publish(e); err != nil {
+			logger.Errorf("publish %s %s event to %s error %s", e.Type, e.Path, s, err)
+		}
+	}
+	if len(n.sinks) == 0 {
+		return
+	}
+	select {
+	case n.queue <- e:
+	default:
+		logger.Errorf("event queue full, drop %s %s event %s", e.Type, e.Path, e.Id)
+	}
+}
+
+func (n *Notifier) run() {
+	for e := range n.queue {
+		for _, s := range n.sinks {
+			if err := s.Publish(e); err != nil {
+				logger.Errorf("publish %s %s event to %s error %s", e.Type, e.Path, s, err)
+			}
+		}
+	}
+}
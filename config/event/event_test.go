@@ -0,0 +1,102 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var calls int32
+	received := make(chan *Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", body) {
+			t.Errorf("signature %s", r.Header.Get(SignatureHeader))
+		}
+		// fail the first attempt
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Error(err)
+		}
+		received <- &e
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(10, NewWebhook(srv.URL, "secret", 3, time.Millisecond))
+	n.Notify(&Event{Id: "1", Type: Update, Path: "app.yaml"})
+	select {
+	case e := <-received:
+		if e.Id != "1" || e.Path != "app.yaml" || atomic.LoadInt32(&calls) != 2 {
+			t.Fatalf("event %+v after %d calls", e, calls)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.log")
+	l, err := NewLog(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := l.Publish(&Event{Id: id, Type: Create, Path: "app.yaml"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"id":"2"`) {
+		t.Fatalf("log %s", data)
+	}
+}
+
+func TestNotifierLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.log")
+	l, err := NewLog(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the full queue of the other sinks does not drop the logged events
+	block := make(chan struct{})
+	defer close(block)
+	n := NewNotifier(1, l, blocking(block))
+	for i := 0; i < 5; i++ {
+		n.Notify(&Event{Id: string(rune('1' + i)), Type: Update, Path: "app.yaml"})
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 5 {
+		t.Fatalf("log %s", data)
+	}
+}
+
+// blocking sink waits on its channel
+type blocking chan struct{}
+
+func (b blocking) Publish(*Event) error {
+	<-b
+	return nil
+}
+
+func (b blocking) String() string {
+	return "blocking"
+}
@@ -0,0 +1,165 @@
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/logger"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SignatureHeader of the webhook requests, sha256= followed by the hex hmac of the body
+const SignatureHeader = "X-Config-Signature"
+
+// Log appends the events to a file as json lines
+type Log struct {
+	sync.Mutex
+	file *os.File
+}
+
+func NewLog(file string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{file: f}, nil
+}
+
+func (l *Log) Publish(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.Lock()
+	defer l.Unlock()
+	_, err = l.file.Write(append(data, '\n'))
+	return err
+}
+
+func (l *Log) String() string {
+	return "log " + l.file.Name()
+}
+
+// Broker publishes the events as json messages on a topic
+type Broker struct {
+	broker broker.Broker
+	topic  string
+}
+
+func NewBroker(b broker.Broker, topic string) *Broker {
+	return &Broker{broker: b, topic: topic}
+}
+
+func (b *Broker) Publish(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.broker.Publish(b.topic, &broker.Message{
+		Header: map[string]string{"Content-Type": "application/json", "Id": e.Id},
+		Body:   data,
+	})
+}
+
+func (b *Broker) String() string {
+	return "broker topic " + b.topic
+}
+
+// Webhook posts the events to an url, a failed post is retried with a doubling backoff
+type Webhook struct {
+	url      string
+	secret   string
+	attempts int
+	backoff  time.Duration
+	client   *http.Client
+	queue    chan []byte
+}
+
+// NewWebhook signs the events with secret and tries to post each of them attempts times
+func NewWebhook(url string, secret string, attempts int, backoff time.Duration) *Webhook {
+	w := &Webhook{
+		url:      url,
+		secret:   secret,
+		attempts: attempts,
+		backoff:  backoff,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan []byte, 1000),
+	}
+	go w.run()
+	return w
+}
+
+// Publish queues e, the events of a webhook are posted in order
+func (w *Webhook) Publish(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	select {
+	case w.queue <- data:
+		return nil
+	default:
+		return fmt.Errorf("queue full")
+	}
+}
+
+func (w *Webhook) String() string {
+	return "webhook " + w.url
+}
+
+func (w *Webhook) run() {
+	for data := range w.queue {
+		delay := w.backoff
+		for attempt := 1; ; attempt++ {
+			err := w.post(data)
+			if err == nil {
+				break
+			}
+			if attempt >= w.attempts {
+				logger.Errorf("%s error %s, drop event after %d attempts", w, err, attempt)
+				break
+			}
+			logger.Warnf("%s error %s, retry in %s", w, err, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+func (w *Webhook) post(data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, data))
+	}
+	rsp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", rsp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
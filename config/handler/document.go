@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/storage"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"strings"
+)
+
+type ListRequest struct {
+	// Prefix keeps the paths starting with it
+	Prefix string `json:"prefix"`
+	// Glob keeps the paths matching it, '*' matches inside one path segment and '**' across segments
+	Glob string `json:"glob"`
+}
+
+type Document struct {
+	Path     string `json:"path"`
+	Format   string `json:"format"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+	// Modified unix time
+	Modified int64 `json:"modified"`
+}
+
+type ListResponse struct {
+	Documents []*Document `json:"documents"`
+}
+
+type DeleteRequest struct {
+	Path string `json:"path"`
+}
+
+type DeleteResponse struct{}
+
+type RenameRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type RenameResponse struct{}
+
+// DocumentService manage the documents tree, its messages are plain structs so it only answers the
+// application/grpc+json codec, not the protobuf one
+type DocumentService struct {
+	files *FileService
+}
+
+func NewDocumentService(files *FileService) *DocumentService {
+	return &DocumentService{files: files}
+}
+
+// List returns the documents the caller can read
+func (d *DocumentService) List(ctx context.Context, request *ListRequest, response *ListResponse) error {
+	caller, err := d.files.control.Authenticator.Caller(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "invalid credential %s", err)
+	}
+	paths, err := d.files.storage.List()
+	if err != nil {
+		return status.Errorf(codes.Internal, "list documents error %s", err)
+	}
+	sort.Strings(paths)
+	prefix := strings.TrimPrefix(request.Prefix, "/")
+	for _, p := range paths {
+		if !strings.HasPrefix(p, prefix) || !d.files.control.Allowed(caller, access.Read, p) {
+			continue
+		}
+		if request.Glob != "" {
+			if ok, err := access.Match(request.Glob, p); err != nil {
+				return status.Errorf(codes.InvalidArgument, "glob %s error %s", request.Glob, err)
+			} else if !ok {
+				continue
+			}
+		}
+		set, err := d.files.memory.Get(p)
+		if err != nil {
+			set, err = d.files.storage.Read(p)
+		}
+		if err == storage.ErrNotFound {
+			continue
+		} else if err != nil {
+			return status.Errorf(codes.Internal, "read %s error %s", p, err)
+		}
+		response.Documents = append(response.Documents, &Document{
+			Path:     p,
+			Format:   set.Format,
+			Size:     int64(len(set.Data)),
+			Checksum: set.Checksum,
+			Modified: set.Timestamp.Unix(),
+		})
+	}
+	return nil
+}
+
+func (d *DocumentService) Delete(ctx context.Context, request *DeleteRequest, _ *DeleteResponse) error {
+	caller, p, err := d.files.authorize(ctx, access.Write, request.Path)
+	if err != nil {
+		return err
+	}
+	return d.files.change(ctx, &replica.Change{Op: replica.OpDelete, Path: p, Actor: caller.Name})
+}
+
+// Rename moves a document, the caller needs the write permission on both paths
+func (d *DocumentService) Rename(ctx context.Context, request *RenameRequest, _ *RenameResponse) error {
+	caller, from, err := d.files.authorize(ctx, access.Write, request.From)
+	if err != nil {
+		return err
+	}
+	_, to, err := d.files.authorize(ctx, access.Write, request.To)
+	if err != nil {
+		return err
+	}
+	return d.files.change(ctx, &replica.Change{Op: replica.OpRename, Path: from, To: to, Actor: caller.Name})
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/event"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/storage"
+	"go-micro.dev/v4/registry"
+	"testing"
+	"time"
+)
+
+type sink chan *event.Event
+
+func (s sink) Publish(e *event.Event) error {
+	s <- e
+	return nil
+}
+
+func (s sink) String() string {
+	return "test"
+}
+
+func TestFileService_changed(t *testing.T) {
+	dir := t.TempDir()
+	s, err := storage.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("loaded.yaml", []byte("a: 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	events := make(sink, 10)
+	fs, err := NewFileService(s, WithEvents(event.NewNotifier(10, events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func(typ string, added int, removed int) *event.Event {
+		select {
+		case e := <-events:
+			if e.Type != typ || e.Path != "app.yaml" || e.Actor != "user" || e.Diff.Added != added || e.Diff.Removed != removed {
+				t.Fatalf("event %+v, want %s +%d -%d", e, typ, added, removed)
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", typ)
+		}
+		return nil
+	}
+
+	for _, c := range []*replica.Change{
+		{Op: replica.OpWrite, Path: "app.yaml", Data: []byte("a: 1\nb: 2\n"), Actor: "user"},
+		{Op: replica.OpWrite, Path: "app.yaml", Data: []byte("a: 1\nb: 3\n"), Actor: "user"},
+		{Op: replica.OpDelete, Path: "app.yaml", Actor: "user"},
+	} {
+		if err := fs.Apply(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	created := next(event.Create, 2, 0)
+	updated := next(event.Update, 1, 1)
+	deleted := next(event.Delete, 0, 2)
+	if updated.OldChecksum != created.NewChecksum || deleted.OldChecksum != updated.NewChecksum || deleted.NewChecksum != "" {
+		t.Fatalf("checksums %+v %+v %+v", created, updated, deleted)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+}
+
+func TestFileService_changedOnFollower(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.NewMemoryRegistry()
+	err = reg.Register(&registry.Service{Name: "config", Nodes: []*registry.Node{{
+		Id:       "config-a",
+		Address:  "a:8080",
+		Metadata: map[string]string{"server": "grpc", replica.StartedKey: "1"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(sink, 10)
+	fs, err := NewFileService(s,
+		WithReplica(replica.New("config", "b", "secret", time.Second, nil, reg)),
+		WithEvents(event.NewNotifier(10, events)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a change of the leader synced to the follower
+	if err := fs.Apply(&replica.Change{Op: replica.OpWrite, Path: "app.yaml", Data: []byte("a: 1\n"), Actor: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("follower notified %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -1,64 +1,137 @@
 package handler
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/convert"
+	"github.com/sparrow-community/app/config/event"
+	"github.com/sparrow-community/app/config/history"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/secret"
+	"github.com/sparrow-community/app/config/storage"
 	"github.com/sparrow-community/protos/config"
 	"go-micro.dev/v4/config/source"
+	"go-micro.dev/v4/logger"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
-	"os"
 	"path"
-	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-// FileService local config file watch
+// saveDelay a document removed from the storage may take to be saved again
+const saveDelay = 500 * time.Millisecond
+
+// FileService serves the config documents of a storage
 type FileService struct {
-	root   string
-	memory *Memory
+	storage storage.Storage
+	memory  *Memory
+	keyring *secret.Keyring
+	decrypt []string
+	control *access.Control
+	history *history.History
+	replica *replica.Replica
+	events  *event.Notifier
+	// ready once the documents are loaded, the loading is not notified as changes
+	ready bool
+
+	sync.Mutex
+	// actors of the writes in progress by path and checksum
+	actors map[string]string
+	// locks of the documents being changed by path
+	locks map[string]*documentLock
+}
+
+// documentLock serializes the changes of a document
+type documentLock struct {
+	sync.Mutex
+	refs int
 }
 
-func NewFileService(r string) (*FileService, error) {
-	memory := NewMemory()
-	getPath, err := getPaths(r)
+// NewFileService serves the documents of s to the callers allowed by the control option,
+// ENC[...] values are sealed with the keyring option and opened for its decrypt callers only
+func NewFileService(s storage.Storage, opts ...Option) (*FileService, error) {
+	o, err := newOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	f := &FileService{
+		storage: s,
+		memory:  NewMemory(s),
+		keyring: o.Keyring,
+		decrypt: o.Decrypt,
+		control: o.Control,
+		history: o.History,
+		replica: o.Replica,
+		events:  o.Events,
+		actors:  map[string]string{},
+		locks:   map[string]*documentLock{},
+	}
+	f.memory.OnChange(f.changed)
+	paths, err := s.List()
 	if err != nil {
 		return nil, err
 	}
-	if err := memory.Watch(getPath...); err != nil {
+	if err := f.memory.Watch(paths...); err != nil {
 		return nil, err
 	}
-	return &FileService{
-		root:   r,
-		memory: memory,
-	}, nil
+	f.Lock()
+	f.ready = true
+	f.Unlock()
+	if tw, ok := s.(storage.TreeWatcher); ok {
+		go f.watchTree(tw)
+	}
+	return f, nil
 }
 
+// Read returns the document at request.Path, see query for the key and format selection
 func (f *FileService) Read(ctx context.Context, request *proto.ReadRequest, response *proto.ReadResponse) error {
-	p := filepath.Clean(path.Join(f.root, request.Path))
-	set, err := f.memory.Get(p)
+	q, err := parseQuery(request.Path)
 	if err != nil {
-		if err = f.writeFile(p, []byte("")); err != nil {
+		return err
+	}
+	caller, p, err := f.authorize(ctx, access.Read, q.path)
+	if err != nil {
+		return err
+	}
+	set, err := f.load(p)
+	if err != nil {
+		return err
+	}
+	if !q.raw {
+		if set, _, err = f.interpolate(caller, p, set); err != nil {
 			return err
 		}
 	}
-	if set == nil {
-		set = &source.ChangeSet{}
+	if set, err = f.open(caller, set); err != nil {
+		return err
 	}
-	response.ChangeSet = &proto.ChangeSet{
-		Data:      set.Data,
-		Checksum:  set.Checksum,
-		Format:    set.Format,
-		Source:    set.Source,
-		Timestamp: time.Now().Unix(),
+	selected, err := q.apply(set)
+	if err != nil {
+		return err
 	}
-	return nil
+	response.ChangeSet, err = f.changeSet(caller, selected)
+	return err
 }
 
-func (f *FileService) Write(_ context.Context, request *proto.WriteRequest, response *wrapperspb.BoolValue) error {
-	dest := filepath.Clean(path.Join(f.root, request.Path))
-	if err := f.writeFile(dest, request.ChangeSet.Data); err != nil {
+func (f *FileService) Write(ctx context.Context, request *proto.WriteRequest, response *wrapperspb.BoolValue) error {
+	caller, p, err := f.authorize(ctx, access.Write, request.Path)
+	if err != nil {
+		response.Value = false
+		return err
+	}
+	data, err := f.seal(request.ChangeSet.Data)
+	if err != nil {
+		response.Value = false
+		return status.Errorf(codes.InvalidArgument, "encrypt %s error %s", request.Path, err)
+	}
+	if err := f.change(ctx, &replica.Change{Op: replica.OpWrite, Path: p, Data: data, Actor: caller.Name}); err != nil {
 		response.Value = false
 		return err
 	}
@@ -66,80 +139,547 @@ func (f *FileService) Write(_ context.Context, request *proto.WriteRequest, resp
 	return nil
 }
 
-func (f *FileService) Watch(_ context.Context, request *proto.WatchRequest, stream proto.Source_WatchStream) error {
-	set, err := f.memory.Get(filepath.Clean(path.Join(f.root, request.Path)))
+// Watch streams the document at request.Path until it is removed, a watch with a key
+// only receives the changes of the value at this key, the document is sent again when
+// a document it references changes
+func (f *FileService) Watch(ctx context.Context, request *proto.WatchRequest, stream proto.Source_WatchStream) error {
+	q, err := parseQuery(request.Path)
+	if err != nil {
+		return err
+	}
+	caller, p, err := f.authorize(ctx, access.Watch, q.path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.load(p); err != nil {
+		return err
+	}
+	set, updates, cancel, err := f.memory.Subscribe(p)
 	if err != nil {
 		return status.Errorf(codes.NotFound, "cannot read %s", err)
 	}
-	rsp := &proto.WatchResponse{
-		ChangeSet: &proto.ChangeSet{
-			Data:      set.Data,
-			Checksum:  set.Checksum,
-			Format:    set.Format,
-			Source:    set.Source,
-			Timestamp: time.Now().Unix(),
-		},
+	defer cancel()
+	refs := newReferences(f.memory)
+	defer refs.close()
+
+	var checksum string
+	for first := true; ; first = false {
+		selected := set
+		if !q.raw {
+			var deps []string
+			selected, deps, err = f.interpolate(caller, p, set)
+			refs.watch(deps)
+		}
+		if err == nil {
+			selected, err = f.open(caller, selected)
+		}
+		if err == nil {
+			selected, err = q.apply(selected)
+		}
+		if err != nil && first {
+			return err
+		}
+		// the key may be missing from this version or a reference may not resolve, wait for the next one
+		if err != nil {
+			logger.Warnf("watch %s error %s", request.Path, err)
+		} else if selected.Checksum != checksum {
+			cs, err := f.changeSet(caller, selected)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(&proto.WatchResponse{ChangeSet: cs}); err != nil {
+				return status.Errorf(codes.Internal, "watch send response error %s", err)
+			}
+			checksum = selected.Checksum
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case s, ok := <-updates:
+			if !ok {
+				return status.Errorf(codes.NotFound, "%s removed", p)
+			}
+			set = s
+		case <-refs.changed:
+		}
 	}
-	if err := stream.Send(rsp); err != nil {
-		return status.Errorf(codes.Internal, "watch send response error %s", err)
+}
+
+// changeSet returns the response change set of set, opened for the decrypt callers by open, the plaintext
+// ENC[...] values are sealed for the other callers
+func (f *FileService) changeSet(caller access.Caller, set *source.ChangeSet) (*proto.ChangeSet, error) {
+	data := set.Data
+	if !f.canDecrypt(caller) {
+		var err error
+		if data, err = f.reveal(caller, data); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return &proto.ChangeSet{
+		Data:      data,
+		Checksum:  set.Checksum,
+		Format:    set.Format,
+		Source:    set.Source,
+		Timestamp: time.Now().Unix(),
+	}, nil
 }
 
-func (f *FileService) writeFile(dest string, data []byte) error {
-	//dest := filepath.Clean(path.Join(f.root, request.Path))
-	dir := filepath.Dir(dest)
-	exists, err := exists(dir)
+// open decrypts the ENC[...] values of set for a decrypt caller, in the decoded document so the encoder
+// escapes the plaintext values, before a key is selected or the document converted
+func (f *FileService) open(caller access.Caller, set *source.ChangeSet) (*source.ChangeSet, error) {
+	if f.keyring == nil || !f.canDecrypt(caller) || !bytes.Contains(set.Data, []byte("ENC[")) {
+		return set, nil
+	}
+	var data []byte
+	var err error
+	if convert.Supported(set.Format) {
+		var v interface{}
+		if v, err = convert.Decode(set.Format, set.Data); err == nil {
+			if v, err = f.openValues(v); err == nil {
+				data, err = convert.Encode(set.Format, v)
+			}
+		}
+	} else {
+		// a format that cannot be decoded keeps the plaintext values as they are
+		data, err = f.keyring.Open(set.Data)
+	}
 	if err != nil {
-		return status.Errorf(codes.InvalidArgument, "%s", err)
+		return nil, status.Errorf(codes.Internal, "secret values error %s", err)
+	}
+	cs := &source.ChangeSet{
+		Data:      data,
+		Format:    set.Format,
+		Source:    set.Source,
+		Timestamp: set.Timestamp,
 	}
-	if !exists {
-		if err := os.MkdirAll(dest, os.ModePerm); err != nil {
-			return status.Errorf(codes.InvalidArgument, "MkdirAll error %s %s", dest, err)
+	cs.Checksum = cs.Sum()
+	return cs, nil
+}
+
+// openValues decrypts the ENC[...] values of the strings of v
+func (f *FileService) openValues(v interface{}) (interface{}, error) {
+	var err error
+	switch t := v.(type) {
+	case string:
+		var data []byte
+		if data, err = f.keyring.Open([]byte(t)); err == nil {
+			return string(data), nil
+		}
+	case map[string]interface{}:
+		for k, e := range t {
+			if t[k], err = f.openValues(e); err != nil {
+				break
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			if t[i], err = f.openValues(e); err != nil {
+				break
+			}
 		}
 	}
-	destTmp := fmt.Sprintf("%s.tmp", dest)
-	if err := os.WriteFile(destTmp, data, 0666); err != nil {
-		return status.Errorf(codes.InvalidArgument, "write file error %s %s", dest, err)
+	return v, err
+}
+
+// authorize resolves the caller and the clean path of p then checks the caller holds perm on it,
+// denied requests and allowed writes are logged as audit events
+func (f *FileService) authorize(ctx context.Context, perm access.Permission, p string) (access.Caller, string, error) {
+	caller, err := f.control.Authenticator.Caller(ctx)
+	if err != nil {
+		audit(caller, perm, p, false, err.Error())
+		return caller, "", status.Errorf(codes.Unauthenticated, "invalid credential %s", err)
 	}
-	if err := os.Rename(destTmp, dest); err != nil {
-		return status.Errorf(codes.InvalidArgument, "rename %s to %s error %s", destTmp, dest, err)
+	clean, err := resolve(p)
+	if err != nil {
+		audit(caller, perm, p, false, "path traversal")
+		return caller, "", err
 	}
-	if err := f.memory.Watch(dest); err != nil {
-		return status.Errorf(codes.InvalidArgument, "watch %s error %s", dest, err)
+	if !f.control.Allowed(caller, perm, clean) {
+		audit(caller, perm, clean, false, "denied by policy")
+		return caller, "", status.Errorf(codes.PermissionDenied, "%s %s is not allowed", perm, clean)
 	}
-	return nil
+	if perm == access.Write {
+		audit(caller, perm, clean, true, "")
+	}
+	return caller, clean, nil
 }
 
-// getPaths gets the paths
-func getPaths(root string) ([]string, error) {
-	if err := os.MkdirAll(root, os.ModePerm); err != nil {
-		return nil, err
+// resolve returns the clean path of p relative to the config root, paths escaping the root are rejected
+func resolve(p string) (string, error) {
+	rel := strings.TrimPrefix(path.Clean(strings.TrimPrefix(p, "/")), "/")
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", status.Errorf(codes.InvalidArgument, "path %s is outside of the config root", p)
+	}
+	return rel, nil
+}
+
+// load returns the document at p from memory, a document of the storage missing from memory, after a restart or
+// on a replica catching up, is loaded into it
+func (f *FileService) load(p string) (*source.ChangeSet, error) {
+	set, err := f.memory.Load(p)
+	switch err {
+	case nil:
+		return set, nil
+	case storage.ErrNotFound:
+		return nil, status.Errorf(codes.NotFound, "%s not found", p)
+	default:
+		return nil, status.Errorf(codes.Internal, "read %s error %s", p, err)
+	}
+}
+
+// reveal opens ENC[...] values for decrypt callers, other callers get the stored sealed values, a document
+// not sealed yet is sealed for them
+func (f *FileService) reveal(caller access.Caller, data []byte) ([]byte, error) {
+	if f.keyring == nil {
+		return data, nil
+	}
+	var err error
+	if f.canDecrypt(caller) {
+		data, err = f.keyring.Open(data)
+	} else if !secret.Sealed(data) {
+		data, err = f.keyring.Seal(data)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "secret values error %s", err)
+	}
+	return data, nil
+}
+
+// seal encrypts the ENC[value] markers of data
+func (f *FileService) seal(data []byte) ([]byte, error) {
+	if f.keyring == nil {
+		return data, nil
+	}
+	return f.keyring.Seal(data)
+}
+
+func (f *FileService) canDecrypt(caller access.Caller) bool {
+	if caller.Kind != access.KindService {
+		return false
+	}
+	for _, s := range f.decrypt {
+		if s == caller.Name {
+			return true
+		}
 	}
-	var fs []string
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	return false
+}
+
+func audit(caller access.Caller, perm access.Permission, p string, allowed bool, reason string) {
+	level := logger.InfoLevel
+	if !allowed {
+		level = logger.WarnLevel
+	}
+	logger.Fields(map[string]interface{}{
+		"audit":      "access",
+		"caller":     caller.Name,
+		"kind":       caller.Kind,
+		"permission": perm,
+		"path":       p,
+		"allowed":    allowed,
+		"reason":     reason,
+	}).Logf(level, "%s %s %s allowed=%t", caller.Kind, perm, p, allowed)
+}
+
+// watchTree follows the documents created or removed outside of the service
+func (f *FileService) watchTree(tw storage.TreeWatcher) {
+	for {
+		w, err := tw.WatchTree()
 		if err != nil {
-			return err
+			logger.Errorf("watch %s tree error %s", f.storage, err)
+			time.Sleep(time.Second)
+			continue
 		}
-		if info.IsDir() {
+		// changes may have been missed while the tree was not watched
+		f.sync()
+		for {
+			e, err := w.Next()
+			if err != nil {
+				logger.Errorf("watch %s tree error %s", f.storage, err)
+				break
+			}
+			switch e.Op {
+			case storage.Create:
+				// a document saved over a watched one updates it in place
+				if err := f.memory.Update(e.Path); err != nil {
+					logger.Warnf("watch %s error %s", e.Path, err)
+				}
+			case storage.Remove:
+				f.forget(e.Path)
+			}
+		}
+		_ = w.Stop()
+		time.Sleep(time.Second)
+	}
+}
+
+// sync watches every document of the storage and forgets the ones gone
+func (f *FileService) sync() {
+	paths, err := f.storage.List()
+	if err != nil {
+		logger.Errorf("list %s documents error %s", f.storage, err)
+		return
+	}
+	listed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		listed[p] = true
+	}
+	for _, p := range f.memory.Paths() {
+		if !listed[p] {
+			f.memory.Remove(p)
+		}
+	}
+	if err := f.memory.Watch(paths...); err != nil {
+		logger.Warnf("watch %s documents error %s", f.storage, err)
+	}
+}
+
+// forget removes p, or every document below p when it is a directory, from memory when it is still missing
+// after saveDelay, editors save a document by moving it away or renaming a new file over it and its watchers
+// follow the new version
+func (f *FileService) forget(p string) {
+	for _, watched := range f.memory.Paths() {
+		if watched == p || strings.HasPrefix(watched, p+"/") {
+			watched := watched
+			time.AfterFunc(saveDelay, func() {
+				f.reload(watched)
+			})
+		}
+	}
+}
+
+// reload updates p in memory from the storage, or removes it from memory when it is gone
+func (f *FileService) reload(p string) {
+	switch err := f.memory.Update(p); err {
+	case nil:
+	case storage.ErrNotFound:
+		f.memory.Remove(p)
+	default:
+		logger.Warnf("reload %s error %s", p, err)
+	}
+}
+
+// change makes change on the leader replica then locally, so the caller reads its own change right away
+func (f *FileService) change(ctx context.Context, change *replica.Change) error {
+	defer f.lock(change.Path, change.To)()
+	return f.commit(ctx, change)
+}
+
+// commit makes change like change does, the caller holds the lock of the changed documents
+func (f *FileService) commit(ctx context.Context, change *replica.Change) error {
+	if f.replica != nil {
+		leader, err := f.replica.Leader()
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "find leader error %s", err)
+		}
+		if leader != nil {
+			if err := f.replica.Forward(ctx, leader, change); err != nil {
+				return forwardError(err)
+			}
+			// the next sync from the leader repairs a failed local change
+			if err := f.Apply(change); err != nil {
+				logger.Warnf("%s %s error %s", change.Op, change.Path, err)
+			}
 			return nil
 		}
-		fs = append(fs, filepath.Clean(path))
+	}
+	return applyError(change, f.Apply(change))
+}
+
+// applyError returns the status of an error of Apply
+func applyError(change *replica.Change, err error) error {
+	switch err {
+	case nil:
 		return nil
-	})
+	case storage.ErrNotFound:
+		return status.Errorf(codes.NotFound, "%s not found", change.Path)
+	case storage.ErrExists:
+		return status.Errorf(codes.AlreadyExists, "%s already exists", change.To)
+	default:
+		return status.Errorf(codes.Internal, "%s %s error %s", change.Op, change.Path, err)
+	}
+}
 
+// Apply makes change on the local storage and memory
+func (f *FileService) Apply(change *replica.Change) error {
+	switch change.Op {
+	case replica.OpWrite:
+		defer f.expect(change.Actor, change.Path, (&source.ChangeSet{Data: change.Data}).Sum())()
+		if err := f.storage.Write(change.Path, change.Data); err != nil {
+			return err
+		}
+		return f.memory.Update(change.Path)
+	case replica.OpDelete:
+		defer f.expect(change.Actor, change.Path, "")()
+		if err := f.storage.Delete(change.Path); err != nil {
+			return err
+		}
+		f.memory.Remove(change.Path)
+		return nil
+	case replica.OpRename:
+		if set, err := f.memory.Get(change.Path); err == nil {
+			defer f.expect(change.Actor, change.To, set.Checksum)()
+		}
+		if err := f.storage.Rename(change.Path, change.To); err != nil {
+			return err
+		}
+		f.memory.Remove(change.Path)
+		return f.memory.Update(change.To)
+	}
+	return fmt.Errorf("unknown operation %s", change.Op)
+}
+
+// Checksums returns the checksum of every document in memory
+func (f *FileService) Checksums() map[string]string {
+	checksums := map[string]string{}
+	for _, p := range f.memory.Paths() {
+		if set, err := f.memory.Get(p); err == nil {
+			checksums[p] = set.Checksum
+		}
+	}
+	return checksums
+}
+
+// Data returns the stored content of the document at p
+func (f *FileService) Data(p string) ([]byte, error) {
+	set, err := f.memory.Get(p)
 	if err != nil {
-		return nil, err
+		return nil, storage.ErrNotFound
+	}
+	return set.Data, nil
+}
+
+// lock locks the documents at paths until the returned func is called, empty paths are skipped
+func (f *FileService) lock(paths ...string) func() {
+	var sorted []string
+	for _, p := range paths {
+		if p != "" {
+			sorted = append(sorted, p)
+		}
+	}
+	// a fixed order keeps two renames from waiting on each other
+	sort.Strings(sorted)
+	var keys []string
+	var locks []*documentLock
+	f.Lock()
+	for i, p := range sorted {
+		if i > 0 && p == sorted[i-1] {
+			continue
+		}
+		l, ok := f.locks[p]
+		if !ok {
+			l = &documentLock{}
+			f.locks[p] = l
+		}
+		l.refs++
+		keys = append(keys, p)
+		locks = append(locks, l)
+	}
+	f.Unlock()
+	for _, l := range locks {
+		l.Lock()
+	}
+	return func() {
+		f.Lock()
+		for i, l := range locks {
+			l.Unlock()
+			if l.refs--; l.refs == 0 {
+				delete(f.locks, keys[i])
+			}
+		}
+		f.Unlock()
 	}
-	return fs, err
 }
 
-func exists(p string) (bool, error) {
-	if _, err := os.Stat(p); err != nil {
-		if os.IsNotExist(err) {
-			return false, nil
+// expect attributes the version checksum of p to actor until the returned func is called
+func (f *FileService) expect(actor string, p string, checksum string) func() {
+	k := p + "#" + checksum
+	f.Lock()
+	f.actors[k] = actor
+	f.Unlock()
+	return func() {
+		f.Lock()
+		delete(f.actors, k)
+		f.Unlock()
+	}
+}
+
+// changed records the new version of a document in its history and notifies the change on the leader, a document
+// with plaintext ENC[...] values is sealed first
+func (f *FileService) changed(p string, old *source.ChangeSet, set *source.ChangeSet) {
+	if set != nil && f.keyring != nil && !secret.Sealed(set.Data) {
+		// the sealed version is recorded and notified in its turn
+		if f.sealStored(p, set) == nil {
+			return
 		}
-		return true, nil
 	}
-	return true, nil
+	var checksum string
+	if set != nil {
+		checksum = set.Checksum
+	}
+	f.Lock()
+	actor := f.actors[p+"#"+checksum]
+	ready := f.ready
+	f.Unlock()
+
+	var revision int64
+	if f.history != nil && set != nil {
+		rev, _, err := f.history.Record(p, set.Data, set.Checksum, actor)
+		if err != nil {
+			logger.Errorf("record %s revision error %s", p, err)
+		} else {
+			revision = rev.Revision
+		}
+	}
+	if f.events == nil || !ready || !f.leading() {
+		return
+	}
+	e := &event.Event{
+		Id:       uuid.New().String(),
+		Type:     event.Update,
+		Path:     p,
+		Revision: revision,
+		Actor:    actor,
+		Time:     time.Now().Unix(),
+	}
+	var before, after []byte
+	if old != nil {
+		e.OldChecksum, before = old.Checksum, old.Data
+	} else {
+		e.Type = event.Create
+	}
+	if set != nil {
+		e.NewChecksum, after = set.Checksum, set.Data
+	} else {
+		e.Type = event.Delete
+	}
+	e.Diff.Added, e.Diff.Removed = history.Stat(before, after)
+	f.events.Notify(e)
+}
+
+// leading reports whether the changes are committed here, the followers apply the changes of the leader and
+// do not notify them again
+func (f *FileService) leading() bool {
+	if f.replica == nil {
+		return true
+	}
+	leader, err := f.replica.Leader()
+	if err != nil {
+		// a change notified twice beats a lost one
+		logger.Warnf("find leader error %s", err)
+		return true
+	}
+	return leader == nil
+}
+
+// sealStored writes set sealed, the documents written to the storage directly keep plaintext ENC[...] values
+func (f *FileService) sealStored(p string, set *source.ChangeSet) error {
+	data, err := f.keyring.Seal(set.Data)
+	if err == nil {
+		err = f.Apply(&replica.Change{Op: replica.OpWrite, Path: p, Data: data})
+	}
+	if err != nil {
+		logger.Errorf("seal %s error %s", p, err)
+	}
+	return err
 }
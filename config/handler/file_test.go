@@ -1,40 +1,187 @@
 package handler
 
-import "testing"
+import (
+	"bytes"
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/convert"
+	"github.com/sparrow-community/app/config/secret"
+	"github.com/sparrow-community/app/config/storage"
+	"github.com/sparrow-community/protos/config"
+	"golang.org/x/net/context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
 
-func TestFile_exists(t *testing.T) {
-	type args struct {
-		path string
-	}
+func Test_resolve(t *testing.T) {
 	tests := []struct {
 		name    string
-		args    args
-		want    bool
+		path    string
+		want    string
 		wantErr bool
 	}{
-		{
-			name:    "test1",
-			args:    args{path: "../conf"},
-			want:    true,
-			wantErr: false,
-		},
-		{
-			name:    "test2",
-			args:    args{path: "test"},
-			want:    false,
-			wantErr: false,
-		},
+		{name: "file", path: "micro.json", want: "micro.json"},
+		{name: "nested", path: "/service/micro.yaml", want: "service/micro.yaml"},
+		{name: "inner parent", path: "service/../micro.yaml", want: "micro.yaml"},
+		{name: "root", path: "/", wantErr: true},
+		{name: "parent", path: "../config.key", wantErr: true},
+		{name: "deep parent", path: "service/../../../etc/passwd", wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := exists(tt.args.path)
+			got, err := resolve(tt.path)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("File.exists() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("resolve() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if got != tt.want {
-				t.Errorf("File.exists() = %v, want %v", got, tt.want)
+				t.Errorf("resolve() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestFileService_seal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db.json"), []byte(`{"password": "ENC[s3cret]"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := secret.LoadKeyring(filepath.Join(t.TempDir(), "config.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFileService(s, WithKeyring(keyring))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.Read("db.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secret.Sealed(stored.Data) || bytes.Contains(stored.Data, []byte("s3cret")) {
+		t.Fatalf("stored document not sealed on load: %s", stored.Data)
+	}
+	// reads return the stored sealed form, not a new sealing
+	for i := 0; i < 2; i++ {
+		var response proto.ReadResponse
+		if err := f.Read(context.Background(), &proto.ReadRequest{Path: "db.json"}, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.ChangeSet.Checksum != stored.Checksum || !bytes.Equal(response.ChangeSet.Data, stored.Data) {
+			t.Fatalf("read %d returned %s, want %s", i, response.ChangeSet.Data, stored.Data)
+		}
+	}
+}
+
+func TestFileService_open(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db.yaml"), []byte("password: ENC[s3\"cr\\et]\nport: 5432\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := secret.LoadKeyring(filepath.Join(t.TempDir(), "config.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFileService(s, WithKeyring(keyring, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := f.load("db.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the plaintext is escaped by the encoder of the selected format
+	app := access.Caller{Name: "app", Kind: access.KindService}
+	for path, key := range map[string]string{"db.yaml?format=json": "password", "db.yaml?format=json#password": ""} {
+		q, err := parseQuery(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := f.open(app, set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selected, err := q.apply(opened)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := convert.Decode("json", selected.Data)
+		if err != nil {
+			t.Fatalf("%s is not json: %s", path, selected.Data)
+		}
+		if v, _ = convert.Lookup(v, key); v != `s3"cr\et` {
+			t.Fatalf("%s password = %v", path, v)
+		}
+	}
+
+	// the other callers get the sealed document
+	other, err := f.open(access.Caller{Name: "web", Kind: access.KindService}, set)
+	if err != nil || other != set {
+		t.Fatalf("open() for another caller = %v, %v, want the sealed document", other, err)
+	}
+}
+
+func TestFileService_save(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	if err := os.WriteFile(file, []byte("a: 1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFileService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, updates, cancel, err := f.memory.Subscribe("app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	// give the tree watcher time to start
+	time.Sleep(100 * time.Millisecond)
+
+	// an editor moves the document away then writes the new version
+	if err := os.Rename(file, file+"~"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("a: 2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case set, ok := <-updates:
+			if !ok {
+				t.Fatal("watch closed by the save")
+			}
+			if string(set.Data) == "a: 2" {
+				// the watch outlives the removal check
+				time.Sleep(2 * saveDelay)
+				select {
+				case _, ok := <-updates:
+					if !ok {
+						t.Fatal("watch closed after the save")
+					}
+				default:
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("saved version not received")
+		}
+	}
+}
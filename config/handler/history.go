@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/history"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RevisionsRequest struct {
+	Path string `json:"path"`
+}
+
+type RevisionsResponse struct {
+	// Revisions the latest first, without their data
+	Revisions []*history.Revision `json:"revisions"`
+}
+
+type RevisionRequest struct {
+	Path     string `json:"path"`
+	Revision int64  `json:"revision"`
+}
+
+type RevisionResponse struct {
+	Revision *history.Revision `json:"revision"`
+}
+
+type DiffRequest struct {
+	Path string `json:"path"`
+	From int64  `json:"from"`
+	// To revision compared to From, the current version when 0
+	To int64 `json:"to"`
+	// Context lines around each change, 3 when 0
+	Context int `json:"context"`
+}
+
+type DiffResponse struct {
+	Diff string `json:"diff"`
+}
+
+// HistoryService serves the revisions of the documents, over the application/grpc+json codec only, its
+// messages have no proto
+type HistoryService struct {
+	files *FileService
+}
+
+func NewHistoryService(files *FileService) *HistoryService {
+	return &HistoryService{files: files}
+}
+
+func (h *HistoryService) Revisions(ctx context.Context, request *RevisionsRequest, response *RevisionsResponse) error {
+	_, p, err := h.authorize(ctx, request.Path)
+	if err != nil {
+		return err
+	}
+	response.Revisions, err = h.files.history.List(p)
+	if err != nil {
+		return status.Errorf(codes.Internal, "list %s revisions error %s", p, err)
+	}
+	return nil
+}
+
+func (h *HistoryService) Revision(ctx context.Context, request *RevisionRequest, response *RevisionResponse) error {
+	caller, p, err := h.authorize(ctx, request.Path)
+	if err != nil {
+		return err
+	}
+	response.Revision, err = h.revision(caller, p, request.Revision)
+	return err
+}
+
+// Diff returns the unified diff between two revisions of a document
+func (h *HistoryService) Diff(ctx context.Context, request *DiffRequest, response *DiffResponse) error {
+	caller, p, err := h.authorize(ctx, request.Path)
+	if err != nil {
+		return err
+	}
+	from, err := h.revision(caller, p, request.From)
+	if err != nil {
+		return err
+	}
+	var to []byte
+	if request.To == 0 {
+		set, err := h.files.memory.Get(p)
+		if err != nil {
+			return status.Errorf(codes.NotFound, "%s not found", p)
+		}
+		if to, err = h.files.reveal(caller, set.Data); err != nil {
+			return err
+		}
+	} else {
+		rev, err := h.revision(caller, p, request.To)
+		if err != nil {
+			return err
+		}
+		to = rev.Data
+	}
+	n := request.Context
+	if n <= 0 {
+		n = 3
+	}
+	response.Diff = history.Diff(from.Data, to, n)
+	return nil
+}
+
+func (h *HistoryService) authorize(ctx context.Context, p string) (access.Caller, string, error) {
+	if h.files.history == nil {
+		return access.Anonymous, "", status.Errorf(codes.FailedPrecondition, "history is disabled")
+	}
+	return h.files.authorize(ctx, access.Read, p)
+}
+
+// revision returns revision rev of p with its values revealed for caller
+func (h *HistoryService) revision(caller access.Caller, p string, rev int64) (*history.Revision, error) {
+	r, err := h.files.history.Get(p, rev)
+	if err == history.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "%s revision %d not found", p, rev)
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, "read %s revision %d error %s", p, rev, err)
+	}
+	if r.Data, err = h.files.reveal(caller, r.Data); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
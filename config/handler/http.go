@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sparrow-community/app/config/convert"
+	"github.com/sparrow-community/protos/config"
+	"go-micro.dev/v4/metadata"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxDocumentSize of a document written over http
+const MaxDocumentSize = 4 << 20
+
+// HttpHandler serves the documents over http under Prefix:
+//
+//	GET    {prefix}/api/documents?prefix=&glob=      list the documents
+//	GET    {prefix}/api/documents/{path}?format=&key= read a document, raw=true keeps its template references
+//	PUT    {prefix}/api/documents/{path}              write a valid document
+//	DELETE {prefix}/api/documents/{path}              delete a document
+//	POST   {prefix}/api/rename                        rename a document, body {"from":"","to":""}
+//	GET    {prefix}/api/revisions/{path}?revision=    list the revisions of a document or read one
+//	GET    {prefix}/api/diff/{path}?from=&to=         diff two revisions of a document
+//	GET    {prefix}/api/watch/{path}?format=&key=&raw= stream the versions of a document as server-sent events
+//	GET    {prefix}/ui/                               admin web ui
+//
+// the bearer token is read from the Authorization header
+type HttpHandler struct {
+	Prefix    string
+	files     *FileService
+	documents *DocumentService
+	history   *HistoryService
+	ui        http.Handler
+}
+
+func NewHttpHandler(prefix string, files *FileService, ui fs.FS) *HttpHandler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return &HttpHandler{
+		Prefix:    prefix,
+		files:     files,
+		documents: NewDocumentService(files),
+		history:   NewHistoryService(files),
+		ui:        http.StripPrefix(prefix+"/ui/", http.FileServer(http.FS(ui))),
+	}
+}
+
+func (h *HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, h.Prefix)
+	if p == "" || p == "/" || p == "/ui" {
+		http.Redirect(w, r, h.Prefix+"/ui/", http.StatusFound)
+		return
+	}
+	if strings.HasPrefix(p, "/ui/") {
+		h.ui.ServeHTTP(w, r)
+		return
+	}
+
+	// the bearer token of the request identifies the caller like the grpc metadata does
+	ctx := r.Context()
+	if token := r.Header.Get("Authorization"); token != "" {
+		ctx = metadata.NewContext(ctx, metadata.Metadata{"Authorization": token})
+	}
+	var err error
+	switch route, doc, _ := strings.Cut(strings.TrimPrefix(p, "/api/"), "/"); {
+	case route == "documents" && doc == "" && r.Method == http.MethodGet:
+		err = h.list(ctx, w, r)
+	case route == "documents" && doc != "" && r.Method == http.MethodGet:
+		err = h.read(ctx, w, r, doc)
+	case route == "documents" && doc != "" && r.Method == http.MethodPut:
+		err = h.write(ctx, w, r, doc)
+	case route == "documents" && doc != "" && r.Method == http.MethodDelete:
+		err = h.delete(ctx, w, doc)
+	case route == "rename" && r.Method == http.MethodPost:
+		err = h.rename(ctx, w, r)
+	case route == "revisions" && doc != "" && r.Method == http.MethodGet:
+		err = h.revisions(ctx, w, r, doc)
+	case route == "diff" && doc != "" && r.Method == http.MethodGet:
+		err = h.diff(ctx, w, r, doc)
+	case route == "watch" && doc != "" && r.Method == http.MethodGet:
+		err = h.watch(ctx, w, r, doc)
+	default:
+		err = status.Errorf(codes.NotFound, "%s %s not found", r.Method, r.URL.Path)
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *HttpHandler) list(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var response ListResponse
+	request := &ListRequest{Prefix: r.URL.Query().Get("prefix"), Glob: r.URL.Query().Get("glob")}
+	if err := h.documents.List(ctx, request, &response); err != nil {
+		return err
+	}
+	return writeJSON(w, &response)
+}
+
+func (h *HttpHandler) read(ctx context.Context, w http.ResponseWriter, r *http.Request, doc string) error {
+	q, err := documentQuery(doc, r.URL.Query())
+	if err != nil {
+		return err
+	}
+	var response proto.ReadResponse
+	if err := h.files.Read(ctx, &proto.ReadRequest{Path: q}, &response); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType(response.ChangeSet.Format))
+	w.Header().Set("ETag", strconv.Quote(response.ChangeSet.Checksum))
+	_, err = w.Write(response.ChangeSet.Data)
+	return err
+}
+
+// write rejects documents that do not parse in the format of their extension
+func (h *HttpHandler) write(ctx context.Context, w http.ResponseWriter, r *http.Request, doc string) error {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxDocumentSize))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "read body error %s", err)
+	}
+	format := extension(doc)
+	if convert.Supported(format) {
+		if _, err := convert.Decode(format, data); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid %s document %s", format, err)
+		}
+	}
+	request := &proto.WriteRequest{Path: doc, ChangeSet: &proto.ChangeSet{Data: data, Format: format}}
+	if err := h.files.Write(ctx, request, &wrapperspb.BoolValue{}); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *HttpHandler) delete(ctx context.Context, w http.ResponseWriter, doc string) error {
+	if err := h.documents.Delete(ctx, &DeleteRequest{Path: doc}, &DeleteResponse{}); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *HttpHandler) rename(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var request RenameRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxDocumentSize)).Decode(&request); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid rename request %s", err)
+	}
+	if err := h.documents.Rename(ctx, &request, &RenameResponse{}); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *HttpHandler) revisions(ctx context.Context, w http.ResponseWriter, r *http.Request, doc string) error {
+	v := r.URL.Query().Get("revision")
+	if v == "" {
+		var response RevisionsResponse
+		if err := h.history.Revisions(ctx, &RevisionsRequest{Path: doc}, &response); err != nil {
+			return err
+		}
+		return writeJSON(w, &response)
+	}
+	rev, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid revision %s", v)
+	}
+	var response RevisionResponse
+	if err := h.history.Revision(ctx, &RevisionRequest{Path: doc, Revision: rev}, &response); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType(extension(doc)))
+	_, err = w.Write(response.Revision.Data)
+	return err
+}
+
+func (h *HttpHandler) diff(ctx context.Context, w http.ResponseWriter, r *http.Request, doc string) error {
+	request := &DiffRequest{Path: doc}
+	for name, v := range map[string]*int64{"from": &request.From, "to": &request.To} {
+		if s := r.URL.Query().Get(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid %s revision %s", name, s)
+			}
+			*v = n
+		}
+	}
+	var response DiffResponse
+	if err := h.history.Diff(ctx, request, &response); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	_, err := w.Write([]byte(response.Diff))
+	return err
+}
+
+// watch streams the versions of a document as "change" events, the data of an event is a json change set
+func (h *HttpHandler) watch(ctx context.Context, w http.ResponseWriter, r *http.Request, doc string) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "streaming is not supported")
+	}
+	q, err := documentQuery(doc, r.URL.Query())
+	if err != nil {
+		return err
+	}
+	stream := &eventStream{ctx: ctx, w: w, flusher: flusher}
+	err = h.files.Watch(ctx, &proto.WatchRequest{Path: q}, stream)
+	if err != nil && stream.started {
+		// the status line is sent already, report the error as the last event
+		s, _ := status.FromError(err)
+		_ = stream.event("error", map[string]string{"code": s.Code().String(), "message": s.Message()})
+		return nil
+	}
+	return err
+}
+
+// eventStream sends the responses of a watch as server-sent events
+type eventStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func (s *eventStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *eventStream) SendMsg(m interface{}) error {
+	return s.event("change", m)
+}
+
+func (s *eventStream) RecvMsg(interface{}) error {
+	return io.EOF
+}
+
+func (s *eventStream) Close() error {
+	return nil
+}
+
+func (s *eventStream) Send(m *proto.WatchResponse) error {
+	return s.event("change", m.ChangeSet)
+}
+
+func (s *eventStream) event(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// documentQuery returns the read path of doc with the format, raw and key parameters of v, escaped so
+// parseQuery reads them back as they are
+func documentQuery(doc string, v url.Values) (string, error) {
+	if strings.ContainsAny(doc, "?#") {
+		return "", status.Errorf(codes.InvalidArgument, "document %s contains ? or #", doc)
+	}
+	params := url.Values{}
+	if format := v.Get("format"); format != "" {
+		params.Set("format", format)
+	}
+	if v.Get("raw") == "true" {
+		params.Set("raw", "true")
+	}
+	q := doc
+	if len(params) > 0 {
+		q += "?" + params.Encode()
+	}
+	if key := v.Get("key"); key != "" {
+		q += "#" + url.PathEscape(key)
+	}
+	return q, nil
+}
+
+// extension is the format of a document stored at p
+func extension(p string) string {
+	if i := strings.LastIndex(path.Base(p), "."); i >= 0 {
+		return path.Base(p)[i+1:]
+	}
+	return "bytes"
+}
+
+func contentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "yaml", "yml":
+		return "application/yaml"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the status of err with its http status code
+func writeError(w http.ResponseWriter, err error) {
+	s, _ := status.FromError(err)
+	code := http.StatusInternalServerError
+	switch s.Code() {
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.AlreadyExists:
+		code = http.StatusConflict
+	case codes.Unimplemented:
+		code = http.StatusNotImplemented
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"code": s.Code().String(), "message": s.Message()})
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/history"
+	"github.com/sparrow-community/app/config/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHttpHandler(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := history.Open(filepath.Join(t.TempDir(), "history.db"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	fs, err := NewFileService(s, WithHistory(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHttpHandler("/config", fs, fstest.MapFS{"index.html": {Data: []byte("ui")}}))
+	defer srv.Close()
+
+	do := func(method string, p string, body string) (int, string) {
+		req, err := http.NewRequest(method, srv.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var b strings.Builder
+		_, _ = io.Copy(&b, resp.Body)
+		return resp.StatusCode, b.String()
+	}
+
+	if code, _ := do(http.MethodPut, "/config/api/documents/app.json", `{"a":`); code != http.StatusBadRequest {
+		t.Errorf("invalid document status %d", code)
+	}
+	for _, v := range []string{`{"a":{"b":1}}`, `{"a":{"b":2}}`} {
+		if code, body := do(http.MethodPut, "/config/api/documents/app.json", v); code != http.StatusNoContent {
+			t.Fatalf("write status %d %s", code, body)
+		}
+	}
+	if code, body := do(http.MethodGet, "/config/api/documents/app.json?key=a.b", ""); code != http.StatusOK || body != "2" {
+		t.Errorf("read status %d %s", code, body)
+	}
+	// the parameters are escaped on their way to the read path
+	if code, body := do(http.MethodPut, "/config/api/documents/keys.json", `{"x#y":{"z&raw=true":5}}`); code != http.StatusNoContent {
+		t.Fatalf("write status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/config/api/documents/keys.json?format=json&key=x%23y.z%26raw%3Dtrue", ""); code != http.StatusOK || body != "5" {
+		t.Errorf("read escaped key status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/config/api/documents/app.json%3Fraw=true", ""); code != http.StatusBadRequest {
+		t.Errorf("read document with ? status %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/config/api/documents", ""); code != http.StatusOK || !strings.Contains(body, `"path":"app.json"`) {
+		t.Errorf("list status %d %s", code, body)
+	}
+	// the file watcher may record the second revision after the write returned
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		code, body := do(http.MethodGet, "/config/api/revisions/app.json", "")
+		if code == http.StatusOK && strings.Contains(body, `"revision":2`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("revisions status %d %s", code, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code, body := do(http.MethodGet, "/config/api/diff/app.json?from=1", ""); code != http.StatusOK || body != "@@ -1,1 +1,1 @@\n-{\"a\":{\"b\":1}}\n+{\"a\":{\"b\":2}}\n" {
+		t.Errorf("diff status %d %s", code, body)
+	}
+	if code, _ := do(http.MethodDelete, "/config/api/documents/app.json", ""); code != http.StatusNoContent {
+		t.Errorf("delete status %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/config/api/documents/app.json", ""); code != http.StatusNotFound {
+		t.Errorf("read deleted status %d", code)
+	}
+	if code, body := do(http.MethodGet, "/config/ui/", ""); code != http.StatusOK || body != "ui" {
+		t.Errorf("ui status %d %s", code, body)
+	}
+}
@@ -3,25 +3,62 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"github.com/sparrow-community/app/config/storage"
 	"go-micro.dev/v4/config/source"
-	"go-micro.dev/v4/config/source/file"
 	"strings"
 	"sync"
 	"time"
 )
 
+// Memory keeps the latest change set of every watched document of a storage
 type Memory struct {
 	sync.RWMutex
 	exit    chan bool
-	sources map[string]*fileSource
+	storage storage.Storage
+	sources map[string]*document
+	changed func(path string, old *source.ChangeSet, set *source.ChangeSet)
 }
 
-type fileSource struct {
-	source source.Source
-	set    *source.ChangeSet
+type document struct {
+	set         *source.ChangeSet
+	exit        chan bool
+	subscribers map[chan *source.ChangeSet]bool
 }
 
-func (m *Memory) watch(path string, fs *fileSource) {
+// publish sends set to the subscribers, replacing a change set they did not receive yet, the memory lock must be held,
+// it returns the previous change set and whether set differs from it
+func (d *document) publish(set *source.ChangeSet) (*source.ChangeSet, bool) {
+	old := d.set
+	changed := old == nil || old.Checksum != set.Checksum
+	d.set = set
+	for ch := range d.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- set
+	}
+	return old, changed
+}
+
+// OnChange calls fn, outside of the memory lock, with the previous and the new version of a changed document,
+// old is nil for a new document and set is nil for a removed one
+func (m *Memory) OnChange(fn func(path string, old *source.ChangeSet, set *source.ChangeSet)) {
+	m.Lock()
+	defer m.Unlock()
+	m.changed = fn
+}
+
+func (m *Memory) notify(path string, old *source.ChangeSet, set *source.ChangeSet, changed bool) {
+	m.RLock()
+	fn := m.changed
+	m.RUnlock()
+	if changed && fn != nil {
+		fn(path, old, set)
+	}
+}
+
+func (m *Memory) watch(path string, doc *document) {
 	watch := func(path string, w source.Watcher) error {
 		for {
 			cs, err := w.Next()
@@ -29,15 +66,33 @@ func (m *Memory) watch(path string, fs *fileSource) {
 				return err
 			}
 			m.Lock()
-			m.sources[path].set = cs
+			// a removed document is not published anymore
+			if m.sources[path] != doc {
+				m.Unlock()
+				return errors.New("removed")
+			}
+			old, changed := doc.publish(cs)
 			m.Unlock()
+			m.notify(path, old, cs, changed)
+		}
+	}
+
+	// wait returns false once the document is removed
+	wait := func() bool {
+		select {
+		case <-doc.exit:
+			return false
+		case <-time.After(time.Second):
+			return true
 		}
 	}
 
 	for {
-		w, err := fs.source.Watch()
+		w, err := m.storage.Watch(path)
 		if err != nil {
-			time.Sleep(time.Second)
+			if !wait() {
+				return
+			}
 			continue
 		}
 		done := make(chan bool)
@@ -45,15 +100,16 @@ func (m *Memory) watch(path string, fs *fileSource) {
 			select {
 			case <-done:
 			case <-m.exit:
+			case <-doc.exit:
 			}
 			_ = w.Stop()
 		}()
 
-		if err := watch(path, w); err != nil {
-			time.Sleep(time.Second)
-		}
-
+		err = watch(path, w)
 		close(done)
+		if err != nil && !wait() {
+			return
+		}
 	}
 }
 
@@ -61,23 +117,9 @@ func (m *Memory) Watch(paths ...string) error {
 	var errs []string
 
 	for _, path := range paths {
-		s := file.NewSource(
-			file.WithPath(path),
-			source.WithEncoder(&BytesEncoder{}),
-		)
-		if _, ok := m.sources[path]; ok {
-			continue
-		}
-		set, err := s.Read()
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("error loading s %s: %v", s, err))
-			continue
+		if err := m.load(path); err != nil {
+			errs = append(errs, fmt.Sprintf("error loading %s %s: %v", m.storage, path, err))
 		}
-		fs := &fileSource{source: s, set: set}
-		m.Lock()
-		m.sources[path] = fs
-		m.Unlock()
-		go m.watch(path, fs)
 	}
 
 	if len(errs) > 0 {
@@ -86,30 +128,127 @@ func (m *Memory) Watch(paths ...string) error {
 	return nil
 }
 
-func (m *Memory) Get(path string) (*source.ChangeSet, error) {
-	if fs, ok := m.sources[path]; ok {
-		return fs.set, nil
+// Load returns the change set of path, a document of the storage not watched yet is read and watched
+func (m *Memory) Load(path string) (*source.ChangeSet, error) {
+	if set, err := m.Get(path); err == nil {
+		return set, nil
+	}
+	if err := m.load(path); err != nil {
+		return nil, err
 	}
-	return nil, errors.New(fmt.Sprintf("not wartch %s", path))
+	return m.Get(path)
 }
 
-// BytesEncoder .
-type BytesEncoder struct{}
+// load reads and watches path unless it is already watched
+func (m *Memory) load(path string) error {
+	m.RLock()
+	_, ok := m.sources[path]
+	m.RUnlock()
+	if ok {
+		return nil
+	}
+	set, err := m.storage.Read(path)
+	if err != nil {
+		return err
+	}
+	doc := &document{set: set, exit: make(chan bool), subscribers: map[chan *source.ChangeSet]bool{}}
+	m.Lock()
+	if _, ok := m.sources[path]; ok {
+		m.Unlock()
+		return nil
+	}
+	m.sources[path] = doc
+	m.Unlock()
+	m.notify(path, nil, set, true)
+	go m.watch(path, doc)
+	return nil
+}
+
+// Update reloads path from the storage, so a write is visible before its watcher reports it
+func (m *Memory) Update(path string) error {
+	set, err := m.storage.Read(path)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	doc, ok := m.sources[path]
+	var old *source.ChangeSet
+	changed := false
+	if ok {
+		old, changed = doc.publish(set)
+	}
+	m.Unlock()
+	if !ok {
+		return m.Watch(path)
+	}
+	m.notify(path, old, set, changed)
+	return nil
+}
 
-func (b BytesEncoder) Encode(_ interface{}) ([]byte, error) {
-	panic("not support for encoding")
+// Remove forgets path, stops its watcher and closes its subscriptions
+func (m *Memory) Remove(path string) {
+	m.Lock()
+	doc, ok := m.sources[path]
+	delete(m.sources, path)
+	var last *source.ChangeSet
+	if ok {
+		for ch := range doc.subscribers {
+			close(ch)
+		}
+		doc.subscribers = nil
+		last = doc.set
+	}
+	m.Unlock()
+	if ok {
+		close(doc.exit)
+		m.notify(path, last, nil, true)
+	}
+}
+
+// Subscribe returns the current change set of path and a channel of its next ones,
+// the channel only keeps the latest change set and is closed when path is removed
+func (m *Memory) Subscribe(path string) (*source.ChangeSet, <-chan *source.ChangeSet, func(), error) {
+	m.Lock()
+	defer m.Unlock()
+	doc, ok := m.sources[path]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("not watched %s", path)
+	}
+	ch := make(chan *source.ChangeSet, 1)
+	doc.subscribers[ch] = true
+	cancel := func() {
+		m.Lock()
+		defer m.Unlock()
+		if doc.subscribers[ch] {
+			delete(doc.subscribers, ch)
+		}
+	}
+	return doc.set, ch, cancel, nil
 }
 
-func (b BytesEncoder) Decode(_ []byte, _ interface{}) error {
-	panic("not support for decoding")
+func (m *Memory) Get(path string) (*source.ChangeSet, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if doc, ok := m.sources[path]; ok {
+		return doc.set, nil
+	}
+	return nil, fmt.Errorf("not watched %s", path)
 }
 
-func (b BytesEncoder) String() string {
-	return "bytes"
+// Paths returns every watched path
+func (m *Memory) Paths() []string {
+	m.RLock()
+	defer m.RUnlock()
+	paths := make([]string, 0, len(m.sources))
+	for p := range m.sources {
+		paths = append(paths, p)
+	}
+	return paths
 }
 
-func NewMemory() *Memory {
+func NewMemory(s storage.Storage) *Memory {
 	return &Memory{
-		sources: map[string]*fileSource{},
+		storage: s,
+		sources: map[string]*document{},
 	}
 }
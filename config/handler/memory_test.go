@@ -3,16 +3,21 @@ package handler
 import (
 	"encoding/json"
 	"github.com/ghodss/yaml"
+	"github.com/sparrow-community/app/config/storage"
 	"strings"
 	"testing"
 )
 
 func TestMemory_Watch(t *testing.T) {
-	paths, err := getPaths("../conf")
+	s, err := storage.NewFile("../conf")
 	if err != nil {
 		t.Fatal(err)
 	}
-	m := NewMemory()
+	paths, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory(s)
 	err = m.Watch(paths...)
 	if err != nil {
 		t.Fatal(err)
@@ -25,11 +30,15 @@ func TestMemory_Watch(t *testing.T) {
 }
 
 func TestMemory_Get(t *testing.T) {
-	paths, err := getPaths("../conf")
+	s, err := storage.NewFile("../conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := s.List()
 	if err != nil {
 		t.Fatal(err)
 	}
-	newMemory := NewMemory()
+	newMemory := NewMemory(s)
 	err = newMemory.Watch(paths...)
 	if err != nil {
 		t.Fatal(err)
@@ -60,3 +69,79 @@ func TestMemory_Get(t *testing.T) {
 		}
 	}
 }
+
+func TestMemory_Load(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory(s)
+	if _, err := m.Load("app.yaml"); err != storage.ErrNotFound {
+		t.Fatalf("Load() missing error = %v, want not found", err)
+	}
+	// a document written to the storage after the memory loaded
+	if err := s.Write("app.yaml", []byte("a: 1")); err != nil {
+		t.Fatal(err)
+	}
+	if set, err := m.Load("app.yaml"); err != nil || string(set.Data) != "a: 1" {
+		t.Fatalf("Load() = %v, %v", set, err)
+	}
+	if _, err := m.Get("app.yaml"); err != nil {
+		t.Fatalf("Get() after Load() error %v", err)
+	}
+}
+
+func TestMemory_Remove(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("micro.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory(s)
+	if err := m.Watch("micro.json"); err != nil {
+		t.Fatal(err)
+	}
+	m.Remove("micro.json")
+	if _, err := m.Get("micro.json"); err == nil {
+		t.Fatal("removed path is still watched")
+	}
+	if len(m.Paths()) != 0 {
+		t.Fatalf("Paths() = %v", m.Paths())
+	}
+}
+
+func TestMemory_Subscribe(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("micro.json", []byte(`{"name": "a"}`)); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory(s)
+	if err := m.Watch("micro.json"); err != nil {
+		t.Fatal(err)
+	}
+	set, updates, cancel, err := m.Subscribe("micro.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if string(set.Data) != `{"name": "a"}` {
+		t.Fatalf("Subscribe() set = %s", set.Data)
+	}
+	if err := s.Write("micro.json", []byte(`{"name": "b"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Update("micro.json"); err != nil {
+		t.Fatal(err)
+	}
+	if next := <-updates; string(next.Data) != `{"name": "b"}` {
+		t.Fatalf("next set = %s", next.Data)
+	}
+	m.Remove("micro.json")
+	for range updates {
+	}
+}
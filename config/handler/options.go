@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/event"
+	"github.com/sparrow-community/app/config/history"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/secret"
+)
+
+// Options of a FileService
+type Options struct {
+	// Keyring seals ENC[...] values, values are served as stored without it
+	Keyring *secret.Keyring
+	// Decrypt services allowed to read decrypted values
+	Decrypt []string
+	// Control checks the callers permissions, every caller is allowed everything without it
+	Control *access.Control
+	// History records the revisions of the documents
+	History *history.History
+	// Replica forwards the changes to the leader replica, changes are made locally without it
+	Replica *replica.Replica
+	// Events notifies the changes of the documents
+	Events *event.Notifier
+}
+
+type Option func(o *Options)
+
+func WithKeyring(keyring *secret.Keyring, decrypt ...string) Option {
+	return func(o *Options) {
+		o.Keyring = keyring
+		o.Decrypt = decrypt
+	}
+}
+
+func WithControl(control *access.Control) Option {
+	return func(o *Options) {
+		o.Control = control
+	}
+}
+
+func WithHistory(h *history.History) Option {
+	return func(o *Options) {
+		o.History = h
+	}
+}
+
+func WithReplica(r *replica.Replica) Option {
+	return func(o *Options) {
+		o.Replica = r
+	}
+}
+
+func WithEvents(n *event.Notifier) Option {
+	return func(o *Options) {
+		o.Events = n
+	}
+}
+
+func newOptions(opts ...Option) (Options, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Control == nil {
+		control, err := access.NewControl(access.NewAuthenticator(nil))
+		if err != nil {
+			return o, err
+		}
+		control.Disabled = true
+		o.Control = control
+	}
+	return o, nil
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/convert"
+	"go-micro.dev/v4/config/source"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"strings"
+)
+
+// query of a Read or Watch path, like app.yaml?format=json#database.dsn for the database.dsn value of app.yaml as json,
+// app.yaml?raw=true returns the document without resolving its references, the key is path escaped when it
+// contains a reserved character
+type query struct {
+	path string
+	// key dot separated path of a value, the whole document when empty
+	key string
+	// format of the returned value, the document format when empty
+	format string
+	// raw keeps the template references of the document
+	raw bool
+}
+
+func parseQuery(p string) (query, error) {
+	p, escaped, _ := strings.Cut(p, "#")
+	key, err := url.PathUnescape(escaped)
+	if err != nil {
+		return query{}, status.Errorf(codes.InvalidArgument, "key %s error %s", escaped, err)
+	}
+	p, raw, _ := strings.Cut(p, "?")
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return query{}, status.Errorf(codes.InvalidArgument, "query %s error %s", raw, err)
+	}
+	q := query{path: p, key: key, format: values.Get("format"), raw: values.Get("raw") == "true"}
+	if q.format != "" && !convert.Supported(q.format) {
+		return query{}, status.Errorf(codes.InvalidArgument, "unsupported format %s", q.format)
+	}
+	return q, nil
+}
+
+// apply returns the change set of the selected value of set, set itself when nothing is selected
+func (q query) apply(set *source.ChangeSet) (*source.ChangeSet, error) {
+	if q.key == "" && q.format == "" {
+		return set, nil
+	}
+	format := q.format
+	if format == "" {
+		format = set.Format
+	}
+	data, err := convert.Select(set.Data, set.Format, format, q.key)
+	if err == convert.ErrKeyNotFound {
+		return nil, status.Errorf(codes.NotFound, "key %s not found in %s", q.key, q.path)
+	} else if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "select %s of %s error %s", q.key, q.path, err)
+	}
+	cs := &source.ChangeSet{
+		Data:      data,
+		Format:    format,
+		Source:    set.Source,
+		Timestamp: set.Timestamp,
+	}
+	cs.Checksum = cs.Sum()
+	return cs, nil
+}
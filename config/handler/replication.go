@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/replica"
+	"go-micro.dev/v4/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// ReplicationService serves the changes forwarded by the followers and the snapshots they sync from, the
+// replicas call it with the application/grpc+json codec, its messages have no proto
+type ReplicationService struct {
+	files *FileService
+}
+
+func NewReplicationService(files *FileService) *ReplicationService {
+	return &ReplicationService{files: files}
+}
+
+// Apply makes a change forwarded by a follower, on the leader only, forwarding it again would loop between replicas
+// that disagree on the leader
+func (r *ReplicationService) Apply(ctx context.Context, request *replica.Change, _ *replica.ApplyResponse) error {
+	if err := r.authorize(ctx); err != nil {
+		return err
+	}
+	p, err := resolve(request.Path)
+	if err != nil {
+		return err
+	}
+	request.Path = p
+	if request.Op == replica.OpRename {
+		if request.To, err = resolve(request.To); err != nil {
+			return err
+		}
+	}
+	leader, err := r.files.replica.Leader()
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "find leader error %s", err)
+	}
+	if leader != nil {
+		return status.Errorf(codes.Unavailable, "%s is the leader", leader.Id)
+	}
+	defer r.files.lock(request.Path, request.To)()
+	return applyError(request, r.files.Apply(request))
+}
+
+// Snapshot returns the documents that differ from the follower ones
+func (r *ReplicationService) Snapshot(ctx context.Context, request *replica.SnapshotRequest, response *replica.SnapshotResponse) error {
+	if err := r.authorize(ctx); err != nil {
+		return err
+	}
+	snapshot, err := replica.Snapshot(r.files, request)
+	if err != nil {
+		return status.Errorf(codes.Internal, "snapshot error %s", err)
+	}
+	*response = *snapshot
+	return nil
+}
+
+func (r *ReplicationService) authorize(ctx context.Context) error {
+	if r.files.replica == nil {
+		return status.Errorf(codes.FailedPrecondition, "replication is disabled")
+	}
+	if err := r.files.replica.Authorize(ctx); err != nil {
+		return status.Errorf(codes.PermissionDenied, "%s", err)
+	}
+	return nil
+}
+
+// forwardError returns the status of an error of the leader
+func forwardError(err error) error {
+	e := errors.FromError(err)
+	switch e.Code {
+	case http.StatusBadRequest:
+		return status.Errorf(codes.InvalidArgument, "%s", e.Detail)
+	case http.StatusNotFound:
+		return status.Errorf(codes.NotFound, "%s", e.Detail)
+	case http.StatusConflict:
+		return status.Errorf(codes.AlreadyExists, "%s", e.Detail)
+	case http.StatusPreconditionFailed:
+		return status.Errorf(codes.FailedPrecondition, "%s", e.Detail)
+	}
+	return status.Errorf(codes.Unavailable, "forward to leader error %s", err)
+}
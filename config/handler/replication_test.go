@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/storage"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/registry"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestReplication_Merge(t *testing.T) {
+	newFileService := func() *FileService {
+		s, err := storage.NewFile(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs, err := NewFileService(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	}
+	leader, follower := newFileService(), newFileService()
+
+	for _, c := range []*replica.Change{
+		{Op: replica.OpWrite, Path: "app.yaml", Data: []byte("a: 1")},
+		{Op: replica.OpWrite, Path: "db/main.yaml", Data: []byte("dsn: x")},
+		{Op: replica.OpRename, Path: "db/main.yaml", To: "db/primary.yaml"},
+	} {
+		if err := leader.Apply(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := follower.Apply(&replica.Change{Op: replica.OpWrite, Path: "gone.yaml", Data: []byte("b: 2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := replica.Snapshot(leader, &replica.SnapshotRequest{Checksums: follower.Checksums()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Merge(follower, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	want, got := leader.Checksums(), follower.Checksums()
+	if len(got) != len(want) || len(got) != 2 {
+		t.Fatalf("follower documents %v, leader documents %v", got, want)
+	}
+	for p, checksum := range want {
+		if got[p] != checksum {
+			t.Errorf("%s checksum %s, leader %s", p, got[p], checksum)
+		}
+	}
+
+	snapshot, err = replica.Snapshot(leader, &replica.SnapshotRequest{Checksums: follower.Checksums()})
+	if err != nil || len(snapshot.Documents) != 0 {
+		t.Fatalf("synced snapshot %+v error %v", snapshot, err)
+	}
+}
+
+func TestReplicationService_Apply(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.NewMemoryRegistry()
+	// no client, a forwarded change would panic
+	rep := replica.New("config", "b", "secret", time.Second, nil, reg)
+	fs, err := NewFileService(s, WithReplica(rep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReplicationService(fs)
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{replica.SecretKey: "secret"})
+
+	if err := r.Apply(ctx, &replica.Change{Op: replica.OpWrite, Path: "app.yaml", Data: []byte("a: 1")}, &replica.ApplyResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if set, err := s.Read("app.yaml"); err != nil || string(set.Data) != "a: 1" {
+		t.Fatalf("leader applied %v error %v", set, err)
+	}
+
+	// an older replica is the leader
+	err = reg.Register(&registry.Service{Name: "config", Nodes: []*registry.Node{{
+		Id:       "config-a",
+		Address:  "a:8080",
+		Metadata: map[string]string{"server": "grpc", replica.StartedKey: "1"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Apply(ctx, &replica.Change{Op: replica.OpWrite, Path: "app.yaml", Data: []byte("a: 2")}, &replica.ApplyResponse{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("follower Apply() error = %v, want unavailable", err)
+	}
+	if set, err := s.Read("app.yaml"); err != nil || string(set.Data) != "a: 1" {
+		t.Fatalf("follower applied %v error %v", set, err)
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/secret"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RotateRequest struct{}
+
+type RotateResponse struct {
+	// Key id of the new primary key
+	Key string `json:"key"`
+	// Paths of the re-encrypted documents
+	Paths []string `json:"paths"`
+}
+
+// SecretService manage the key of ENC[...] values, it has no proto, call it with the application/grpc+json codec
+type SecretService struct {
+	files *FileService
+}
+
+func NewSecretService(files *FileService) *SecretService {
+	return &SecretService{files: files}
+}
+
+// Rotate adds a new key, re-encrypts every document with it then drops the previous keys
+func (s *SecretService) Rotate(ctx context.Context, _ *RotateRequest, response *RotateResponse) error {
+	caller, err := s.files.control.Authenticator.Caller(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "invalid credential %s", err)
+	}
+	if !s.files.canDecrypt(caller) {
+		return status.Errorf(codes.PermissionDenied, "rotate key is not allowed")
+	}
+	keyring := s.files.keyring
+	if keyring == nil {
+		return status.Errorf(codes.FailedPrecondition, "no secret key")
+	}
+	// the replicas share the key file, only the leader rotates it
+	if s.files.replica != nil {
+		if leader, err := s.files.replica.Leader(); err != nil {
+			return status.Errorf(codes.Unavailable, "find leader error %s", err)
+		} else if leader != nil {
+			return status.Errorf(codes.FailedPrecondition, "rotate the key on the leader %s", leader.Address)
+		}
+	}
+	id, err := keyring.Rotate()
+	if err != nil {
+		return status.Errorf(codes.Internal, "rotate key error %s", err)
+	}
+	paths, err := s.files.storage.List()
+	if err != nil {
+		return status.Errorf(codes.Internal, "list documents error %s", err)
+	}
+	for _, p := range paths {
+		resealed, err := s.reseal(ctx, keyring, caller.Name, p)
+		if err != nil {
+			return err
+		}
+		if resealed {
+			response.Paths = append(response.Paths, p)
+		}
+	}
+	if err := keyring.Prune(); err != nil {
+		return status.Errorf(codes.Internal, "drop previous keys error %s", err)
+	}
+	response.Key = id
+	return nil
+}
+
+// reseal re-encrypts the document at p under the lock of its writes, so a concurrent write is not overwritten
+func (s *SecretService) reseal(ctx context.Context, keyring *secret.Keyring, actor string, p string) (bool, error) {
+	defer s.files.lock(p)()
+	set, err := s.files.storage.Read(p)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "read %s error %s", p, err)
+	}
+	sealed, err := keyring.Reseal(set.Data)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "re-encrypt %s error %s", p, err)
+	}
+	if bytes.Equal(sealed, set.Data) {
+		return false, nil
+	}
+	if err := s.files.commit(ctx, &replica.Change{Op: replica.OpWrite, Path: p, Data: sealed, Actor: actor}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
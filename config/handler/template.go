@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/sparrow-community/app/config/access"
+	"github.com/sparrow-community/app/config/storage"
+	"github.com/sparrow-community/app/config/template"
+	"go-micro.dev/v4/config/source"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// interpolate returns set of p with its references resolved for caller, and the paths of the documents it references,
+// a caller only resolves references to the documents it can read
+func (f *FileService) interpolate(caller access.Caller, p string, set *source.ChangeSet) (*source.ChangeSet, []string, error) {
+	if !template.Has(set.Data) {
+		return set, nil, nil
+	}
+	r := &template.Resolver{
+		Load: func(ref string) (*source.ChangeSet, error) {
+			clean, err := resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			if !f.control.Allowed(caller, access.Read, clean) {
+				return nil, fmt.Errorf("read %s is not allowed", clean)
+			}
+			set, err := f.memory.Load(clean)
+			if err != nil {
+				return nil, storage.ErrNotFound
+			}
+			return set, nil
+		},
+	}
+	data, deps, err := r.Resolve(p, set.Format, set.Data)
+	for i, dep := range deps {
+		deps[i], _ = resolve(dep)
+	}
+	if err != nil {
+		return nil, deps, status.Errorf(codes.FailedPrecondition, "resolve %s error %s", p, err)
+	}
+	cs := &source.ChangeSet{
+		Data:      data,
+		Format:    set.Format,
+		Source:    set.Source,
+		Timestamp: set.Timestamp,
+	}
+	cs.Checksum = cs.Sum()
+	return cs, deps, nil
+}
+
+// references notifies the changes of the documents referenced by a watched document
+type references struct {
+	memory  *Memory
+	changed chan struct{}
+	// subscriptions by path
+	subscriptions map[string]*subscription
+}
+
+type subscription struct {
+	stop func()
+	// ended is closed when the document is removed
+	ended chan struct{}
+}
+
+func newReferences(m *Memory) *references {
+	return &references{
+		memory:        m,
+		changed:       make(chan struct{}, 1),
+		subscriptions: map[string]*subscription{},
+	}
+}
+
+// watch subscribes to the changes of paths and unsubscribes from the other documents,
+// a removed document is subscribed again once it exists
+func (r *references) watch(paths []string) {
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+		if s, ok := r.subscriptions[p]; ok {
+			select {
+			case <-s.ended:
+				s.stop()
+				delete(r.subscriptions, p)
+			default:
+				continue
+			}
+		}
+		if s := r.subscribe(p); s != nil {
+			r.subscriptions[p] = s
+		}
+	}
+	for p, s := range r.subscriptions {
+		if !keep[p] {
+			s.stop()
+			delete(r.subscriptions, p)
+		}
+	}
+}
+
+func (r *references) subscribe(p string) *subscription {
+	_, updates, cancel, err := r.memory.Subscribe(p)
+	if err != nil {
+		return nil
+	}
+	done := make(chan struct{})
+	s := &subscription{ended: make(chan struct{})}
+	var once sync.Once
+	s.stop = func() {
+		once.Do(func() {
+			cancel()
+			close(done)
+		})
+	}
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-updates:
+				if !ok {
+					close(s.ended)
+				}
+				select {
+				case r.changed <- struct{}{}:
+				default:
+				}
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return s
+}
+
+func (r *references) close() {
+	for _, s := range r.subscriptions {
+		s.stop()
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"github.com/sparrow-community/app/config/replica"
+	"github.com/sparrow-community/app/config/storage"
+	"github.com/sparrow-community/protos/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type watchStream struct {
+	proto.Source_WatchStream
+	ctx       context.Context
+	responses chan *proto.WatchResponse
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(m *proto.WatchResponse) error {
+	s.responses <- m
+	return nil
+}
+
+func TestFileService_interpolate(t *testing.T) {
+	s, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewFileService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(p string, data string) {
+		if err := fs.Apply(&replica.Change{Op: replica.OpWrite, Path: p, Data: []byte(data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("common.yaml", "redis:\n  addr: localhost:6379\n")
+	write("app.yaml", "addr: ${ref:common.yaml#redis.addr}\n")
+	write("a.yaml", "v: ${ref:b.yaml#v}\n")
+	write("b.yaml", "v: ${ref:a.yaml#v}\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var read proto.ReadResponse
+	if err := fs.Read(ctx, &proto.ReadRequest{Path: "app.yaml"}, &read); err != nil || string(read.ChangeSet.Data) != "addr: localhost:6379\n" {
+		t.Fatalf("read %v error %v", read.ChangeSet, err)
+	}
+	if err := fs.Read(ctx, &proto.ReadRequest{Path: "app.yaml?raw=true"}, &read); err != nil || string(read.ChangeSet.Data) != "addr: ${ref:common.yaml#redis.addr}\n" {
+		t.Fatalf("raw read %v error %v", read.ChangeSet, err)
+	}
+	if err := fs.Read(ctx, &proto.ReadRequest{Path: "a.yaml"}, &read); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("cycle read error %v", err)
+	}
+
+	stream := &watchStream{ctx: ctx, responses: make(chan *proto.WatchResponse, 10)}
+	go func() {
+		_ = fs.Watch(ctx, &proto.WatchRequest{Path: "app.yaml"}, stream)
+	}()
+	next := func(want string) {
+		select {
+		case rsp := <-stream.responses:
+			if string(rsp.ChangeSet.Data) != want {
+				t.Fatalf("watch %s, want %s", rsp.ChangeSet.Data, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watch timeout, want %s", want)
+		}
+	}
+	next("addr: localhost:6379\n")
+	write("common.yaml", "redis:\n  addr: redis:6379\n")
+	next("addr: redis:6379\n")
+}
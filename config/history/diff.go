@@ -0,0 +1,127 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diff returns the unified diff of two documents with n lines of context around each change
+func Diff(a []byte, b []byte, n int) string {
+	x, y := lines(a), lines(b)
+	ops := diffLines(x, y)
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// skip to the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		from := maxInt(start-n, 0)
+		// extend the hunk while changes are closer than twice the context
+		end, gap := start, 0
+		for i := start; i < len(ops) && gap <= 2*n; i++ {
+			if ops[i].kind == ' ' {
+				gap++
+			} else {
+				gap, end = 0, i
+			}
+		}
+		to := minInt(end+n+1, len(ops))
+
+		ai, bi, ac, bc := ops[from].a, ops[from].b, 0, 0
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				ac++
+			}
+			if o.kind != '-' {
+				bc++
+			}
+		}
+		out.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", ai+1, ac, bi+1, bc))
+		for _, o := range ops[from:to] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.line)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// Stat returns the number of lines added and removed from a to b
+func Stat(a []byte, b []byte) (added int, removed int) {
+	for _, o := range diffLines(lines(a), lines(b)) {
+		switch o.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+type op struct {
+	kind byte
+	line string
+	// a and b line indexes of the op in both documents
+	a, b int
+}
+
+// diffLines aligns x and y on their longest common subsequence
+func diffLines(x []string, y []string) []op {
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = maxInt(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, op{kind: ' ', line: x[i], a: i, b: j})
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{kind: '-', line: x[i], a: i, b: j})
+			i++
+		default:
+			ops = append(ops, op{kind: '+', line: y[j], a: i, b: j})
+			j++
+		}
+	}
+	return ops
+}
+
+func lines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,163 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("revision not found")
+
+	revisionsBucket = []byte("revisions")
+)
+
+// Revision is a version of a document
+type Revision struct {
+	Revision int64  `json:"revision"`
+	Checksum string `json:"checksum"`
+	// Actor caller who wrote the revision, empty for changes made directly in the storage
+	Actor string `json:"actor"`
+	// Time unix time of the change
+	Time int64  `json:"time"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// History keeps the latest revisions of every document in a bbolt database
+type History struct {
+	db    *bolt.DB
+	limit int
+}
+
+// Open opens the history database file, only the limit latest revisions of a document are kept
+func Open(file string, limit int) (*History, error) {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(revisionsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &History{db: db, limit: limit}, nil
+}
+
+// Record appends data as the new revision of p, nothing is recorded when checksum is the one of the latest revision
+func (h *History) Record(p string, data []byte, checksum string, actor string) (*Revision, bool, error) {
+	rev := &Revision{Checksum: checksum, Actor: actor, Time: time.Now().Unix(), Data: data}
+	recorded := false
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(revisionsBucket).CreateBucketIfNotExists([]byte(p))
+		if err != nil {
+			return err
+		}
+		if _, v := b.Cursor().Last(); v != nil {
+			var latest Revision
+			if err := json.Unmarshal(v, &latest); err != nil {
+				return err
+			}
+			if latest.Checksum == checksum {
+				*rev = latest
+				return nil
+			}
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		rev.Revision = int64(seq)
+		v, err := json.Marshal(rev)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key(rev.Revision), v); err != nil {
+			return err
+		}
+		recorded = true
+		if h.limit <= 0 {
+			return nil
+		}
+		// drop the oldest revisions over the limit
+		var dropped [][]byte
+		c := b.Cursor()
+		n := 0
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			if n++; n > h.limit {
+				dropped = append(dropped, append([]byte(nil), k...))
+			}
+		}
+		for _, k := range dropped {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return rev, recorded, nil
+}
+
+// List returns the revisions of p without their data, the latest first
+func (h *History) List(p string) ([]*Revision, error) {
+	var revs []*Revision
+	err := h.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revisionsBucket).Bucket([]byte(p))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var rev Revision
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return err
+			}
+			rev.Data = nil
+			revs = append(revs, &rev)
+		}
+		return nil
+	})
+	return revs, err
+}
+
+// Get returns revision rev of p with its data
+func (h *History) Get(p string, rev int64) (*Revision, error) {
+	var r Revision
+	err := h.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revisionsBucket).Bucket([]byte(p))
+		if b == nil {
+			return ErrNotFound
+		}
+		v := b.Get(key(rev))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+func key(rev int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(rev))
+	return k
+}
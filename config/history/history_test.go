@@ -0,0 +1,60 @@
+package history
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistory_Record(t *testing.T) {
+	h, err := Open(filepath.Join(t.TempDir(), "history.db"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	for i, v := range []string{"a", "a", "b", "c"} {
+		_, recorded, err := h.Record("app.yaml", []byte(v), v, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recorded != (i != 1) {
+			t.Errorf("version %d recorded %t", i, recorded)
+		}
+	}
+
+	revs, err := h.List("app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Revision != 3 || revs[1].Revision != 2 || revs[0].Data != nil {
+		t.Fatalf("revisions %+v", revs)
+	}
+	rev, err := h.Get("app.yaml", 3)
+	if err != nil || string(rev.Data) != "c" || rev.Actor != "user" {
+		t.Fatalf("revision %+v error %v", rev, err)
+	}
+	if _, err := h.Get("app.yaml", 1); err != ErrNotFound {
+		t.Fatalf("dropped revision error %v", err)
+	}
+	if _, err := h.Get("missing.yaml", 1); err != ErrNotFound {
+		t.Fatalf("missing document error %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "a: 1\nb: 2\nc: 3\nd: 4\ne: 5\nf: 6\ng: 7\nh: 8\ni: 9\n"
+	b := strings.Replace(strings.Replace(a, "b: 2", "b: 20", 1), "i: 9\n", "", 1)
+	want := "@@ -1,9 +1,8 @@\n" +
+		" a: 1\n-b: 2\n+b: 20\n c: 3\n d: 4\n e: 5\n f: 6\n g: 7\n h: 8\n-i: 9\n"
+	if got := Diff([]byte(a), []byte(b), 3); got != want {
+		t.Errorf("diff\n%s\nwant\n%s", got, want)
+	}
+	want = "@@ -1,3 +1,3 @@\n a: 1\n-b: 2\n+b: 20\n c: 3\n@@ -8,2 +8,1 @@\n h: 8\n-i: 9\n"
+	if got := Diff([]byte(a), []byte(b), 1); got != want {
+		t.Errorf("diff\n%s\nwant\n%s", got, want)
+	}
+	if got := Diff([]byte(a), []byte(a), 3); got != "" {
+		t.Errorf("diff of the same documents %s", got)
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
 	mcgrpc "github.com/go-micro/plugins/v4/client/grpc"
 	msgrpc "github.com/go-micro/plugins/v4/server/grpc"
+	mhttp "github.com/go-micro/plugins/v4/server/http"
+	"github.com/google/uuid"
 	"github.com/sparrow-community/app/config/config"
 	"github.com/sparrow-community/app/config/handler"
+	"github.com/sparrow-community/app/config/history"
+	"github.com/sparrow-community/app/config/secret"
+	"github.com/sparrow-community/app/config/web"
+	"github.com/sparrow-community/pkgs/listener"
 	lg "github.com/sparrow-community/plugins/v4/logger/grpc"
+	"github.com/sparrow-community/protos/cache"
 	"github.com/sparrow-community/protos/config"
 	"go-micro.dev/v4"
 	"go-micro.dev/v4/client"
 	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/util/wrapper"
+	"net"
 )
 
 var (
@@ -27,26 +39,147 @@ func main() {
 		return
 	}
 
-	client.DefaultClient = mcgrpc.NewClient()
+	// the cache names the namespace of the service after the service name of its client
+	client.DefaultClient = wrapper.FromService(config.Conf.Server.Name, mcgrpc.NewClient())
 	lg.InitializeLogger(config.Conf.Server.Name)
 	logger.Infof("%s %s %s %s", version, commit, date, builtBy)
 
-	srv := micro.NewService(
-		micro.Client(mcgrpc.NewClient()),
-		micro.Server(msgrpc.NewServer()),
+	// Verify identity access tokens with the key identity stores in cache
+	cacheService := cache.NewCacheService("cache", client.DefaultClient)
+	if err := config.Conf.InitAccess(cacheService); err != nil {
+		logger.Fatal(err)
+	}
+
+	documents, err := config.Conf.OpenStorage(config.Conf.Storage.Type, client.DefaultClient)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	keyring, err := secret.LoadKeyring(config.Conf.Secrets.Keyfile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	revisions, err := history.Open(config.Conf.History.File, config.Conf.History.Limit)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// the grpc server id identifies the replica in the registry
+	id := uuid.New().String()
+	rep, err := config.Conf.NewReplica(id, client.DefaultClient, registry.DefaultRegistry)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	tc, err := config.Conf.TLSConfig()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	lst, err := listener.New(
+		listener.WithAddress(config.Conf.Server.Address),
+	)
+	if err != nil {
+		logger.Fatalf("error creating listener: %v", err)
+	}
+
+	opts := []micro.Option{
 		micro.Name(config.Conf.Server.Name),
+		micro.Client(mcgrpc.NewClient()),
 		micro.Version(version),
-	)
+	}
+
+	serverOpts := []server.Option{server.Id(id)}
+	if rep != nil {
+		serverOpts = append(serverOpts, server.Metadata(rep.Metadata()))
+	}
+	srv := grpcService(lst.Grpc(), serverOpts, opts...)
 
-	fs, err := handler.NewFileService(config.Conf.Configs.Path)
+	events, err := config.Conf.NewNotifier(srv.Options().Broker)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	fs, err := handler.NewFileService(documents,
+		handler.WithKeyring(keyring, config.Conf.Secrets.Decrypt...),
+		handler.WithControl(config.Conf.Control),
+		handler.WithHistory(revisions),
+		handler.WithReplica(rep),
+		handler.WithEvents(events),
+	)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if rep != nil {
+		// catch up with the leader then follow its changes
+		go rep.Follow(context.Background(), fs)
+	}
+
+	go httpServer(lst.Http(), fs, opts...)
+	go grpcServer(srv, fs)
+	if tc != nil {
+		// tls connections cannot be multiplexed, the tls grpc server listens on its own address and stays out of
+		// the registry, which lists the multiplexed one
+		tlsLst, err := net.Listen("tcp", config.Conf.Access.TLS.Address)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		tlsOpts := append([]server.Option{msgrpc.AuthTLS(tc), server.Registry(registry.NewMemoryRegistry())}, serverOpts...)
+		go grpcServer(grpcService(tlsLst, tlsOpts, opts...), fs)
+	}
+
+	if err := lst.Serve(); err != nil {
+		logger.Errorf("server error: %v", err)
+	}
+}
+
+func httpServer(lst net.Listener, fs *handler.FileService, opts ...micro.Option) {
+	httpServer := mhttp.NewServer(
+		server.Name(config.Conf.Server.Name),
+		mhttp.Listener(lst),
+	)
+	h := handler.NewHttpHandler("/"+config.Conf.Server.Name, fs, web.UI())
+	if err := httpServer.Handle(httpServer.NewHandler(h)); err != nil {
+		logger.Errorf("error creating http server: %v", err)
+	}
+	httpOpts := append(opts, micro.Server(httpServer))
+	srv := micro.NewService(httpOpts...)
+	if err := srv.Run(); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func grpcService(lst net.Listener, serverOpts []server.Option, opts ...micro.Option) micro.Service {
+	serverOpts = append(serverOpts,
+		server.Name(config.Conf.Server.Name),
+		msgrpc.Listener(lst),
+	)
+	grpcOpts := append(opts, micro.Server(msgrpc.NewServer(serverOpts...)))
+	return micro.NewService(grpcOpts...)
+}
+
+func grpcServer(srv micro.Service, fs *handler.FileService) {
 	if err := proto.RegisterSourceHandler(srv.Server(), fs); err != nil {
 		logger.Fatal(err)
 	}
 
+	if err := srv.Server().Handle(srv.Server().NewHandler(handler.NewSecretService(fs))); err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(handler.NewDocumentService(fs))); err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(handler.NewHistoryService(fs))); err != nil {
+		logger.Fatal(err)
+	}
+
+	if err := srv.Server().Handle(srv.Server().NewHandler(handler.NewReplicationService(fs))); err != nil {
+		logger.Fatal(err)
+	}
+
 	if err := srv.Run(); err != nil {
 		logger.Fatal(err)
 	}
@@ -0,0 +1,52 @@
+package remote
+
+import (
+	"context"
+	"github.com/ghodss/yaml"
+	"go-micro.dev/v4/config/source"
+	"go-micro.dev/v4/logger"
+)
+
+// Bind decodes the json or yaml document of src into a new T, then calls reload with every next version
+// decoded into a new T until ctx is done, a version that does not decode is logged and skipped
+func Bind[T any](ctx context.Context, src source.Source, reload func(*T)) (*T, error) {
+	cs, err := src.Read()
+	if err != nil {
+		return nil, err
+	}
+	v := new(T)
+	if err := yaml.Unmarshal(cs.Data, v); err != nil {
+		return nil, err
+	}
+	if reload == nil {
+		return v, nil
+	}
+	w, err := src.Watch()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = w.Stop()
+	}()
+	go func() {
+		checksum := cs.Checksum
+		for {
+			cs, err := w.Next()
+			if err != nil {
+				return
+			}
+			if cs.Checksum == checksum {
+				continue
+			}
+			next := new(T)
+			if err := yaml.Unmarshal(cs.Data, next); err != nil {
+				logger.Errorf("decode %s error %s", src, err)
+				continue
+			}
+			checksum = cs.Checksum
+			reload(next)
+		}
+	}()
+	return v, nil
+}
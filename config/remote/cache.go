@@ -0,0 +1,115 @@
+package remote
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-micro.dev/v4/config/source"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const cacheKeySize = 32
+
+// cache keeps the local copies of the documents in dir, one change set file per path encrypted with key since
+// the copies hold the secret values revealed to the service
+type cache struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// newCache encrypts the copies with key, or with a random key kept in the dir.key file when key is empty
+func newCache(dir string, key []byte) (*cache, error) {
+	if len(key) == 0 {
+		var err error
+		if key, err = loadCacheKey(dir + ".key"); err != nil {
+			return nil, err
+		}
+	}
+	if len(key) != cacheKeySize {
+		return nil, fmt.Errorf("cache key must be %d bytes", cacheKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cache{dir: dir, aead: aead}, nil
+}
+
+// loadCacheKey reads the key file at path, a key file readable by the owner only is created when it does not exist
+func loadCacheKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil || !os.IsNotExist(err) {
+		return key, err
+	}
+	key = make([]byte, cacheKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		// created by another source meanwhile
+		return os.ReadFile(path)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Write(key); err != nil {
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+func (c *cache) file(p string) string {
+	return filepath.Join(c.dir, url.PathEscape(p)+".json")
+}
+
+func (c *cache) save(p string, cs *source.ChangeSet) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	tmp := c.file(p) + ".tmp"
+	if err := os.WriteFile(tmp, c.aead.Seal(nonce, nonce, data, []byte(p)), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file(p))
+}
+
+func (c *cache) load(p string) (*source.ChangeSet, error) {
+	sealed, err := os.ReadFile(c.file(p))
+	if err != nil {
+		return nil, err
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("malformed local copy")
+	}
+	data, err := c.aead.Open(nil, sealed[:size], sealed[size:], []byte(p))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt local copy: %w", err)
+	}
+	var cs source.ChangeSet
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
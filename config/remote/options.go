@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"context"
+	"go-micro.dev/v4/config/source"
+	"time"
+)
+
+type serviceKey struct{}
+type pathKey struct{}
+type tokenKey struct{}
+type cacheDirKey struct{}
+type cacheKeyKey struct{}
+type timeoutKey struct{}
+type backoffKey struct{}
+
+// Backoff delays of the watch reconnections, doubled after each failure from Min up to Max
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// WithService sets the name of the config service, config by default
+func WithService(name string) source.Option {
+	return withValue(serviceKey{}, name)
+}
+
+// WithPath sets the document read, it may select a key and a format like app.yaml?format=json#database
+func WithPath(p string) source.Option {
+	return withValue(pathKey{}, p)
+}
+
+// WithToken sets the identity access token sent with the requests
+func WithToken(token string) source.Option {
+	return withValue(tokenKey{}, token)
+}
+
+// WithCacheDir sets the directory of the local copies read when the service is unreachable, empty disables them
+func WithCacheDir(dir string) source.Option {
+	return withValue(cacheDirKey{}, dir)
+}
+
+// WithCacheKey sets the 32 bytes key encrypting the local copies, a random key is kept next to the cache directory
+// without it
+func WithCacheKey(key []byte) source.Option {
+	return withValue(cacheKeyKey{}, key)
+}
+
+// WithTimeout sets the timeout of a read
+func WithTimeout(d time.Duration) source.Option {
+	return withValue(timeoutKey{}, d)
+}
+
+// WithBackoff sets the delays of the watch reconnections
+func WithBackoff(min time.Duration, max time.Duration) source.Option {
+	return withValue(backoffKey{}, Backoff{Min: min, Max: max})
+}
+
+func withValue(k interface{}, v interface{}) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
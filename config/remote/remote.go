@@ -0,0 +1,180 @@
+// Package remote is a go-micro config source reading its documents from the config service
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sparrow-community/protos/config"
+	"go-micro.dev/v4/config/source"
+	merrors "go-micro.dev/v4/errors"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	DefaultService = "config"
+	DefaultTimeout = 5 * time.Second
+	DefaultBackoff = Backoff{Min: 100 * time.Millisecond, Max: 30 * time.Second}
+)
+
+type remote struct {
+	opts    source.Options
+	service proto.SourceService
+	path    string
+	token   string
+	cache   *cache
+	timeout time.Duration
+	backoff Backoff
+}
+
+// NewSource returns the source of a config service document, see WithPath
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+	name := DefaultService
+	if v, ok := options.Context.Value(serviceKey{}).(string); ok {
+		name = v
+	}
+	return newSource(proto.NewSourceService(name, options.Client), options)
+}
+
+func newSource(service proto.SourceService, options source.Options) *remote {
+	r := &remote{
+		opts:    options,
+		service: service,
+		timeout: DefaultTimeout,
+		backoff: DefaultBackoff,
+	}
+	r.path, _ = options.Context.Value(pathKey{}).(string)
+	r.token, _ = options.Context.Value(tokenKey{}).(string)
+	if v, ok := options.Context.Value(timeoutKey{}).(time.Duration); ok {
+		r.timeout = v
+	}
+	if v, ok := options.Context.Value(backoffKey{}).(Backoff); ok {
+		r.backoff = v
+	}
+	dir, ok := options.Context.Value(cacheDirKey{}).(string)
+	if !ok {
+		if d, err := os.UserCacheDir(); err == nil {
+			dir = filepath.Join(d, "sparrow", "config")
+		}
+	}
+	if dir != "" {
+		key, _ := options.Context.Value(cacheKeyKey{}).([]byte)
+		c, err := newCache(dir, key)
+		if err != nil {
+			logger.Warnf("local copies in %s disabled, key error %s", dir, err)
+		}
+		r.cache = c
+	}
+	return r
+}
+
+// Read returns the document from the service, or its local copy when the service is unreachable, a denied read or
+// a missing document is an error
+func (r *remote) Read() (*source.ChangeSet, error) {
+	ctx, cancel := context.WithTimeout(r.context(context.Background()), r.timeout)
+	defer cancel()
+	rsp, err := r.service.Read(ctx, &proto.ReadRequest{Path: r.path})
+	if err == nil {
+		cs := r.changeSet(rsp.ChangeSet)
+		r.store(cs)
+		return cs, nil
+	}
+	if r.cache == nil || !unavailable(err) {
+		return nil, err
+	}
+	cs, cerr := r.cache.load(r.path)
+	if cerr != nil {
+		return nil, fmt.Errorf("read %s error %v, no local copy %v", r.path, err, cerr)
+	}
+	logger.Warnf("read %s error %s, using the local copy", r.path, err)
+	return cs, nil
+}
+
+func (r *remote) Write(cs *source.ChangeSet) error {
+	ctx, cancel := context.WithTimeout(r.context(context.Background()), r.timeout)
+	defer cancel()
+	rsp, err := r.service.Write(ctx, &proto.WriteRequest{
+		Path: r.path,
+		ChangeSet: &proto.ChangeSet{
+			Data:      cs.Data,
+			Checksum:  cs.Checksum,
+			Format:    cs.Format,
+			Source:    cs.Source,
+			Timestamp: cs.Timestamp.Unix(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !rsp.Value {
+		return errors.New("write " + r.path + " failed")
+	}
+	return nil
+}
+
+// Watch follows the document, the watch reconnects with a backoff while the service is unreachable
+func (r *remote) Watch() (source.Watcher, error) {
+	return newWatcher(r), nil
+}
+
+func (r *remote) String() string {
+	return "config"
+}
+
+func (r *remote) context(ctx context.Context) context.Context {
+	if r.token == "" {
+		return ctx
+	}
+	return metadata.MergeContext(ctx, metadata.Metadata{"Authorization": "Bearer " + r.token}, true)
+}
+
+func (r *remote) changeSet(cs *proto.ChangeSet) *source.ChangeSet {
+	set := &source.ChangeSet{
+		Data:      cs.Data,
+		Checksum:  cs.Checksum,
+		Format:    cs.Format,
+		Source:    r.String(),
+		Timestamp: time.Unix(cs.Timestamp, 0),
+	}
+	if set.Checksum == "" {
+		set.Checksum = set.Sum()
+	}
+	return set
+}
+
+// store keeps the local copy of cs
+func (r *remote) store(cs *source.ChangeSet) {
+	if r.cache == nil {
+		return
+	}
+	if err := r.cache.save(r.path, cs); err != nil {
+		logger.Warnf("save %s local copy error %s", r.path, err)
+	}
+}
+
+// unavailable reports whether err tells the service could not be reached or did not answer in time, the go-micro
+// client reports a service without node or connection as its own internal error
+func unavailable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if s, ok := status.FromError(err); ok && (s.Code() == codes.Unavailable || s.Code() == codes.DeadlineExceeded) {
+		return true
+	}
+	e := merrors.FromError(err)
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusInternalServerError:
+		return e.Id == "go.micro.client"
+	}
+	return false
+}
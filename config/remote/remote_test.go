@@ -0,0 +1,179 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"github.com/sparrow-community/protos/config"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/config/source"
+	merrors "go-micro.dev/v4/errors"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// service serves data until it is down, its watch streams send the queued change sets then fail
+type service struct {
+	sync.Mutex
+	data    []byte
+	down    bool
+	denied  bool
+	streams chan chan *proto.WatchResponse
+}
+
+func (s *service) Read(ctx context.Context, in *proto.ReadRequest, opts ...client.CallOption) (*proto.ReadResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return nil, merrors.InternalServerError("go.micro.client", "service config: not found")
+	}
+	if s.denied {
+		return nil, merrors.Forbidden("go.micro.client", "read app.json is not allowed")
+	}
+	return &proto.ReadResponse{ChangeSet: &proto.ChangeSet{Data: s.data, Format: "json"}}, nil
+}
+
+func (s *service) Write(ctx context.Context, in *proto.WriteRequest, opts ...client.CallOption) (*wrapperspb.BoolValue, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.data = in.ChangeSet.Data
+	return &wrapperspb.BoolValue{Value: true}, nil
+}
+
+func (s *service) Watch(ctx context.Context, in *proto.WatchRequest, opts ...client.CallOption) (proto.Source_WatchService, error) {
+	ch := make(chan *proto.WatchResponse, 10)
+	s.streams <- ch
+	return &stream{ctx: ctx, ch: ch}, nil
+}
+
+type stream struct {
+	proto.Source_WatchService
+	ctx context.Context
+	ch  chan *proto.WatchResponse
+}
+
+func (s *stream) Recv() (*proto.WatchResponse, error) {
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case rsp, ok := <-s.ch:
+		if !ok {
+			return nil, io.EOF
+		}
+		return rsp, nil
+	}
+}
+
+func (s *stream) Close() error {
+	return nil
+}
+
+func newTestSource(t *testing.T, s *service) *remote {
+	return newSource(s, source.NewOptions(
+		WithPath("app.json"),
+		WithCacheDir(t.TempDir()),
+		WithBackoff(time.Millisecond, 10*time.Millisecond),
+	))
+}
+
+func TestRemote_Read(t *testing.T) {
+	s := &service{data: []byte(`{"password":"s3cret"}`)}
+	r := newTestSource(t, s)
+	if cs, err := r.Read(); err != nil || string(cs.Data) != `{"password":"s3cret"}` {
+		t.Fatalf("read %v error %v", cs, err)
+	}
+	file := r.cache.file("app.json")
+	if data, err := os.ReadFile(file); err != nil || bytes.Contains(data, []byte("s3cret")) {
+		t.Fatalf("local copy %s in plaintext, error %v", data, err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("local copy mode %v error %v", info, err)
+	}
+
+	s.down = true
+	cs, err := r.Read()
+	if err != nil || string(cs.Data) != `{"password":"s3cret"}` || cs.Format != "json" {
+		t.Fatalf("local copy %v error %v", cs, err)
+	}
+
+	// a revoked access is not hidden by the local copy
+	s.down, s.denied = false, true
+	if _, err := r.Read(); err == nil {
+		t.Fatal("denied read returned the local copy")
+	}
+
+	r.cache = nil
+	if _, err := r.Read(); err == nil {
+		t.Fatal("read without local copy")
+	}
+}
+
+func TestRemote_Watch(t *testing.T) {
+	s := &service{streams: make(chan chan *proto.WatchResponse, 10)}
+	r := newTestSource(t, s)
+	w, err := r.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	send := func(ch chan *proto.WatchResponse, data string) {
+		ch <- &proto.WatchResponse{ChangeSet: &proto.ChangeSet{Data: []byte(data), Checksum: data}}
+	}
+	next := func(want string) {
+		cs, err := w.Next()
+		if err != nil || string(cs.Data) != want {
+			t.Fatalf("next %v error %v, want %s", cs, err, want)
+		}
+	}
+
+	ch := <-s.streams
+	send(ch, "a")
+	next("a")
+	close(ch)
+
+	// the reconnected stream sends the current version again, only the next one is a change
+	ch = <-s.streams
+	send(ch, "a")
+	send(ch, "b")
+	next("b")
+
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Next(); err != source.ErrWatcherStopped {
+		t.Fatalf("stopped watcher error %v", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type Config struct {
+		Server struct {
+			Address string `json:"address"`
+		} `json:"server"`
+	}
+	s := &service{data: []byte(`{"server":{"address":":80"}}`), streams: make(chan chan *proto.WatchResponse, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloads := make(chan *Config, 1)
+	c, err := Bind(ctx, newTestSource(t, s), func(c *Config) { reloads <- c })
+	if err != nil || c.Server.Address != ":80" {
+		t.Fatalf("bind %+v error %v", c, err)
+	}
+
+	ch := <-s.streams
+	ch <- &proto.WatchResponse{ChangeSet: &proto.ChangeSet{Data: []byte("server: [invalid"), Checksum: "1"}}
+	ch <- &proto.WatchResponse{ChangeSet: &proto.ChangeSet{Data: []byte("server:\n  address: :81\n"), Checksum: "2"}}
+	select {
+	case c := <-reloads:
+		if c.Server.Address != ":81" {
+			t.Fatalf("reload %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reload")
+	}
+}
@@ -0,0 +1,106 @@
+package remote
+
+import (
+	"context"
+	"github.com/sparrow-community/protos/config"
+	"go-micro.dev/v4/config/source"
+	"go-micro.dev/v4/logger"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// watcher keeps a watch stream open, it reconnects with a backoff after each stream error
+type watcher struct {
+	r       *remote
+	ctx     context.Context
+	cancel  context.CancelFunc
+	updates chan *source.ChangeSet
+	once    sync.Once
+}
+
+func newWatcher(r *remote) *watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{
+		r:       r,
+		ctx:     ctx,
+		cancel:  cancel,
+		updates: make(chan *source.ChangeSet, 1),
+	}
+	go w.run()
+	return w
+}
+
+func (w *watcher) run() {
+	delay := w.r.backoff.Min
+	var checksum string
+	for {
+		received, err := w.watch(&checksum)
+		if w.ctx.Err() != nil {
+			return
+		}
+		if received {
+			delay = w.r.backoff.Min
+		}
+		logger.Warnf("watch %s error %s, reconnecting in %s", w.r.path, err, delay)
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(jitter(delay)):
+		}
+		if delay *= 2; delay > w.r.backoff.Max {
+			delay = w.r.backoff.Max
+		}
+	}
+}
+
+// watch receives the versions of the document until the stream fails, it reports whether a version was received
+func (w *watcher) watch(checksum *string) (bool, error) {
+	stream, err := w.r.service.Watch(w.r.context(w.ctx), &proto.WatchRequest{Path: w.r.path})
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+	received := false
+	for {
+		rsp, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+		cs := w.r.changeSet(rsp.ChangeSet)
+		// a reconnection sends the current version first
+		if cs.Checksum == *checksum {
+			continue
+		}
+		*checksum = cs.Checksum
+		w.r.store(cs)
+		select {
+		case <-w.updates:
+		default:
+		}
+		w.updates <- cs
+	}
+}
+
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case <-w.ctx.Done():
+		return nil, source.ErrWatcherStopped
+	case cs := <-w.updates:
+		return cs, nil
+	}
+}
+
+func (w *watcher) Stop() error {
+	w.once.Do(w.cancel)
+	return nil
+}
+
+// jitter spreads the reconnections of the clients between d/2 and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
@@ -0,0 +1,232 @@
+package replica
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/registry"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// StartedKey registry metadata of the start time of a replica
+	StartedKey = "replica_started"
+	// SecretKey request metadata of the secret shared by the replicas, sent in plaintext without tls
+	SecretKey = "Replication-Secret"
+
+	applyEndpoint    = "ReplicationService.Apply"
+	snapshotEndpoint = "ReplicationService.Snapshot"
+)
+
+var ErrSecret = errors.New("invalid replication secret")
+
+type Op string
+
+const (
+	OpWrite  Op = "write"
+	OpDelete Op = "delete"
+	OpRename Op = "rename"
+)
+
+// Change of a document a follower forwards to the leader
+type Change struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+	// To new path of a rename
+	To   string `json:"to"`
+	Data []byte `json:"data"`
+	// Actor caller who made the change
+	Actor string `json:"actor"`
+}
+
+type ApplyResponse struct{}
+
+type SnapshotRequest struct {
+	// Checksums of the follower documents by path
+	Checksums map[string]string `json:"checksums"`
+}
+
+type Document struct {
+	Path     string `json:"path"`
+	Checksum string `json:"checksum"`
+	Data     []byte `json:"data"`
+}
+
+type SnapshotResponse struct {
+	// Documents of the leader that differ from the follower ones
+	Documents []*Document `json:"documents"`
+	// Paths of every leader document
+	Paths []string `json:"paths"`
+}
+
+// Store the local documents of a replica
+type Store interface {
+	// Checksums returns the checksum of every document by path
+	Checksums() map[string]string
+	// Data returns the content of the document at p
+	Data(p string) ([]byte, error)
+	// Apply makes a change locally
+	Apply(change *Change) error
+}
+
+// Replica of the service, the oldest replica registered is the leader, followers forward
+// their changes to the leader and poll it for the documents changed since their last sync
+type Replica struct {
+	name     string
+	id       string
+	started  time.Time
+	secret   string
+	interval time.Duration
+	client   client.Client
+	registry registry.Registry
+}
+
+// New returns the replica id of the service name, id is the server id of the replica
+func New(name string, id string, secret string, interval time.Duration, c client.Client, r registry.Registry) *Replica {
+	return &Replica{
+		name:     name,
+		id:       id,
+		started:  time.Now(),
+		secret:   secret,
+		interval: interval,
+		client:   c,
+		registry: r,
+	}
+}
+
+// Metadata registry metadata of the grpc server of the replica
+func (r *Replica) Metadata() map[string]string {
+	return map[string]string{StartedKey: strconv.FormatInt(r.started.UnixNano(), 10)}
+}
+
+// Leader returns the node of the leader, nil when the replica is the leader
+func (r *Replica) Leader() (*registry.Node, error) {
+	services, err := r.registry.GetService(r.name)
+	if err != nil && err != registry.ErrNotFound {
+		return nil, err
+	}
+	type candidate struct {
+		node    *registry.Node
+		started int64
+	}
+	self := r.name + "-" + r.id
+	candidates := []candidate{{started: r.started.UnixNano()}}
+	for _, s := range services {
+		for _, n := range s.Nodes {
+			started, err := strconv.ParseInt(n.Metadata[StartedKey], 10, 64)
+			if n.Id == self || n.Metadata["server"] != "grpc" || err != nil {
+				continue
+			}
+			candidates = append(candidates, candidate{node: n, started: started})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].started != candidates[j].started {
+			return candidates[i].started < candidates[j].started
+		}
+		return id(candidates[i].node, self) < id(candidates[j].node, self)
+	})
+	return candidates[0].node, nil
+}
+
+func id(n *registry.Node, self string) string {
+	if n == nil {
+		return self
+	}
+	return n.Id
+}
+
+// Forward makes change on the leader node
+func (r *Replica) Forward(ctx context.Context, leader *registry.Node, change *Change) error {
+	req := r.client.NewRequest(r.name, applyEndpoint, change, client.WithContentType("application/grpc+json"))
+	return r.client.Call(r.context(ctx), req, &ApplyResponse{}, client.WithAddress(leader.Address))
+}
+
+// Authorize checks the request comes from a replica
+func (r *Replica) Authorize(ctx context.Context) error {
+	s, ok := metadata.Get(ctx, SecretKey)
+	if !ok || subtle.ConstantTimeCompare([]byte(s), []byte(r.secret)) != 1 {
+		return ErrSecret
+	}
+	return nil
+}
+
+// Follow syncs store from the leader while the replica is a follower, until ctx is done
+func (r *Replica) Follow(ctx context.Context, store Store) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Sync(ctx, store); err != nil {
+			logger.Warnf("sync from leader error %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the documents that differ from the leader ones, nothing is done on the leader
+func (r *Replica) Sync(ctx context.Context, store Store) error {
+	leader, err := r.Leader()
+	if err != nil || leader == nil {
+		return err
+	}
+	req := r.client.NewRequest(r.name, snapshotEndpoint, &SnapshotRequest{Checksums: store.Checksums()},
+		client.WithContentType("application/grpc+json"))
+	var snapshot SnapshotResponse
+	if err := r.client.Call(r.context(ctx), req, &snapshot, client.WithAddress(leader.Address)); err != nil {
+		return err
+	}
+	return Merge(store, &snapshot)
+}
+
+func (r *Replica) context(ctx context.Context) context.Context {
+	return metadata.MergeContext(ctx, metadata.Metadata{SecretKey: r.secret}, true)
+}
+
+// Merge applies a leader snapshot to store, the documents the leader does not have are deleted
+func Merge(store Store, snapshot *SnapshotResponse) error {
+	for _, d := range snapshot.Documents {
+		if err := store.Apply(&Change{Op: OpWrite, Path: d.Path, Data: d.Data}); err != nil {
+			return err
+		}
+	}
+	paths := make(map[string]bool, len(snapshot.Paths))
+	for _, p := range snapshot.Paths {
+		paths[p] = true
+	}
+	for p := range store.Checksums() {
+		if paths[p] {
+			continue
+		}
+		if err := store.Apply(&Change{Op: OpDelete, Path: p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot returns the documents of the leader store that differ from the follower checksums
+func Snapshot(store Store, request *SnapshotRequest) (*SnapshotResponse, error) {
+	response := &SnapshotResponse{}
+	for p, checksum := range store.Checksums() {
+		response.Paths = append(response.Paths, p)
+		if request.Checksums[p] == checksum {
+			continue
+		}
+		data, err := store.Data(p)
+		if err != nil {
+			return nil, err
+		}
+		response.Documents = append(response.Documents, &Document{Path: p, Checksum: checksum, Data: data})
+	}
+	sort.Strings(response.Paths)
+	return response, nil
+}
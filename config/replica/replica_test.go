@@ -0,0 +1,40 @@
+package replica
+
+import (
+	"go-micro.dev/v4/registry"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplica_Leader(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+	r := New("config", "b", "secret", time.Second, nil, reg)
+
+	leader, err := r.Leader()
+	if err != nil || leader != nil {
+		t.Fatalf("single replica leader %v error %v", leader, err)
+	}
+
+	register := func(id string, server string, started time.Time) {
+		err := reg.Register(&registry.Service{Name: "config", Version: id, Nodes: []*registry.Node{{
+			Id:       "config-" + id,
+			Address:  id + ":8080",
+			Metadata: map[string]string{"server": server, StartedKey: strconv.FormatInt(started.UnixNano(), 10)},
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	register("b", "grpc", r.started)
+	register("c", "grpc", r.started.Add(time.Second))
+	register("h", "http", r.started.Add(-time.Hour))
+	if leader, err := r.Leader(); err != nil || leader != nil {
+		t.Fatalf("oldest replica leader %v error %v", leader, err)
+	}
+
+	register("a", "grpc", r.started)
+	if leader, err := r.Leader(); err != nil || leader == nil || leader.Id != "config-a" {
+		t.Fatalf("same start leader %v error %v", leader, err)
+	}
+}
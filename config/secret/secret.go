@@ -0,0 +1,316 @@
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// Algorithm prefix of a sealed ENC[...] value, ENC[AES256_GCM,<key id>,<base64 nonce+ciphertext>]
+	Algorithm = "AES256_GCM"
+	keySize   = 32
+)
+
+// marker matches ENC[...] values, plaintext values must not contain ']'
+var marker = regexp.MustCompile(`ENC\[([^\]]*)\]`)
+
+type key struct {
+	id   string
+	raw  []byte
+	aead cipher.AEAD
+}
+
+// Keyring holds the keys used to seal ENC[...] values, the first key is the primary one
+type Keyring struct {
+	sync.RWMutex
+	path string
+	keys []*key
+}
+
+// LoadKeyring reads the keyfile at path, a new keyfile with a random key is created when it does not exist
+func LoadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{path: path}
+	keys, err := readKeys(path)
+	if os.IsNotExist(err) {
+		nk, err := newKey()
+		if err != nil {
+			return nil, err
+		}
+		k.keys = []*key{nk}
+		return k, k.save()
+	} else if err != nil {
+		return nil, err
+	}
+	k.keys = keys
+	return k, nil
+}
+
+// Reload reads the keyfile again, the replicas share it and only the leader rotates it
+func (k *Keyring) Reload() error {
+	keys, err := readKeys(k.path)
+	if err != nil {
+		return err
+	}
+	k.Lock()
+	defer k.Unlock()
+	k.keys = keys
+	return nil
+}
+
+func readKeys(path string) ([]*key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []*key
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("keyfile %s: malformed line", path)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("keyfile %s: key %s: %w", path, id, err)
+		}
+		nk, err := parseKey(id, raw)
+		if err != nil {
+			return nil, fmt.Errorf("keyfile %s: %w", path, err)
+		}
+		keys = append(keys, nk)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keyfile %s: no key", path)
+	}
+	return keys, nil
+}
+
+// Seal encrypts every plaintext ENC[...] value of data with the primary key, sealed values are kept as is
+func (k *Keyring) Seal(data []byte) ([]byte, error) {
+	k.RLock()
+	defer k.RUnlock()
+	return k.replace(data, func(value string) (string, error) {
+		if isSealed(value) {
+			return "ENC[" + value + "]", nil
+		}
+		return k.seal(value)
+	})
+}
+
+// Open decrypts every ENC[...] value of data, plaintext values lose their marker, the keyfile is reloaded
+// when a value is sealed with a key the keyring does not have, a follower replica meets the keys the leader rotates
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	if k.missing(data) {
+		if err := k.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	k.RLock()
+	defer k.RUnlock()
+	return k.replace(data, func(value string) (string, error) {
+		if !isSealed(value) {
+			return value, nil
+		}
+		return k.open(value)
+	})
+}
+
+// Reseal encrypts every ENC[...] value of data with the primary key
+func (k *Keyring) Reseal(data []byte) ([]byte, error) {
+	k.RLock()
+	defer k.RUnlock()
+	return k.replace(data, func(value string) (string, error) {
+		if isSealed(value) {
+			plain, err := k.open(value)
+			if err != nil {
+				return "", err
+			}
+			value = plain
+		}
+		return k.seal(value)
+	})
+}
+
+// Rotate adds a new primary key and saves the keyfile, previous keys are kept until Prune
+func (k *Keyring) Rotate() (string, error) {
+	nk, err := newKey()
+	if err != nil {
+		return "", err
+	}
+	k.Lock()
+	defer k.Unlock()
+	k.keys = append([]*key{nk}, k.keys...)
+	if err := k.save(); err != nil {
+		k.keys = k.keys[1:]
+		return "", err
+	}
+	return nk.id, nil
+}
+
+// Prune drops every key but the primary one and saves the keyfile
+func (k *Keyring) Prune() error {
+	k.Lock()
+	defer k.Unlock()
+	k.keys = k.keys[:1]
+	return k.save()
+}
+
+// missing reports whether a value of data is sealed with a key the keyring does not have
+func (k *Keyring) missing(data []byte) bool {
+	k.RLock()
+	defer k.RUnlock()
+	for _, m := range marker.FindAllSubmatch(data, -1) {
+		value := string(m[1])
+		if !isSealed(value) {
+			continue
+		}
+		if parts := strings.SplitN(value, ",", 3); len(parts) == 3 && k.find(parts[1]) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *Keyring) find(id string) *key {
+	for _, v := range k.keys {
+		if v.id == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// Primary returns the id of the primary key
+func (k *Keyring) Primary() string {
+	k.RLock()
+	defer k.RUnlock()
+	return k.keys[0].id
+}
+
+func (k *Keyring) replace(data []byte, fn func(value string) (string, error)) ([]byte, error) {
+	var errs []string
+	out := marker.ReplaceAllFunc(data, func(m []byte) []byte {
+		v, err := fn(string(marker.FindSubmatch(m)[1]))
+		if err != nil {
+			errs = append(errs, err.Error())
+			return m
+		}
+		return []byte(v)
+	})
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return out, nil
+}
+
+func (k *Keyring) seal(plain string) (string, error) {
+	primary := k.keys[0]
+	nonce := make([]byte, primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := primary.aead.Seal(nonce, nonce, []byte(plain), []byte(primary.id))
+	return fmt.Sprintf("ENC[%s,%s,%s]", Algorithm, primary.id, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func (k *Keyring) open(value string) (string, error) {
+	parts := strings.SplitN(value, ",", 3)
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	found := k.find(parts[1])
+	if found == nil {
+		return "", fmt.Errorf("unknown key %s", parts[1])
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	size := found.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := found.aead.Open(nil, sealed[:size], sealed[size:], []byte(found.id))
+	if err != nil {
+		return "", fmt.Errorf("decrypt with key %s: %w", found.id, err)
+	}
+	return string(plain), nil
+}
+
+// save writes the keyfile through a temporary file, readable by the owner only
+func (k *Keyring) save() error {
+	var buf bytes.Buffer
+	for _, v := range k.keys {
+		buf.WriteString(fmt.Sprintf("%s:%s\n", v.id, base64.StdEncoding.EncodeToString(v.raw)))
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.tmp", k.path)
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.path)
+}
+
+// Sealed reports whether every ENC[...] value of data is sealed
+func Sealed(data []byte) bool {
+	for _, m := range marker.FindAllSubmatch(data, -1) {
+		if !isSealed(string(m[1])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSealed(value string) bool {
+	return strings.HasPrefix(value, Algorithm+",")
+}
+
+func newKey() (*key, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return parseKey(hex.EncodeToString(id), raw)
+}
+
+func parseKey(id string, raw []byte) (*key, error) {
+	if len(raw) != keySize {
+		return nil, fmt.Errorf("key %s must be %d bytes", id, keySize)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &key{id: id, raw: raw, aead: aead}, nil
+}
@@ -0,0 +1,75 @@
+package secret
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.key")
+	k, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := []byte(`{"redis": {"password": "ENC[s3cret]", "addr": "localhost:6379"}}`)
+	sealed, err := k.Seal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("s3cret")) {
+		t.Fatalf("sealed document contains plaintext: %s", sealed)
+	}
+	if Sealed(doc) || !Sealed(sealed) {
+		t.Fatalf("Sealed() of %s or %s is wrong", doc, sealed)
+	}
+	if again, err := k.Seal(sealed); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(again, sealed) {
+		t.Fatalf("sealing twice changed the document: %s", again)
+	}
+
+	// a reloaded keyring opens values sealed before
+	loaded, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := loaded.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte(`{"redis": {"password": "s3cret", "addr": "localhost:6379"}}`); !bytes.Equal(opened, want) {
+		t.Fatalf("got %s, want %s", opened, want)
+	}
+
+	// rotation keeps the old key until every value is resealed
+	old := k.Primary()
+	if _, err := k.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	resealed, err := k.Reseal(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(resealed, []byte(","+old+",")) {
+		t.Fatalf("resealed document still uses key %s: %s", old, resealed)
+	}
+	if _, err := k.Open(sealed); err == nil {
+		t.Fatal("value sealed with a pruned key opened")
+	}
+	if opened, err := k.Open(resealed); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Contains(opened, []byte(`"s3cret"`)) {
+		t.Fatalf("got %s", opened)
+	}
+
+	// a replica sharing the keyfile reloads it to open the values sealed with the rotated key
+	if opened, err := loaded.Open(resealed); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Contains(opened, []byte(`"s3cret"`)) {
+		t.Fatalf("got %s", opened)
+	}
+}
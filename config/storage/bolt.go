@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"encoding/json"
+	"go-micro.dev/v4/config/source"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var documentsBucket = []byte("documents")
+
+// record is the stored form of a document in key value storages
+type record struct {
+	Data     []byte    `json:"data"`
+	Modified time.Time `json:"modified"`
+}
+
+// Bolt keeps documents in an embedded bbolt database, watchers only see writes of this process
+type Bolt struct {
+	db       *bolt.DB
+	watchers watchers
+}
+
+func NewBolt(file string) (*Bolt, error) {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(documentsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+func (b *Bolt) Read(p string) (*source.ChangeSet, error) {
+	var r record
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(documentsBucket).Get([]byte(p))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newChangeSet(p, r.Data, b.String(), r.Modified), nil
+}
+
+func (b *Bolt) Write(p string, data []byte) error {
+	r := record{Data: data, Modified: time.Now()}
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(documentsBucket).Put([]byte(p), v)
+	})
+	if err != nil {
+		return err
+	}
+	b.watchers.notify(p, newChangeSet(p, r.Data, b.String(), r.Modified))
+	return nil
+}
+
+func (b *Bolt) Delete(p string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(documentsBucket)
+		if bucket.Get([]byte(p)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(p))
+	})
+}
+
+func (b *Bolt) Rename(from string, to string) error {
+	var r record
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(documentsBucket)
+		v := bucket.Get([]byte(from))
+		if v == nil {
+			return ErrNotFound
+		}
+		if bucket.Get([]byte(to)) != nil {
+			return ErrExists
+		}
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte(to), v); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(from))
+	})
+	if err != nil {
+		return err
+	}
+	b.watchers.notify(to, newChangeSet(to, r.Data, b.String(), r.Modified))
+	return nil
+}
+
+func (b *Bolt) List() ([]string, error) {
+	var ps []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(documentsBucket).ForEach(func(k, _ []byte) error {
+			ps = append(ps, string(k))
+			return nil
+		})
+	})
+	return ps, err
+}
+
+func (b *Bolt) Watch(p string) (source.Watcher, error) {
+	return b.watchers.add(p), nil
+}
+
+func (b *Bolt) String() string {
+	return "bolt"
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
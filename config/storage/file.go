@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"go-micro.dev/v4/config/source"
+	"go-micro.dev/v4/config/source/file"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File keeps documents as files of a local directory
+type File struct {
+	root string
+}
+
+func NewFile(root string) (*File, error) {
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &File{root: root}, nil
+}
+
+func (f *File) Read(p string) (*source.ChangeSet, error) {
+	cs, err := f.source(p).Read()
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return cs, err
+}
+
+// Write replaces the file through a temporary file so watchers never read a partial document
+func (f *File) Write(p string, data []byte) error {
+	dest := f.file(p)
+	dir := filepath.Dir(dest)
+	exists, err := exists(dir)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("MkdirAll error %s %s", dir, err)
+		}
+	}
+	destTmp := fmt.Sprintf("%s.tmp", dest)
+	if err := os.WriteFile(destTmp, data, 0666); err != nil {
+		return fmt.Errorf("write file error %s %s", dest, err)
+	}
+	if err := os.Rename(destTmp, dest); err != nil {
+		return fmt.Errorf("rename %s to %s error %s", destTmp, dest, err)
+	}
+	return nil
+}
+
+func (f *File) Delete(p string) error {
+	err := os.Remove(f.file(p))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (f *File) Rename(from string, to string) error {
+	src, dest := f.file(from), f.file(to)
+	if ok, err := exists(src); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFound
+	}
+	if ok, err := exists(dest); err != nil {
+		return err
+	} else if ok {
+		return ErrExists
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return fmt.Errorf("MkdirAll error %s %s", dest, err)
+	}
+	return os.Rename(src, dest)
+}
+
+func (f *File) List() ([]string, error) {
+	var ps []string
+	err := filepath.Walk(f.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(f.root, path)
+		if err != nil {
+			return err
+		}
+		ps = append(ps, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (f *File) Watch(p string) (source.Watcher, error) {
+	return f.source(p).Watch()
+}
+
+func (f *File) String() string {
+	return "file"
+}
+
+func (f *File) source(p string) source.Source {
+	return file.NewSource(
+		file.WithPath(f.file(p)),
+		source.WithEncoder(&BytesEncoder{}),
+	)
+}
+
+func (f *File) file(p string) string {
+	return filepath.Join(f.root, filepath.FromSlash(p))
+}
+
+// BytesEncoder .
+type BytesEncoder struct{}
+
+func (b BytesEncoder) Encode(_ interface{}) ([]byte, error) {
+	panic("not support for encoding")
+}
+
+func (b BytesEncoder) Decode(_ []byte, _ interface{}) error {
+	panic("not support for decoding")
+}
+
+func (b BytesEncoder) String() string {
+	return "bytes"
+}
+
+func exists(p string) (bool, error) {
+	if _, err := os.Stat(p); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return true, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sparrow-community/protos/cache"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/config/source"
+	"go-micro.dev/v4/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	CacheConfigDocumentKey = "cache:config:document:"
+	// cacheService name of the cache service
+	cacheService = "cache"
+	// listPageSize keys of a page of the document keys
+	listPageSize = 500
+)
+
+// keyListRequest and keyListResponse of the cache KeyService.List endpoint
+type keyListRequest struct {
+	Pattern string `json:"pattern"`
+	Cursor  string `json:"cursor"`
+	Size    int64  `json:"size"`
+}
+
+type keyListResponse struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor"`
+}
+
+// Redis keeps documents in redis through the cache service, watchers poll so writes of other replicas are seen too
+type Redis struct {
+	client   cache.CacheService
+	keys     client.Client
+	interval time.Duration
+}
+
+func NewRedis(c client.Client, interval time.Duration) *Redis {
+	return &Redis{client: cache.NewCacheService(cacheService, c), keys: c, interval: interval}
+}
+
+func (r *Redis) Read(p string) (*source.ChangeSet, error) {
+	ret, err := r.client.Get(context.Background(), &cache.GetRequest{Key: CacheConfigDocumentKey + p})
+	if err != nil {
+		// Cache.Get fails with a 404 on a missing key
+		if errors.FromError(err).Code == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	// caches before the 404 returned an empty value for a missing key
+	if len(ret.Value) <= 0 {
+		return nil, ErrNotFound
+	}
+	var rec record
+	if err := json.Unmarshal([]byte(ret.Value), &rec); err != nil {
+		return nil, err
+	}
+	return newChangeSet(p, rec.Data, r.String(), rec.Modified), nil
+}
+
+func (r *Redis) Write(p string, data []byte) error {
+	rec := record{Data: data, Modified: time.Now()}
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Set(context.Background(), &cache.SetRequest{Key: CacheConfigDocumentKey + p, Value: string(v)})
+	return err
+}
+
+func (r *Redis) Delete(p string) error {
+	if _, err := r.Read(p); err != nil {
+		return err
+	}
+	_, err := r.client.Delete(context.Background(), &cache.DeleteRequest{Key: CacheConfigDocumentKey + p})
+	return err
+}
+
+// Rename is not atomic, the cache service has no transaction
+func (r *Redis) Rename(from string, to string) error {
+	cs, err := r.Read(from)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Read(to); err == nil {
+		return ErrExists
+	} else if err != ErrNotFound {
+		return err
+	}
+	if err := r.Write(to, cs.Data); err != nil {
+		return err
+	}
+	_, err = r.client.Delete(context.Background(), &cache.DeleteRequest{Key: CacheConfigDocumentKey + from})
+	return err
+}
+
+// List pages through the document keys with the cache key service
+func (r *Redis) List() ([]string, error) {
+	var ps []string
+	request := &keyListRequest{Pattern: CacheConfigDocumentKey + "*", Size: listPageSize}
+	for {
+		req := r.keys.NewRequest(cacheService, "KeyService.List", request, client.WithContentType("application/grpc+json"))
+		var response keyListResponse
+		if err := r.keys.Call(context.Background(), req, &response); err != nil {
+			return nil, err
+		}
+		for _, k := range response.Keys {
+			if strings.HasPrefix(k, CacheConfigDocumentKey) {
+				ps = append(ps, strings.TrimPrefix(k, CacheConfigDocumentKey))
+			}
+		}
+		if response.Cursor == "" {
+			return ps, nil
+		}
+		request.Cursor = response.Cursor
+	}
+}
+
+func (r *Redis) Watch(p string) (source.Watcher, error) {
+	w := newWatcher(nil)
+	go r.poll(p, w)
+	return w, nil
+}
+
+func (r *Redis) String() string {
+	return "redis"
+}
+
+// poll sends the document to w whenever its checksum changes until w is stopped
+func (r *Redis) poll(p string, w *watcher) {
+	var checksum string
+	if cs, err := r.Read(p); err == nil {
+		checksum = cs.Checksum
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.exit:
+			return
+		case <-ticker.C:
+		}
+		cs, err := r.Read(p)
+		if err != nil || cs.Checksum == checksum {
+			continue
+		}
+		checksum = cs.Checksum
+		w.send(cs)
+	}
+}
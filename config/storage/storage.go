@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"go-micro.dev/v4/config/source"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrExists   = errors.New("document already exists")
+)
+
+// Storage keeps config documents by slash separated paths relative to the config root
+type Storage interface {
+	// Read returns the document at p, ErrNotFound when it does not exist
+	Read(p string) (*source.ChangeSet, error)
+	// Write creates or replaces the document at p
+	Write(p string, data []byte) error
+	// Delete removes the document at p, ErrNotFound when it does not exist
+	Delete(p string) error
+	// Rename moves the document at from to to, ErrExists when to already exists
+	Rename(from string, to string) error
+	// List returns the path of every document
+	List() ([]string, error)
+	// Watch returns a watcher of the changes of the document at p
+	Watch(p string) (source.Watcher, error)
+	String() string
+}
+
+type Op int
+
+const (
+	// Create a document appeared
+	Create Op = iota + 1
+	// Remove a document or a directory of documents disappeared
+	Remove
+)
+
+// Event is a change of the documents tree, Path may be a directory
+type Event struct {
+	Op   Op
+	Path string
+}
+
+// TreeWatcher is implemented by storages whose documents can be created or removed outside of the service
+type TreeWatcher interface {
+	WatchTree() (TreeWatch, error)
+}
+
+type TreeWatch interface {
+	// Next blocks until the next event
+	Next() (Event, error)
+	Stop() error
+}
+
+// Copy writes every document of src to dst and returns their paths
+func Copy(dst Storage, src Storage) ([]string, error) {
+	paths, err := src.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		cs, err := src.Read(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := dst.Write(p, cs.Data); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func newChangeSet(p string, data []byte, src string, modified time.Time) *source.ChangeSet {
+	cs := &source.ChangeSet{
+		Data:      data,
+		Format:    format(p),
+		Source:    src,
+		Timestamp: modified,
+	}
+	cs.Checksum = cs.Sum()
+	return cs
+}
+
+// format is the file extension of p like go-micro file source does
+func format(p string) string {
+	parts := strings.Split(p, ".")
+	if len(parts) > 1 {
+		return parts[len(parts)-1]
+	}
+	return "bytes"
+}
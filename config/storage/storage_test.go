@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testStorage(t *testing.T, s Storage) {
+	if _, err := s.Read("missing.yaml"); err != ErrNotFound {
+		t.Fatalf("Read() error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Write("service/micro.yaml", []byte("micro: {}")); err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.Watch("service/micro.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if err := s.Write("micro.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := s.Read("service/micro.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cs.Data, []byte("micro: {}")) || cs.Format != "yaml" || cs.Checksum == "" {
+		t.Fatalf("Read() = %+v", cs)
+	}
+	paths, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	if want := []string{"micro.json", "service/micro.yaml"}; len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Fatalf("List() = %v, want %v", paths, want)
+	}
+
+	next := make(chan []byte)
+	go func() {
+		cs, err := w.Next()
+		if err != nil {
+			close(next)
+			return
+		}
+		next <- cs.Data
+	}()
+	// give the watcher time to start before the change
+	time.Sleep(100 * time.Millisecond)
+	if err := s.Write("service/micro.yaml", []byte("micro: {name: test}")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-next:
+		if !bytes.Equal(data, []byte("micro: {name: test}")) {
+			t.Fatalf("Next() = %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher missed the change")
+	}
+
+	if err := s.Rename("service/micro.yaml", "micro.json"); err != ErrExists {
+		t.Fatalf("Rename() error = %v, want %v", err, ErrExists)
+	}
+	if err := s.Rename("service/micro.yaml", "other/micro.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read("service/micro.yaml"); err != ErrNotFound {
+		t.Fatalf("Read() renamed document error = %v, want %v", err, ErrNotFound)
+	}
+	if cs, err := s.Read("other/micro.yaml"); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(cs.Data, []byte("micro: {name: test}")) {
+		t.Fatalf("Read() renamed document = %s", cs.Data)
+	}
+	if err := s.Delete("other/micro.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("other/micro.yaml"); err != ErrNotFound {
+		t.Fatalf("Delete() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFile(t *testing.T) {
+	s, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
+
+func TestBolt(t *testing.T) {
+	s, err := NewBolt(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	testStorage(t, s)
+}
+
+func TestCopy(t *testing.T) {
+	src, err := NewFile("../conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewBolt(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	paths, err := Copy(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		want, err := src.Read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := dst.Read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Checksum != want.Checksum {
+			t.Errorf("%s checksum = %s, want %s", p, got.Checksum, want.Checksum)
+		}
+	}
+}
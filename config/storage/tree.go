@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"go-micro.dev/v4/config/source"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// treeWatch watches the root directory of a File storage and every directory below it
+type treeWatch struct {
+	sync.Mutex
+	f      *File
+	fw     *fsnotify.Watcher
+	dirs   map[string]bool
+	events []Event
+}
+
+// WatchTree reports files and directories created, removed or renamed in the root directory,
+// temporary files of Write are ignored
+func (f *File) WatchTree() (TreeWatch, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	t := &treeWatch{f: f, fw: fw, dirs: map[string]bool{}}
+	if _, err := t.add(f.root); err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *treeWatch) Next() (Event, error) {
+	for {
+		if len(t.events) > 0 {
+			e := t.events[0]
+			t.events = t.events[1:]
+			return e, nil
+		}
+		select {
+		case event, ok := <-t.fw.Events:
+			if !ok {
+				return Event{}, source.ErrWatcherStopped
+			}
+			t.handle(event)
+		case err, ok := <-t.fw.Errors:
+			if !ok {
+				return Event{}, source.ErrWatcherStopped
+			}
+			return Event{}, err
+		}
+	}
+}
+
+func (t *treeWatch) Stop() error {
+	return t.fw.Close()
+}
+
+func (t *treeWatch) handle(event fsnotify.Event) {
+	name := filepath.Clean(event.Name)
+	if strings.HasSuffix(name, ".tmp") {
+		return
+	}
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(name)
+		if err != nil {
+			return
+		}
+		if !info.IsDir() {
+			t.emit(Create, name)
+			return
+		}
+		// files may be created before the directory watch is added, report everything already inside
+		files, err := t.add(name)
+		if err != nil {
+			return
+		}
+		for _, file := range files {
+			t.emit(Create, file)
+		}
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		t.Lock()
+		for dir := range t.dirs {
+			if dir == name || strings.HasPrefix(dir, name+string(filepath.Separator)) {
+				_ = t.fw.Remove(dir)
+				delete(t.dirs, dir)
+			}
+		}
+		t.Unlock()
+		t.emit(Remove, name)
+	}
+}
+
+// add watches dir and every directory below it, then returns the files found inside
+func (t *treeWatch) add(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if !strings.HasSuffix(path, ".tmp") {
+				files = append(files, path)
+			}
+			return nil
+		}
+		if err := t.fw.Add(path); err != nil {
+			return err
+		}
+		t.Lock()
+		t.dirs[filepath.Clean(path)] = true
+		t.Unlock()
+		return nil
+	})
+	return files, err
+}
+
+func (t *treeWatch) emit(op Op, name string) {
+	rel, err := filepath.Rel(t.f.root, name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	t.events = append(t.events, Event{Op: op, Path: filepath.ToSlash(rel)})
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFile_WatchTree(t *testing.T) {
+	root := t.TempDir()
+	f, err := NewFile(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := f.WatchTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	events := make(chan Event, 16)
+	go func() {
+		for {
+			e, err := w.Next()
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- e
+		}
+	}()
+	expect := func(want Event) {
+		t.Helper()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					t.Fatalf("watch stopped before %+v", want)
+				}
+				if e == want {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("missed %+v", want)
+			}
+		}
+	}
+
+	if err := f.Write("micro.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{Op: Create, Path: "micro.json"})
+
+	if err := os.MkdirAll(filepath.Join(root, "service", "v1"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "service", "v1", "micro.yaml"), []byte("micro: {}"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{Op: Create, Path: "service/v1/micro.yaml"})
+
+	if err := os.Rename(filepath.Join(root, "service"), filepath.Join(root, "renamed")); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{Op: Remove, Path: "service"})
+	expect(Event{Op: Create, Path: "renamed/v1/micro.yaml"})
+
+	if err := os.Remove(filepath.Join(root, "micro.json")); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{Op: Remove, Path: "micro.json"})
+}
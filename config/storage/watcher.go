@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"go-micro.dev/v4/config/source"
+	"sync"
+)
+
+type watcher struct {
+	next chan *source.ChangeSet
+	exit chan bool
+	once sync.Once
+	stop func()
+}
+
+func newWatcher(stop func()) *watcher {
+	return &watcher{
+		next: make(chan *source.ChangeSet, 1),
+		exit: make(chan bool),
+		stop: stop,
+	}
+}
+
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	select {
+	case cs := <-w.next:
+		return cs, nil
+	case <-w.exit:
+		return nil, source.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() error {
+	w.once.Do(func() {
+		close(w.exit)
+		if w.stop != nil {
+			w.stop()
+		}
+	})
+	return nil
+}
+
+// send replaces a change not consumed yet, watchers only care about the latest one
+func (w *watcher) send(cs *source.ChangeSet) {
+	for {
+		select {
+		case <-w.exit:
+			return
+		case w.next <- cs:
+			return
+		default:
+		}
+		select {
+		case <-w.next:
+		default:
+		}
+	}
+}
+
+// watchers fan out the changes of documents written in process
+type watchers struct {
+	sync.Mutex
+	m map[string]map[*watcher]bool
+}
+
+func (ws *watchers) add(p string) *watcher {
+	var w *watcher
+	w = newWatcher(func() {
+		ws.Lock()
+		defer ws.Unlock()
+		delete(ws.m[p], w)
+		if len(ws.m[p]) == 0 {
+			delete(ws.m, p)
+		}
+	})
+	ws.Lock()
+	defer ws.Unlock()
+	if ws.m == nil {
+		ws.m = map[string]map[*watcher]bool{}
+	}
+	if ws.m[p] == nil {
+		ws.m[p] = map[*watcher]bool{}
+	}
+	ws.m[p][w] = true
+	return w
+}
+
+func (ws *watchers) notify(p string, cs *source.ChangeSet) {
+	ws.Lock()
+	defer ws.Unlock()
+	for w := range ws.m[p] {
+		w.send(cs)
+	}
+}
@@ -0,0 +1,137 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	errPlain     = errors.New("value with special characters inside a plain scalar, quote the scalar")
+	errLineBreak = errors.New("value with a line break inside a single quoted scalar or a comment")
+
+	number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// quote returns value v of the reference at data[start:end] of a document of format, escaped inside a quoted string
+// and quoted where a scalar is expected unless it is one already, so a value never ends a string or adds keys,
+// encoded tells v is the json of a value that is not a string
+func quote(format string, data []byte, start int, end int, v string, encoded bool) (string, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		if inJSONString(data[:start]) {
+			return escape(v), nil
+		}
+		if encoded || scalar(v) {
+			return v, nil
+		}
+		return `"` + escape(v) + `"`, nil
+	case "yaml", "yml":
+		line := data[bytes.LastIndexByte(data[:start], '\n')+1 : start]
+		switch yamlContext(line) {
+		case '"':
+			return escape(v), nil
+		case '\'':
+			if strings.ContainsAny(v, "\r\n") {
+				return "", errLineBreak
+			}
+			return strings.ReplaceAll(v, "'", "''"), nil
+		case '#':
+			if strings.ContainsAny(v, "\r\n") {
+				return "", errLineBreak
+			}
+			return v, nil
+		}
+		if (encoded && !strings.ContainsAny(v, "\r\n")) || plain(v) {
+			return v, nil
+		}
+		if yamlScalarStart(line) && yamlScalarEnd(data[end:]) {
+			return `"` + escape(v) + `"`, nil
+		}
+		return "", errPlain
+	}
+	return v, nil
+}
+
+// escape returns v as the content of a json string, which is also a yaml double quoted scalar
+func escape(v string) string {
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(v)
+	quoted := strings.TrimSuffix(b.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+// scalar reports whether v is a json number, boolean or null
+func scalar(v string) bool {
+	return v == "true" || v == "false" || v == "null" || number.MatchString(v)
+}
+
+// plain reports whether v reads as the same yaml plain scalar wherever it is inserted
+func plain(v string) bool {
+	if number.MatchString(v) {
+		return true
+	}
+	return v != "" && strings.TrimSpace(v) == v &&
+		!strings.ContainsAny(v, "\r\n\t,[]{}") &&
+		!strings.ContainsAny(v[:1], "-?:#&*!|>'\"%@`") &&
+		!strings.Contains(v, ": ") && !strings.Contains(v, " #") && !strings.HasSuffix(v, ":")
+}
+
+// inJSONString reports whether the end of data is inside a json string
+func inJSONString(data []byte) bool {
+	in := false
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case in && c == '\\':
+			i++
+		case c == '"':
+			in = !in
+		}
+	}
+	return in
+}
+
+// yamlContext returns the quote of the scalar the end of line is in, '#' in a comment, 0 elsewhere
+func yamlContext(line []byte) byte {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return '#'
+		case (c == '"' || c == '\'') && yamlScalarStart(line[:i]):
+			quote = c
+		}
+	}
+	return quote
+}
+
+// yamlScalarStart reports whether a scalar starts after line
+func yamlScalarStart(line []byte) bool {
+	trimmed := bytes.TrimRight(line, " \t")
+	if len(trimmed) == 0 {
+		return true
+	}
+	last := trimmed[len(trimmed)-1]
+	return len(trimmed) < len(line) && strings.IndexByte(":-?", last) >= 0 || strings.IndexByte("[{,", last) >= 0
+}
+
+// yamlScalarEnd reports whether a scalar ends before rest
+func yamlScalarEnd(rest []byte) bool {
+	trimmed := bytes.TrimLeft(rest, " \t")
+	if len(trimmed) == 0 {
+		return true
+	}
+	return strings.IndexByte("\r\n,]}", trimmed[0]) >= 0 || trimmed[0] == '#' && len(trimmed) < len(rest)
+}
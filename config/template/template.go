@@ -0,0 +1,179 @@
+// Package template resolves the references of a document to environment variables and to values of other documents:
+//
+//	${env:CONFIG_REDIS_ADDR}                   environment variable CONFIG_REDIS_ADDR
+//	${env:CONFIG_REDIS_ADDR:-localhost:6379}   with a default when it is not set
+//	${ref:common.yaml#redis.addr}              value at key redis.addr of common.yaml
+//	${ref:common.yaml#redis.db:-0}             with a default when the key is missing
+//	$${env:CONFIG_REDIS_ADDR}                  the text ${env:CONFIG_REDIS_ADDR}
+//
+// only the environment variables prefixed with EnvPrefix can be referenced, the other ones are process secrets.
+// string values are inserted as is, other values as json, values are escaped inside the quoted strings of json
+// and yaml documents and quoted where a scalar is expected unless they are one already
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sparrow-community/app/config/convert"
+	"go-micro.dev/v4/config/source"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// EnvPrefix of the environment variables a document can reference
+const EnvPrefix = "CONFIG_"
+
+var (
+	ErrSyntax = errors.New("invalid reference")
+
+	reference = regexp.MustCompile(`\$\$\{|\$\{(env|ref):([^}]*)\}`)
+)
+
+// CycleError documents referencing each other
+type CycleError struct {
+	Paths []string
+}
+
+func (e *CycleError) Error() string {
+	return "reference cycle " + strings.Join(e.Paths, " -> ")
+}
+
+// Loader returns the document at p
+type Loader func(p string) (*source.ChangeSet, error)
+
+// Resolver resolves the references of documents loaded by Load
+type Resolver struct {
+	Load Loader
+	// Env looks up environment variables, os.LookupEnv when nil
+	Env func(key string) (string, bool)
+}
+
+// Resolve returns data of the document at p of format with its references replaced, and the paths of the documents
+// it references directly or through other documents
+func (r *Resolver) Resolve(p string, format string, data []byte) ([]byte, []string, error) {
+	s := &state{r: r, resolved: map[string]interface{}{}}
+	out, err := s.resolve([]string{p}, format, data)
+	if err != nil {
+		return nil, s.deps, err
+	}
+	return out, s.deps, nil
+}
+
+// Has reports whether data contains references
+func Has(data []byte) bool {
+	return bytes.Contains(data, []byte("${"))
+}
+
+type state struct {
+	r *Resolver
+	// resolved decoded documents by path
+	resolved map[string]interface{}
+	deps     []string
+}
+
+func (s *state) resolve(stack []string, format string, data []byte) ([]byte, error) {
+	if !Has(data) {
+		return data, nil
+	}
+	var out bytes.Buffer
+	last := 0
+	for _, m := range reference.FindAllSubmatchIndex(data, -1) {
+		out.Write(data[last:m[0]])
+		last = m[1]
+		// $${
+		if m[2] < 0 {
+			out.WriteString("${")
+			continue
+		}
+		v, encoded, err := s.value(stack, string(data[m[2]:m[3]]), string(data[m[4]:m[5]]))
+		if err != nil {
+			return nil, err
+		}
+		if v, err = quote(format, data, m[0], m[1], v, encoded); err != nil {
+			return nil, fmt.Errorf("%s of %s: %w", data[m[0]:m[1]], stack[len(stack)-1], err)
+		}
+		out.WriteString(v)
+	}
+	out.Write(data[last:])
+	return out.Bytes(), nil
+}
+
+// value returns the value of reference body of kind and whether it is the json of a value that is not a string
+func (s *state) value(stack []string, kind string, body string) (string, bool, error) {
+	body, def, hasDefault := strings.Cut(body, ":-")
+	switch kind {
+	case "env":
+		if !strings.HasPrefix(body, EnvPrefix) {
+			return "", false, fmt.Errorf("environment variable %s is not allowed, only %s* are", body, EnvPrefix)
+		}
+		env := s.r.Env
+		if env == nil {
+			env = os.LookupEnv
+		}
+		if v, ok := env(body); ok {
+			return v, false, nil
+		}
+		if hasDefault {
+			return def, false, nil
+		}
+		return "", false, fmt.Errorf("environment variable %s is not set", body)
+	default:
+		p, key, ok := strings.Cut(body, "#")
+		if !ok || p == "" || key == "" {
+			return "", false, fmt.Errorf("%w ${ref:%s}, want ${ref:path#key}", ErrSyntax, body)
+		}
+		doc, err := s.document(stack, p)
+		if err != nil {
+			return "", false, err
+		}
+		v, ok := convert.Lookup(doc, key)
+		if !ok {
+			if hasDefault {
+				return def, false, nil
+			}
+			return "", false, fmt.Errorf("key %s not found in %s", key, p)
+		}
+		return text(v)
+	}
+}
+
+// document returns the decoded document at p with its own references resolved
+func (s *state) document(stack []string, p string) (interface{}, error) {
+	for i, visited := range stack {
+		if visited == p {
+			return nil, &CycleError{Paths: append(append([]string{}, stack[i:]...), p)}
+		}
+	}
+	if doc, ok := s.resolved[p]; ok {
+		return doc, nil
+	}
+	set, err := s.r.Load(p)
+	if err != nil {
+		return nil, fmt.Errorf("reference %s error %w", p, err)
+	}
+	s.deps = append(s.deps, p)
+	data, err := s.resolve(append(stack, p), set.Format, set.Data)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := convert.Decode(set.Format, data)
+	if err != nil {
+		return nil, fmt.Errorf("reference %s error %w", p, err)
+	}
+	s.resolved[p] = doc
+	return doc, nil
+}
+
+func text(v interface{}) (string, bool, error) {
+	if s, ok := v.(string); ok {
+		return s, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
@@ -0,0 +1,280 @@
+(function () {
+  'use strict';
+
+  const api = location.pathname.replace(/\/ui\/.*$/, '') + '/api';
+  const $ = (id) => document.getElementById(id);
+  const token = $('token');
+  const content = $('content');
+
+  let current = null; // path of the open document
+  let checksum = '';  // checksum of the version loaded in the editor
+  let dirty = false;
+  let events = null;
+
+  token.value = localStorage.getItem('config.token') || '';
+  token.addEventListener('change', () => {
+    localStorage.setItem('config.token', token.value);
+    list();
+  });
+
+  function request(method, url, body, signal) {
+    const headers = {};
+    if (token.value) headers.Authorization = 'Bearer ' + token.value;
+    return fetch(api + url, { method, headers, body, signal }).then(async (r) => {
+      if (!r.ok) {
+        const e = await r.json().catch(() => ({ message: r.statusText }));
+        throw new Error(e.message);
+      }
+      return r;
+    });
+  }
+
+  function encode(p) {
+    return p.split('/').map(encodeURIComponent).join('/');
+  }
+
+  function message(text, error) {
+    $('message').textContent = text || '';
+    $('message').className = error ? 'error' : '';
+  }
+
+  function format(p) {
+    const i = p.lastIndexOf('.');
+    return i < 0 ? '' : p.slice(i + 1);
+  }
+
+  function escape(s) {
+    return s.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+  }
+
+  // highlight colors keys, strings, numbers, literals, comments and ENC[...] values of json and yaml
+  function highlight() {
+    const rules = format(current || '') === 'json'
+      ? /("(?:[^"\\]|\\.)*")(\s*:)?|(-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)|(\btrue\b|\bfalse\b|\bnull\b)()/g
+      : /(#.*$)|^(\s*-?\s*[\w.-]+)(:)|("(?:[^"\\]|\\.)*"|'[^']*')|(ENC\[[^\]]*\])/gm;
+    const html = escape(content.value).replace(rules, function (m, a, b, c, d, e) {
+      if (format(current || '') === 'json') {
+        if (a !== undefined) {
+          const cls = b ? 'key' : (/^"ENC\[/.test(a) ? 'secret' : 'string');
+          return '<span class="' + cls + '">' + a + '</span>' + (b || '');
+        }
+        if (c !== undefined) return '<span class="number">' + c + '</span>';
+        return '<span class="literal">' + d + '</span>';
+      }
+      if (a !== undefined) return '<span class="comment">' + a + '</span>';
+      if (b !== undefined) return '<span class="key">' + b + '</span>' + c;
+      if (d !== undefined) return '<span class="' + (/ENC\[/.test(d) ? 'secret' : 'string') + '">' + d + '</span>';
+      return '<span class="secret">' + e + '</span>';
+    });
+    $('highlight').innerHTML = html + '\n';
+  }
+
+  // validate checks json documents before they are sent, the server validates every format
+  function validate() {
+    if (format(current) !== 'json') return true;
+    try {
+      JSON.parse(content.value);
+      return true;
+    } catch (e) {
+      message('invalid json ' + e.message, true);
+      return false;
+    }
+  }
+
+  content.addEventListener('input', () => {
+    dirty = true;
+    $('state').textContent = 'modified';
+    highlight();
+  });
+  content.addEventListener('scroll', () => {
+    $('highlight').scrollTop = content.scrollTop;
+    $('highlight').scrollLeft = content.scrollLeft;
+  });
+
+  async function list() {
+    const glob = $('filter').value;
+    try {
+      const r = await request('GET', '/documents' + (glob ? '?glob=' + encodeURIComponent(glob) : ''));
+      const { documents } = await r.json();
+      const ul = $('documents');
+      ul.innerHTML = '';
+      (documents || []).forEach((d) => {
+        const li = document.createElement('li');
+        li.textContent = d.path;
+        li.title = d.size + ' bytes, ' + new Date(d.modified * 1000).toLocaleString();
+        li.className = d.path === current ? 'active' : '';
+        li.addEventListener('click', () => open(d.path));
+        ul.appendChild(li);
+      });
+    } catch (e) {
+      message(e.message, true);
+    }
+  }
+
+  async function open(p) {
+    if (dirty && current !== p && !confirm('Discard the changes of ' + current + '?')) return;
+    current = p;
+    $('document').hidden = false;
+    $('path').textContent = p;
+    $('diff').hidden = true;
+    message('');
+    await load();
+    revisions();
+    watch();
+    list();
+  }
+
+  async function load() {
+    try {
+      const r = await request('GET', '/documents/' + encode(current) + '?raw=true');
+      content.value = await r.text();
+      checksum = JSON.parse(r.headers.get('ETag') || '""');
+      dirty = false;
+      $('state').textContent = '';
+      highlight();
+    } catch (e) {
+      message(e.message, true);
+    }
+  }
+
+  async function revisions() {
+    const body = $('revisions').tBodies[0];
+    body.innerHTML = '';
+    try {
+      const r = await request('GET', '/revisions/' + encode(current));
+      const { revisions } = await r.json();
+      (revisions || []).forEach((rev) => {
+        const tr = document.createElement('tr');
+        tr.innerHTML = '<td>' + rev.revision + '</td><td>' + new Date(rev.time * 1000).toLocaleString() +
+          '</td><td>' + escape(rev.actor || 'storage') + '</td><td><a href="#">diff with current</a></td>';
+        tr.querySelector('a').addEventListener('click', (e) => {
+          e.preventDefault();
+          diff(rev.revision);
+        });
+        body.appendChild(tr);
+      });
+    } catch (e) {
+      message(e.message, true);
+    }
+  }
+
+  async function diff(from) {
+    try {
+      const r = await request('GET', '/diff/' + encode(current) + '?from=' + from);
+      const text = await r.text();
+      $('diff').innerHTML = text ? escape(text).split('\n').map((l) => {
+        const cls = l.startsWith('@@') ? 'hunk' : l.startsWith('+') ? 'add' : l.startsWith('-') ? 'del' : '';
+        return cls ? '<span class="' + cls + '">' + l + '</span>' : l;
+      }).join('\n') : 'no change';
+      $('diff').hidden = false;
+    } catch (e) {
+      message(e.message, true);
+    }
+  }
+
+  // watch reloads the open document when it changes, unless it is being edited, the server-sent events are
+  // read with fetch since event sources cannot send the Authorization header
+  function watch() {
+    if (events) events.abort();
+    const controller = new AbortController();
+    events = controller;
+    request('GET', '/watch/' + encode(current) + '?raw=true', undefined, controller.signal).then(async (r) => {
+      const reader = r.body.pipeThrough(new TextDecoderStream()).getReader();
+      let buffer = '';
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += value;
+        for (let end = buffer.indexOf('\n\n'); end >= 0; end = buffer.indexOf('\n\n')) {
+          event(buffer.slice(0, end));
+          buffer = buffer.slice(end + 2);
+        }
+      }
+      // the server ended the stream, follow the document again
+      setTimeout(() => events === controller && watch(), 1000);
+    }).catch((e) => {
+      if (e.name !== 'AbortError') message(e.message, true);
+    });
+  }
+
+  // event handles a server-sent event of a watch
+  function event(block) {
+    let name = 'message';
+    let data = '';
+    for (const line of block.split('\n')) {
+      if (line.startsWith('event:')) name = line.slice(6).trim();
+      else if (line.startsWith('data:')) data += line.slice(5).trim();
+    }
+    if (name === 'error') {
+      message(JSON.parse(data).message, true);
+      events.abort();
+      return;
+    }
+    if (name !== 'change') return;
+    const set = JSON.parse(data);
+    if (set.checksum === checksum) return;
+    if (dirty) {
+      $('state').textContent = 'modified, a newer version was saved';
+      return;
+    }
+    load();
+    revisions();
+  }
+
+  $('save').addEventListener('click', async () => {
+    if (!validate()) return;
+    try {
+      await request('PUT', '/documents/' + encode(current), content.value);
+      dirty = false;
+      message('saved');
+      await load();
+      revisions();
+    } catch (e) {
+      message(e.message, true);
+    }
+  });
+
+  $('delete').addEventListener('click', async () => {
+    if (!confirm('Delete ' + current + '?')) return;
+    try {
+      await request('DELETE', '/documents/' + encode(current));
+      if (events) events.abort();
+      current = null;
+      dirty = false;
+      $('document').hidden = true;
+      list();
+    } catch (e) {
+      message(e.message, true);
+    }
+  });
+
+  $('rename').addEventListener('click', async () => {
+    const to = prompt('Rename ' + current + ' to', current);
+    if (!to || to === current) return;
+    try {
+      await request('POST', '/rename', JSON.stringify({ from: current, to }));
+      dirty = false;
+      open(to);
+    } catch (e) {
+      message(e.message, true);
+    }
+  });
+
+  $('new').addEventListener('click', async () => {
+    const p = prompt('Path of the new document, like app/service.yaml');
+    if (!p) return;
+    try {
+      await request('PUT', '/documents/' + encode(p), format(p) === 'json' ? '{}' : '');
+      open(p);
+    } catch (e) {
+      message(e.message, true);
+    }
+  });
+
+  $('filter').addEventListener('change', list);
+  window.addEventListener('beforeunload', (e) => {
+    if (dirty) e.preventDefault();
+  });
+
+  list();
+})();
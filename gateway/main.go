@@ -4,8 +4,10 @@ import (
 	mhttp "github.com/go-micro/plugins/v4/server/http"
 	"github.com/sparrow-community/app/gateway/config"
 	"go-micro.dev/v4"
+	"go-micro.dev/v4/client"
 	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/util/wrapper"
 	"net"
 )
 
@@ -38,7 +40,11 @@ func main() {
 	if err := httpServer.Handle(httpServer.NewHandler(ReverseProxy())); err != nil {
 		logger.Errorf("error creating http server: %", err)
 	}
-	var opts []micro.Option
+	opts := []micro.Option{
+		micro.Name(config.Conf.Server.Name),
+		// the cache names the namespace of the service after the service name of its client
+		micro.Client(wrapper.FromService(config.Conf.Server.Name, client.DefaultClient)),
+	}
 	httpOpts := append(opts, micro.Server(httpServer))
 	srv := micro.NewService(httpOpts...)
 	if err := srv.Run(); err != nil {
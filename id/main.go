@@ -6,8 +6,10 @@ import (
 	"github.com/sparrow-community/app/id/handler"
 	"github.com/sparrow-community/protos/id"
 	"go-micro.dev/v4"
+	"go-micro.dev/v4/client"
 	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/util/wrapper"
 	"net"
 )
 
@@ -35,6 +37,8 @@ func main() {
 
 	opts := []micro.Option{
 		micro.Name(config.Conf.Server.Name),
+		// the cache names the namespace of the service after the service name of its client
+		micro.Client(wrapper.FromService(config.Conf.Server.Name, client.DefaultClient)),
 		micro.Version(Version),
 	}
 
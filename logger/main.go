@@ -11,6 +11,7 @@ import (
 	"go-micro.dev/v4"
 	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/util/wrapper"
 	"net"
 	"net/http"
 )
@@ -38,7 +39,8 @@ func main() {
 
 	opts := []micro.Option{
 		micro.Name(config.Conf.Server.Name),
-		micro.Client(mcgrpc.NewClient()),
+		// the cache names the namespace of the service after the service name of its client
+		micro.Client(wrapper.FromService(config.Conf.Server.Name, mcgrpc.NewClient())),
 		micro.Version(Version),
 	}
 